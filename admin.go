@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	taskDB "github.com/iabdulzahid/golang_task_manager/internal/database"
+)
+
+const adminUsage = `usage: golang_task_manager admin <command>
+
+commands:
+  users                          list users
+  create-user <name> [--admin]   add a user; admins see every task
+  issue-key <user> [name]        issue an API key for a user (by ID or name)
+  keys [user]                    list API keys, of one user or of everyone
+  revoke-key <key-id>            revoke an API key`
+
+// runAdmin implements the "admin" subcommand and returns the process exit code.
+func runAdmin(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return 2
+	}
+
+	taskDB.LoadEnv()
+	store, err := taskDB.OpenStore(os.Getenv("DATABASE_DRIVER"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error connecting to database:", err)
+		return 1
+	}
+	defer store.Close()
+	if _, ok := store.(*taskDB.MemoryStore); ok {
+		fmt.Fprintln(os.Stderr, "the memory backend keeps nothing between runs; use API_KEY with it instead")
+		return 1
+	}
+	taskDB.SetStore(store)
+
+	switch args[0] {
+	case "users":
+		return printUsers()
+	case "create-user":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "missing user name\n"+adminUsage)
+			return 2
+		}
+		isAdmin := len(args) > 2 && args[2] == "--admin"
+		user, err := taskDB.CreateUser(args[1], isAdmin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error creating user:", err)
+			return 1
+		}
+		fmt.Printf("Created user %s (%s)\n", user.Name, user.ID)
+		return 0
+	case "issue-key":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "missing user\n"+adminUsage)
+			return 2
+		}
+		user, err := taskDB.FindUser(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error finding user:", err)
+			return 1
+		}
+		name := strings.Join(args[2:], " ")
+		plaintext, key, err := taskDB.IssueAPIKey(user.ID, name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error issuing key:", err)
+			return 1
+		}
+		fmt.Printf("Issued key %s for %s. Store it now, it is not shown again:\n%s\n", key.ID, user.Name, plaintext)
+		return 0
+	case "keys":
+		userID := ""
+		if len(args) > 1 {
+			user, err := taskDB.FindUser(args[1])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error finding user:", err)
+				return 1
+			}
+			userID = user.ID
+		}
+		return printAPIKeys(userID)
+	case "revoke-key":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "missing key ID\n"+adminUsage)
+			return 2
+		}
+		if err := taskDB.RevokeAPIKey(args[1]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error revoking key:", err)
+			return 1
+		}
+		fmt.Println("Revoked key", args[1])
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown admin command %q\n%s\n", args[0], adminUsage)
+		return 2
+	}
+}
+
+func printUsers() int {
+	users, err := taskDB.ListUsers()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error listing users:", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tADMIN\tCREATED AT")
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", user.ID, user.Name, user.IsAdmin, user.CreatedAt)
+	}
+	w.Flush()
+	return 0
+}
+
+func printAPIKeys(userID string) int {
+	keys, err := taskDB.ListAPIKeys(userID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error listing keys:", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSER\tNAME\tPREFIX\tCREATED AT\tLAST USED\tREVOKED AT")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.UserID, key.Name, key.Prefix, key.CreatedAt, orDash(key.LastUsedAt.IsZero(), key.LastUsedAt.String()), orDash(key.RevokedAt.IsZero(), key.RevokedAt.String()))
+	}
+	w.Flush()
+	return 0
+}
+
+func orDash(empty bool, value string) string {
+	if empty {
+		return "-"
+	}
+	return value
+}
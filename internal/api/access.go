@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+)
+
+// callerID returns the user ID of the caller, or nil on unauthenticated routes.
+func callerID(c *gin.Context) *string {
+	if identity := middleware.CurrentIdentity(c); identity != nil {
+		id := identity.UserID
+		return &id
+	}
+	return nil
+}
+
+// canSee reports whether the caller may read a task.
+func canSee(c *gin.Context, task models.Task) bool {
+	return policy.Check(middleware.CurrentIdentity(c), policy.Read, &task) == nil
+}
+
+// visibleTasks drops the tasks the caller may not read.
+func visibleTasks(c *gin.Context, tasks []models.Task) []models.Task {
+	visible := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if canSee(c, task) {
+			visible = append(visible, task)
+		}
+	}
+	return visible
+}
+
+// authorize asks the policy whether the caller may perform action on task
+// and writes the response when not: 404 for tasks the caller may not see,
+// so their IDs cannot be probed, and 403 with the reason otherwise.
+func authorize(c *gin.Context, action policy.Action, task *models.Task) bool {
+	if status, message := checkAccess(c, action, task); status != 0 {
+		c.JSON(status, models.ErrorResponse{Error: message})
+		return false
+	}
+	return true
+}
+
+// checkAccess is authorize without the response: it returns the status and
+// error message of a denied request, or 0 when the caller may go ahead.
+func checkAccess(c *gin.Context, action policy.Action, task *models.Task) (int, string) {
+	err := policy.Check(middleware.CurrentIdentity(c), action, task)
+	switch {
+	case err == nil:
+		return 0, ""
+	case errors.Is(err, policy.ErrHidden):
+		return http.StatusNotFound, "Task not found"
+	default:
+		return http.StatusForbidden, err.Error()
+	}
+}
+
+// authorizeTask fetches a task for a handler and checks that the caller may
+// perform action on it. On failure the response has been written and ok is
+// false.
+func authorizeTask(c *gin.Context, taskID string, action policy.Action) (task *models.Task, ok bool) {
+	task, status, message := loadTaskFor(c, taskID, action)
+	if status != 0 {
+		c.JSON(status, models.ErrorResponse{Error: message})
+		return nil, false
+	}
+	return task, true
+}
+
+// loadTaskFor is authorizeTask without the response: on failure it returns
+// the status and error message instead of writing them.
+func loadTaskFor(c *gin.Context, taskID string, action policy.Action) (*models.Task, int, string) {
+	task, err := database.GetTaskByID(taskID)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		return nil, http.StatusNotFound, "Task not found"
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err.Error()
+	}
+	if status, message := checkAccess(c, action, task); status != 0 {
+		return nil, status, message
+	}
+	return task, 0, ""
+}
+
+// checkParentVisible rejects a parent_id the caller may not read with the
+// same error as a parent that does not exist.
+func checkParentVisible(c *gin.Context, parentID *string) bool {
+	if !parentVisible(c, parentID) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: database.ErrParentNotFound.Error() + ": " + *parentID})
+		return false
+	}
+	return true
+}
+
+// parentVisible is checkParentVisible without the response.
+func parentVisible(c *gin.Context, parentID *string) bool {
+	if parentID == nil || *parentID == "" {
+		return true
+	}
+	parent, err := database.GetTaskByID(*parentID)
+	return err != nil || canSee(c, *parent)
+}
+
+// pruneTree removes the subtasks the caller may not read from a task tree.
+func pruneTree(c *gin.Context, task *models.Task) {
+	children := task.Children[:0]
+	for _, child := range task.Children {
+		if canSee(c, child) {
+			pruneTree(c, &child)
+			children = append(children, child)
+		}
+	}
+	task.Children = children
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+)
+
+// CreateGrant godoc
+// @Summary Grant a role
+// @Description Give a user the viewer, editor or admin role on a task (task_id), on every task of a project (project) or everywhere (neither). Granting again in the same scope changes the role. Admins of a task or project can grant on it; only global admins can grant global roles.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param grant body models.GrantRequest true "User, role and scope"
+// @Success 201 {object} models.Grant "Grant created"
+// @Success 200 {object} models.Grant "Role of an existing grant changed"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/grants [post]
+func CreateGrant(c *gin.Context) {
+	var request models.GrantRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !authorizeGrantScope(c, strings.TrimSpace(request.Project), strings.TrimSpace(request.TaskID)) {
+		return
+	}
+
+	grant, created, err := database.GrantRole(request, callerID(c))
+	if errors.Is(err, database.ErrInvalidGrant) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, grant)
+}
+
+// ListGrants godoc
+// @Summary List role grants
+// @Description List the grants on a task (task_id) or a project (project), optionally of one user. Global admins may leave both out to list every grant.
+// @Tags admin
+// @Produce json
+// @Param task_id query string false "Grants on this task"
+// @Param project query string false "Grants on this project"
+// @Param user_id query string false "Grants of this user"
+// @Success 200 {array} models.Grant
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/grants [get]
+func ListGrants(c *gin.Context) {
+	filter := database.GrantFilter{
+		UserID:  c.Query("user_id"),
+		Project: strings.TrimSpace(c.Query("project")),
+		TaskID:  strings.TrimSpace(c.Query("task_id")),
+	}
+	if !authorizeGrantScope(c, filter.Project, filter.TaskID) {
+		return
+	}
+
+	grants, err := database.ListGrants(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// RevokeGrant godoc
+// @Summary Revoke a role grant
+// @Description Delete a grant. The same rights as for granting in its scope are needed.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Grant ID"
+// @Success 200 {object} models.SuccessMessage
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/grants/{id} [delete]
+func RevokeGrant(c *gin.Context) {
+	grant, err := database.GetGrant(c.Param("id"))
+	if errors.Is(err, database.ErrGrantNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Grant not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var project, taskID string
+	if grant.Project != nil {
+		project = *grant.Project
+	}
+	if grant.TaskID != nil {
+		taskID = *grant.TaskID
+	}
+	if !authorizeGrantScope(c, project, taskID) {
+		return
+	}
+
+	if err := database.RevokeGrant(grant.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.SuccessMessage{Message: "Grant revoked"})
+}
+
+// authorizeGrantScope checks that the caller may manage the grants of a task,
+// a project or, with neither, the global grants.
+func authorizeGrantScope(c *gin.Context, project, taskID string) bool {
+	if taskID != "" {
+		_, ok := authorizeTask(c, taskID, policy.Grant)
+		return ok
+	}
+	if err := policy.CheckProjectGrant(middleware.CurrentIdentity(c), project); err != nil {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/leader"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+)
+
+// GetLeaderStatus godoc
+// @Summary Show which server runs the background jobs
+// @Description When several servers share a database, one of them, the leader, runs the background jobs such as marking tasks overdue and creating the next occurrence of recurring tasks. It holds a lease in the database and renews it; when it stops, another server takes over once the lease expires. Only admins can see this.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.LeaderStatus
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse "Leader election is not running"
+// @Security ApiKeyAuth
+// @Router /admin/leader [get]
+func GetLeaderStatus(c *gin.Context) {
+	if err := policy.CheckAdmin(middleware.CurrentIdentity(c)); err != nil {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	elector := leader.Current()
+	if elector == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "Leader election is not running"})
+		return
+	}
+
+	status, err := elector.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
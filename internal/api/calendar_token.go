@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// CreateCalendarToken godoc
+// @Summary Issue a calendar feed token
+// @Description Issue the caller a token for the calendar feed and return the URL to subscribe to. A previous token stops working. The token is shown only once and only opens the feed.
+// @Tags calendar
+// @Produce json
+// @Success 201 {object} models.CalendarToken
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /calendar/token [post]
+func CreateCalendarToken(c *gin.Context) {
+	identity := middleware.CurrentIdentity(c)
+	token, err := database.IssueCalendarToken(identity.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	feed := url.URL{Scheme: scheme, Host: c.Request.Host, Path: "/calendar/tasks.ics", RawQuery: url.Values{"token": {token}}.Encode()}
+	c.JSON(http.StatusCreated, models.CalendarToken{Token: token, URL: feed.String()})
+}
+
+// RevokeCalendarToken godoc
+// @Summary Revoke the calendar feed token
+// @Description Stop the caller's calendar feed token from working.
+// @Tags calendar
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /calendar/token [delete]
+func RevokeCalendarToken(c *gin.Context) {
+	if err := database.RevokeCalendarToken(middleware.CurrentIdentity(c).UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/priority"
+)
+
+// taskETag is the entity tag of a task as served by GET /tasks/{id}: its
+// version, plus a digest of its effective priority and rollup. Neither bumps
+// the version: the policy changes the priority as the due date nears, and the
+// rollup changes with the children.
+func taskETag(task *models.Task) string {
+	effective := *task
+	priority.Current().Apply(&effective)
+	data, _ := json.Marshal(struct {
+		Priority *models.Priority
+		Rollup   *models.TaskRollup
+	}{effective.Priority, task.Rollup})
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf(`"%d-%x"`, task.Version, sum[:4])
+}
+
+// contentETag is the entity tag of any other response: a digest of its body.
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header names etag.
+// If-Match compares strongly, so weak tags never match it; If-None-Match
+// compares weakly.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces the If-Match header of a write to task. It returns
+// the version the write has to find the task at, or 0 for a request without
+// If-Match. On a mismatch it answers 412 Precondition Failed with the
+// current ETag.
+func checkIfMatch(c *gin.Context, task *models.Task) (int64, bool) {
+	version, err := ifMatchVersion(c.GetHeader("If-Match"), task)
+	if err != nil {
+		c.Header("ETag", taskETag(task))
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: err.Error()})
+		return 0, false
+	}
+	return version, true
+}
+
+// ifMatchVersion is checkIfMatch for an If-Match value that did not come in
+// a header, e.g. one of a bulk operation.
+func ifMatchVersion(ifMatch string, task *models.Task) (int64, error) {
+	if ifMatch == "" {
+		return 0, nil
+	}
+	if etag := taskETag(task); !etagMatches(ifMatch, etag, false) {
+		return 0, fmt.Errorf("task %s was changed; its ETag is now %s", task.ID, etag)
+	}
+	return task.Version, nil
+}
+
+// notModified sets the ETag header of a GET and answers 304 Not Modified
+// when the If-None-Match header names it.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// respondWithETag writes body as JSON tagged with the digest of its bytes,
+// or 304 Not Modified when the client already has them.
+func respondWithETag(c *gin.Context, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if notModified(c, contentETag(data)) {
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
@@ -1,27 +1,39 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	_ "github.com/iabdulzahid/golang_task_manager/docs" // Import Swagger docs
 
 	"github.com/gin-gonic/gin"
 	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
 	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+	"github.com/iabdulzahid/golang_task_manager/internal/recurrence"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
 	"github.com/iabdulzahid/golang_task_manager/pkg/globals"
 )
 
 // CreateTask godoc
 // @Summary Create a new task
-// @Description Create a new task with title, description, priority, and due date
+// @Description Create a new task with title, description, priority, and due date. The caller becomes its owner.
 // @Tags tasks
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Makes the request safe to retry: repeats with the same key and body replay the first response"
 // @Param task body models.Task true "Task data"
 // @Success 201 {object} models.SuccessMessage "Task Created Successfully"
+// @Header 201 {string} Idempotent-Replayed "true when the response is a replay"
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse "The caller may not create tasks (in this project)"
+// @Failure 409 {object} models.ErrorResponse "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.ErrorResponse "The Idempotency-Key was used for a different request"
 // @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
 // @Router /tasks [post]
 func CreateTask(c *gin.Context) {
 	logger := globals.Logger
@@ -32,28 +44,23 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
-	// Validate task
-	if task.Title == "" || task.DueDate == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Missing required fields"})
+	if err := validateTask(&task); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
-
-	// Set default value for empty labels
-	if task.Labels == nil {
-		task.Labels = []string{}
-	}
-
-	if !globals.IsValidPriority(string(*task.Priority)) {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("invalid priority: %s. Valid values are: %v", task.Priority, globals.GetValidPriorityValues())})
+	if !authorize(c, policy.Create, &task) || !checkParentVisible(c, task.ParentID) {
 		return
 	}
 
-	if task.Priority == nil || *task.Priority == "" {
-		task.Priority = globals.GetAddress(models.Medium) // Default priority
-	}
+	// The caller owns what they create
+	task.OwnerID, task.CreatedBy, task.UpdatedBy = callerID(c), callerID(c), callerID(c)
 
 	// Create task
 	err := database.CreateTask(&task)
+	if errors.Is(err, workflow.ErrIllegalTransition) || errors.Is(err, recurrence.ErrInvalidRule) || isHierarchyError(err) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
@@ -63,92 +70,301 @@ func CreateTask(c *gin.Context) {
 	c.JSON(http.StatusCreated, models.SuccessMessage{Message: "Task Created Succesfully"})
 }
 
+// validateTask checks a task as written by a client, on create and on every
+// kind of update, and fills in the defaults of the optional fields.
+func validateTask(task *models.Task) error {
+	var missing []string
+	if task.Title == "" {
+		missing = append(missing, "title")
+	}
+	if task.DueDate.IsZero() {
+		missing = append(missing, "due_date")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	// Set default value for empty labels
+	if task.Labels == nil {
+		task.Labels = []string{}
+	}
+	if err := validateLabels(task.Labels); err != nil {
+		return err
+	}
+
+	// Without a priority the priority policy picks one
+	if task.Priority != nil && *task.Priority == "" {
+		task.Priority = nil
+	}
+	if task.Priority != nil && !globals.IsValidPriority(string(*task.Priority)) {
+		return fmt.Errorf("invalid priority: %s. Valid values are: %v", *task.Priority, globals.GetValidPriorityValues())
+	}
+	return nil
+}
+
+// validateLabels rejects labels containing a comma, which separates the
+// labels of a task in a CSV export and import.
+func validateLabels(labels []string) error {
+	for _, label := range labels {
+		if strings.Contains(label, ",") {
+			return fmt.Errorf("invalid label %q: labels cannot contain commas", label)
+		}
+	}
+	return nil
+}
+
 // GetAllTasks godoc
 // @Summary Get all tasks
-// @Description Get a list of all tasks in the system
+// @Description Get a filtered, sorted page of the tasks the caller may see: their own, or every task for admins. Further pages are linked from the Link header (rel="next" / rel="prev").
 // @Tags tasks
 // @Produce json
+// @Param priority query string false "Comma-separated priorities" Enums(Low, Medium, High)
+// @Param label query []string false "Label to match (repeat for several)" collectionFormat(multi)
+// @Param label_match query string false "Match any or all of the labels" Enums(any, all)
+// @Param overdue query bool false "Only overdue (true) or not overdue (false) tasks"
+// @Param due_before query string false "Due strictly before (RFC 3339)"
+// @Param due_after query string false "Due strictly after (RFC 3339)"
+// @Param created_before query string false "Created strictly before (RFC 3339)"
+// @Param created_after query string false "Created strictly after (RFC 3339)"
+// @Param q query string false "Case-insensitive text match on title or description"
+// @Param parent_id query string false "Only direct subtasks of this task"
+// @Param project query string false "Only tasks of this project"
+// @Param sort query string false "Sort field" Enums(priority, due_date, created_at, updated_at, title, description, is_overdue, id)
+// @Param order query string false "Sort order" Enums(asc, desc)
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param cursor query string false "Opaque cursor taken from a Link header"
+// @Param If-None-Match header string false "ETag of a previous response; answered with 304 while the page is unchanged"
 // @Success 200 {array} models.Task
+// @Success 304 {string} string "The page is unchanged"
+// @Header 200 {string} Link "Next and previous page URLs"
+// @Header 200 {string} ETag "Digest of the page"
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
 // @Router /tasks [get]
 func GetAllTasks(c *gin.Context) {
 	logger := globals.Logger
-	// Call the function to get tasks
-	tasks, err := database.GetTasks(logger)
+	query, err := models.ParseTaskQuery(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	query.VisibleTo = middleware.CurrentIdentity(c)
+
+	page, err := database.ListTasks(logger, query)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 	if err != nil {
 		// If there's an error, return 500 with the error message
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch tasks: " + err.Error()})
 		return
 	}
-	logger.Info("GetAllTasks", "tasks", tasks)
+
+	setPaginationLinks(c, page)
 	// Return the list of tasks as a JSON response with status 200
-	c.JSON(http.StatusOK, tasks)
+	respondWithETag(c, page.Tasks)
+}
+
+// setPaginationLinks adds an RFC 8288 Link header pointing at the next and
+// previous pages. Every other query parameter of the request is preserved.
+func setPaginationLinks(c *gin.Context, page *database.TaskPage) {
+	var links []string
+	for _, link := range []struct{ rel, cursor string }{{"next", page.NextCursor}, {"prev", page.PrevCursor}} {
+		if link.cursor == "" {
+			continue
+		}
+		u := *c.Request.URL
+		values := u.Query()
+		values.Set("cursor", link.cursor)
+		u.RawQuery = values.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), link.rel))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
 }
 
 // GetTaskByID godoc
 // @Summary Get task by ID
-// @Description Get task details by task ID
+// @Description Get task details by task ID. Tasks with subtasks carry a rollup of their children; with tree=true every descendant is nested under children.
 // @Tags tasks
 // @Produce json
 // @Param id path string true "Task ID"
+// @Param tree query bool false "Include the whole subtask hierarchy"
+// @Param If-None-Match header string false "ETag of a previous response; answered with 304 while the task is unchanged"
 // @Success 200 {object} models.Task
+// @Success 304 {string} string "The task is unchanged"
+// @Header 200 {string} ETag "Version and effective priority of the task; send it as If-Match to update or delete it"
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
 // @Router /tasks/{id} [get]
 func GetTaskByID(c *gin.Context) {
 	taskID := c.Param("id")
-	task, err := database.GetTaskByID(taskID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+	task, ok := authorizeTask(c, taskID, policy.Read)
+	if !ok {
+		return
+	}
+	if c.Query("tree") == "true" {
+		tree, err := database.GetTaskTree(taskID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+			return
+		}
+		pruneTree(c, tree)
+		respondWithETag(c, tree)
 		return
 	}
 
+	if notModified(c, taskETag(task)) {
+		return
+	}
 	c.JSON(http.StatusOK, task)
 }
 
 // UpdateTask godoc
-// @Summary Update an existing task
-// @Description Update task details by task ID
+// @Summary Replace an existing task
+// @Description Replace the writable fields of a task: title and due_date are required, and omitted optional fields (description, labels, parent_id, recurrence, project) are cleared. Use PATCH to change single fields. For recurring tasks, scope=series also copies the title, description, priority, labels and recurrence to every open occurrence of the series.
 // @Tags tasks
 // @Accept json
 // @Produce json
 // @Param id path string true "Task ID"
+// @Param scope query string false "Edit this occurrence or the whole series" Enums(occurrence, series)
+// @Param If-Match header string false "ETag the task must still have"
 // @Param task body models.Task true "Task data"
 // @Success 200 {object} models.Task
+// @Header 200 {string} ETag "New version and effective priority of the task"
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse "The caller may not change the task"
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse "The task was changed since it was read (If-Match)"
 // @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
 // @Router /tasks/{id} [put]
 func UpdateTask(c *gin.Context) {
 	taskId := c.Param("id")
+	scope, err := database.ParseSeriesScope(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 	var task *models.Task
 	if err := c.ShouldBindJSON(&task); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	if task == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "request body must be a task object"})
+		return
+	}
+	if err := validateTask(task); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	existing, ok := authorizeTask(c, taskId, policy.Update)
+	if !ok {
+		return
+	}
+	if task.Version, ok = checkIfMatch(c, existing); !ok {
+		return
+	}
+	saveTask(c, existing, task, scope)
+}
+
+// saveTask stores task, the new state of existing as sent with PUT or
+// produced by PATCH, and writes the response. A non-zero task.Version makes
+// the write conditional (see checkIfMatch).
+func saveTask(c *gin.Context, existing, task *models.Task, scope database.SeriesScope) {
+	taskId := existing.ID
+	if !checkParentVisible(c, task.ParentID) {
+		return
+	}
+	// Moving a task to another project needs the right to create tasks there
+	if task.Project != existing.Project && !authorize(c, policy.Create, task) {
+		return
+	}
+	task.UpdatedBy = callerID(c)
 
 	// Update task
-	updatedTask, err := database.UpdateTask(taskId, task)
+	var updatedTask *models.Task
+	var err error
+	if scope == database.ScopeSeries {
+		updatedTask, err = database.UpdateTaskSeries(taskId, task)
+	} else {
+		updatedTask, err = database.UpdateTask(taskId, task)
+	}
+	if errors.Is(err, database.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		return
+	}
+	if errors.Is(err, database.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, recurrence.ErrInvalidRule) || isHierarchyError(err) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	c.Header("ETag", taskETag(updatedTask))
 	c.JSON(http.StatusOK, updatedTask)
 }
 
 // DeleteTask godoc
 // @Summary Delete a task
-// @Description Delete a task by its ID
+// @Description Delete a task by its ID. Its subtasks are moved up to its parent (reparent, the default) or deleted with it (cascade). Deleting an occurrence of a recurring task skips it; scope=series deletes every open occurrence and ends the series.
 // @Tags tasks
 // @Param id path string true "Task ID"
+// @Param children query string false "What happens to subtasks" Enums(reparent, cascade)
+// @Param scope query string false "Delete this occurrence or the whole series" Enums(occurrence, series)
+// @Param If-Match header string false "ETag the task must still have"
 // @Success 200 {object} models.SuccessMessage
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse "Only the owner or an admin can delete the task"
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse "The task was changed since it was read (If-Match)"
 // @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
 // @Router /tasks/{id} [delete]
 func DeleteTask(c *gin.Context) {
 	taskId := c.Param("id")
-	err := database.DeleteTask(taskId)
+	mode, err := database.ParseDeleteMode(c.Query("children"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	scope, err := database.ParseSeriesScope(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	existing, ok := authorizeTask(c, taskId, policy.Delete)
+	if !ok {
+		return
+	}
+	version, ok := checkIfMatch(c, existing)
+	if !ok {
+		return
+	}
+	if scope == database.ScopeSeries {
+		err = database.DeleteTaskSeries(taskId, mode, version)
+	} else {
+		err = database.DeleteTask(taskId, mode, version)
+	}
+	if errors.Is(err, database.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		return
+	}
+	if errors.Is(err, database.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+	"github.com/iabdulzahid/golang_task_manager/internal/recurrence"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
+)
+
+// bulkItem is one operation of a bulk request on its way through. A non-zero
+// result.Status before the operation is applied means it failed its checks.
+type bulkItem struct {
+	op      models.BulkOperation
+	task    *models.Task // create and update
+	mode    database.DeleteMode
+	version int64 // From if_match; 0 for unconditional writes
+	by      *string
+	result  models.BulkResult
+}
+
+// BulkTasks godoc
+// @Summary Apply several task changes at once
+// @Description Create, update (full replacement, as PUT), delete or relabel up to 100 tasks in one request. In atomic mode (the default) either every operation is applied or none is: the response has the status of the first failing operation and the others report 424. In best_effort mode each operation stands on its own and the response is 207 when any failed. Results come in request order. A bulk request counts as one request per operation against the rate limit. Recurring tasks are changed one occurrence at a time.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param request body models.BulkRequest true "Operations"
+// @Success 200 {object} models.BulkResponse "Every operation succeeded"
+// @Success 207 {object} models.BulkResponse "best_effort: some operations failed"
+// @Failure 400 {object} models.ErrorResponse "Malformed request, or (atomic) an operation was invalid"
+// @Failure 403 {object} models.BulkResponse "atomic: the caller may not perform an operation"
+// @Failure 404 {object} models.BulkResponse "atomic: a task was not found"
+// @Failure 412 {object} models.BulkResponse "atomic: a task was changed since it was read (if_match)"
+// @Failure 429 {object} models.ErrorResponse "Not enough rate limit left for every operation"
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/bulk [post]
+func BulkTasks(c *gin.Context) {
+	var request models.BulkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if request.Mode == "" {
+		request.Mode = models.BulkAtomic
+	}
+	if request.Mode != models.BulkAtomic && request.Mode != models.BulkBestEffort {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("invalid mode: %s. Valid values are: [%s %s]", request.Mode, models.BulkAtomic, models.BulkBestEffort)})
+		return
+	}
+	if len(request.Operations) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "operations must not be empty"})
+		return
+	}
+	if len(request.Operations) > models.MaxBulkOperations {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("too many operations: %d. At most %d are allowed", len(request.Operations), models.MaxBulkOperations)})
+		return
+	}
+	// The rate limiter already counted the request itself
+	if !middleware.ChargeRateLimit(c, len(request.Operations)-1) {
+		return
+	}
+
+	items := make([]bulkItem, len(request.Operations))
+	for i, op := range request.Operations {
+		items[i] = prepareBulkOperation(c, i, op)
+	}
+
+	status := http.StatusOK
+	if request.Mode == models.BulkAtomic {
+		var err error
+		if status, err = applyAtomically(items); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	} else {
+		for i := range items {
+			if items[i].result.Status == 0 {
+				applyBulkOperation(database.DirectWriter, &items[i])
+			}
+			if items[i].result.Status >= 400 {
+				status = http.StatusMultiStatus
+			}
+		}
+	}
+
+	response := models.BulkResponse{Mode: request.Mode, Results: make([]models.BulkResult, len(items))}
+	for i, item := range items {
+		response.Results[i] = item.result
+		if item.result.Status < 400 {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	c.JSON(status, response)
+}
+
+// applyAtomically applies every operation in one transaction, unless one of
+// them failed its checks already. It returns the status of the response: that
+// of the first failing operation, whose siblings are then marked 424. The
+// error is set when the transaction itself could not be completed.
+func applyAtomically(items []bulkItem) (int, error) {
+	failed, rolledBack := -1, false
+	for i := range items {
+		if items[i].result.Status != 0 {
+			failed = i
+			break
+		}
+	}
+	if failed < 0 {
+		rolledBack = true
+		err := database.InTransaction(func(w database.TaskWriter) error {
+			for i := range items {
+				if !applyBulkOperation(w, &items[i]) {
+					failed = i
+					return errors.New(items[i].result.Error)
+				}
+			}
+			return nil
+		})
+		if err != nil && failed < 0 {
+			log.Printf("Bulk transaction failed: %v\n", err)
+			return 0, err
+		}
+	}
+	if failed < 0 {
+		return http.StatusOK, nil
+	}
+
+	for i := range items {
+		result := &items[i].result
+		// Operations that failed their own checks keep their error
+		if i == failed || (!rolledBack && result.Status != 0) {
+			continue
+		}
+		result.Status = http.StatusFailedDependency
+		result.Error = fmt.Sprintf("not applied: operation %d failed", failed)
+		result.Task = nil
+		if items[i].op.Op == models.BulkCreate {
+			result.ID = ""
+		}
+	}
+	return items[failed].result.Status, nil
+}
+
+// prepareBulkOperation validates an operation and checks that the caller may
+// perform it, like the single-task endpoints do.
+func prepareBulkOperation(c *gin.Context, index int, op models.BulkOperation) bulkItem {
+	item := bulkItem{op: op, by: callerID(c), result: models.BulkResult{Index: index, Op: op.Op, ID: op.ID}}
+	fail := func(status int, message string) bulkItem {
+		item.result.Status, item.result.Error = status, message
+		return item
+	}
+
+	var existing *models.Task
+	switch op.Op {
+	case models.BulkCreate:
+		if op.ID != "" {
+			return fail(http.StatusBadRequest, "create does not take an id; the server assigns it")
+		}
+	case models.BulkUpdate, models.BulkDelete, models.BulkRelabel:
+		if op.ID == "" {
+			return fail(http.StatusBadRequest, op.Op+" needs the id of a task")
+		}
+		action := policy.Update
+		if op.Op == models.BulkDelete {
+			action = policy.Delete
+		}
+		var status int
+		var message string
+		if existing, status, message = loadTaskFor(c, op.ID, action); status != 0 {
+			return fail(status, message)
+		}
+		var err error
+		if item.version, err = ifMatchVersion(op.IfMatch, existing); err != nil {
+			return fail(http.StatusPreconditionFailed, err.Error())
+		}
+	default:
+		return fail(http.StatusBadRequest, fmt.Sprintf("invalid op: %s. Valid values are: [%s %s %s %s]", op.Op, models.BulkCreate, models.BulkUpdate, models.BulkDelete, models.BulkRelabel))
+	}
+
+	switch op.Op {
+	case models.BulkCreate, models.BulkUpdate:
+		if op.Task == nil {
+			return fail(http.StatusBadRequest, op.Op+" needs a task")
+		}
+		task := *op.Task
+		if err := validateTask(&task); err != nil {
+			return fail(http.StatusBadRequest, err.Error())
+		}
+		if !parentVisible(c, task.ParentID) {
+			return fail(http.StatusBadRequest, database.ErrParentNotFound.Error()+": "+*task.ParentID)
+		}
+		// Creating a task, or moving one to another project, needs the right to create tasks there
+		if existing == nil || task.Project != existing.Project {
+			if status, message := checkAccess(c, policy.Create, &task); status != 0 {
+				return fail(status, message)
+			}
+		}
+		if existing == nil {
+			task.OwnerID, task.CreatedBy = item.by, item.by
+		}
+		task.UpdatedBy, task.Version = item.by, item.version
+		item.task = &task
+	case models.BulkDelete:
+		mode, err := database.ParseDeleteMode(op.Children)
+		if err != nil {
+			return fail(http.StatusBadRequest, err.Error())
+		}
+		item.mode = mode
+	case models.BulkRelabel:
+		if len(op.AddLabels) == 0 && len(op.RemoveLabels) == 0 {
+			return fail(http.StatusBadRequest, "relabel needs add_labels or remove_labels")
+		}
+		if err := validateLabels(op.AddLabels); err != nil {
+			return fail(http.StatusBadRequest, err.Error())
+		}
+	}
+	return item
+}
+
+// applyBulkOperation applies a prepared operation with w and fills in its
+// result. It reports whether the operation succeeded.
+func applyBulkOperation(w database.TaskWriter, item *bulkItem) bool {
+	result := &item.result
+	var err error
+	switch item.op.Op {
+	case models.BulkCreate:
+		if err = w.CreateTask(item.task); err == nil {
+			result.Status, result.ID, result.Task = http.StatusCreated, item.task.ID, item.task
+		}
+	case models.BulkUpdate:
+		if result.Task, err = w.UpdateTask(item.op.ID, item.task); err == nil {
+			result.Status = http.StatusOK
+		}
+	case models.BulkDelete:
+		if err = w.DeleteTask(item.op.ID, item.mode, item.version); err == nil {
+			result.Status = http.StatusOK
+		}
+	case models.BulkRelabel:
+		if result.Task, err = w.RelabelTask(item.op.ID, item.op.AddLabels, item.op.RemoveLabels, item.version, item.by); err == nil {
+			result.Status = http.StatusOK
+		}
+	}
+	if err != nil {
+		result.Status, result.Error, result.Task = bulkErrorStatus(err), err.Error(), nil
+		return false
+	}
+	return true
+}
+
+// bulkErrorStatus is the status the single-task endpoints answer err with.
+func bulkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, workflow.ErrIllegalTransition), errors.Is(err, recurrence.ErrInvalidRule), isHierarchyError(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
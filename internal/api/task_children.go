@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+	"github.com/iabdulzahid/golang_task_manager/pkg/globals"
+)
+
+// GetTaskChildren godoc
+// @Summary List the subtasks of a task
+// @Description Get the direct children of a task. Accepts the same filter, sort and pagination parameters as GET /tasks.
+// @Tags tasks
+// @Produce json
+// @Param id path string true "Parent task ID"
+// @Param status query string false "Comma-separated statuses"
+// @Param sort query string false "Sort field" Enums(priority, due_date, created_at, updated_at, title, description, status, is_overdue, id)
+// @Param order query string false "Sort order" Enums(asc, desc)
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param cursor query string false "Opaque cursor taken from a Link header"
+// @Success 200 {array} models.Task
+// @Header 200 {string} Link "Next and previous page URLs"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id}/children [get]
+func GetTaskChildren(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, ok := authorizeTask(c, taskID, policy.Read); !ok {
+		return
+	}
+
+	query, err := models.ParseTaskQuery(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	query.ParentID = taskID
+	query.VisibleTo = middleware.CurrentIdentity(c)
+
+	page, err := database.ListTasks(globals.Logger, query)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch subtasks: " + err.Error()})
+		return
+	}
+
+	setPaginationLinks(c, page)
+	c.JSON(http.StatusOK, page.Tasks)
+}
+
+// isHierarchyError reports errors caused by a bad parent_id in the request.
+func isHierarchyError(err error) bool {
+	return errors.Is(err, database.ErrParentNotFound) || errors.Is(err, database.ErrHierarchyCycle)
+}
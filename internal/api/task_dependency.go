@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+)
+
+// AddTaskDependency godoc
+// @Summary Add a dependency
+// @Description Record that the task cannot start or finish until depends_on_id is finished. Edges that would close a cycle are rejected.
+// @Tags dependencies
+// @Accept json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param dependency body models.DependencyRequest true "Task this task waits for"
+// @Success 201 {object} models.Dependency
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse "The caller may not change the task"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Duplicate edge or dependency cycle"
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id}/dependencies [post]
+func AddTaskDependency(c *gin.Context) {
+	taskID := c.Param("id")
+	var request models.DependencyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if _, ok := authorizeTask(c, taskID, policy.Update); !ok {
+		return
+	}
+	if dependsOn, err := database.GetTaskByID(request.DependsOnID); err == nil && !canSee(c, *dependsOn) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: database.ErrDependencyTaskNotFound.Error() + ": " + request.DependsOnID})
+		return
+	}
+
+	dep, err := database.AddDependency(taskID, request.DependsOnID)
+	switch {
+	case errors.Is(err, database.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		return
+	case errors.Is(err, database.ErrDependencyTaskNotFound):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, database.ErrDependencyExists), errors.Is(err, database.ErrDependencyCycle):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dep)
+}
+
+// GetTaskDependencies godoc
+// @Summary List dependencies
+// @Description List the tasks this task waits for (blocked_by) and the tasks waiting for it (blocks).
+// @Tags dependencies
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {object} models.TaskDependencies
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id}/dependencies [get]
+func GetTaskDependencies(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, ok := authorizeTask(c, taskID, policy.Read); !ok {
+		return
+	}
+	deps, err := database.GetTaskDependencies(taskID)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deps)
+}
+
+// RemoveTaskDependency godoc
+// @Summary Remove a dependency
+// @Description Delete the edge between the task and depends_on_id.
+// @Tags dependencies
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param depends_on_id path string true "ID of the task it waits for"
+// @Success 200 {object} models.SuccessMessage
+// @Failure 403 {object} models.ErrorResponse "The caller may not change the task"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id}/dependencies/{depends_on_id} [delete]
+func RemoveTaskDependency(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, ok := authorizeTask(c, taskID, policy.Update); !ok {
+		return
+	}
+	err := database.RemoveDependency(taskID, c.Param("depends_on_id"))
+	if errors.Is(err, database.ErrDependencyNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Dependency not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessMessage{Message: "Dependency removed"})
+}
+
+// GetTaskBlockers godoc
+// @Summary List open blockers
+// @Description List the direct dependencies of a task that are not finished yet (not in a terminal status). The task cannot be started while this list is non-empty.
+// @Tags dependencies
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {array} models.Task
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id}/blockers [get]
+func GetTaskBlockers(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, ok := authorizeTask(c, taskID, policy.Read); !ok {
+		return
+	}
+	blockers, err := database.GetBlockers(taskID)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, visibleTasks(c, blockers))
+}
+
+// GetCriticalPath godoc
+// @Summary Get the critical path
+// @Description Get the chain of open, dependent tasks that takes the longest to get through, measured by the gaps between their due dates. Ties go to the longer chain. A task without dependencies is a chain of its own; the path is empty only when no task is open. Only the tasks the caller may see are considered.
+// @Tags dependencies
+// @Produce json
+// @Success 200 {object} models.CriticalPath
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/critical-path [get]
+func GetCriticalPath(c *gin.Context) {
+	path, err := database.CriticalPath(middleware.CurrentIdentity(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, path)
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/export"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+)
+
+// Import modes
+const (
+	importCreate = "create" // Every row becomes a new task
+	importUpsert = "upsert" // Rows naming an existing task update it
+)
+
+// ImportTasks godoc
+// @Summary Import tasks from JSON or CSV
+// @Description Read tasks in the formats GET /tasks/export writes and save them row by row as the input streams in, so files of any size can be imported. With mode=create (the default) every row becomes a new task owned by the caller and ids are ignored. With mode=upsert a row whose id names an existing task replaces it like PUT, and a row with an unknown id is created under that id. Invalid rows are reported with their line and skipped; the other rows are still imported. With dry_run=true nothing is saved; rows are checked against the tasks as they are, so a parent created by an earlier row of the same file is not known yet.
+// @Tags tasks
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param format query string false "Input format; by default taken from the Content-Type" Enums(json, csv)
+// @Param mode query string false "What rows with an id do" Enums(create, upsert)
+// @Param dry_run query bool false "Check the rows without saving them"
+// @Param tasks body []models.Task true "Tasks as exported"
+// @Success 200 {object} models.ImportResult
+// @Failure 400 {object} models.ErrorResponse "Invalid parameters, or an input that cannot be read (then with the partial models.ImportResult)"
+// @Security ApiKeyAuth
+// @Router /tasks/import [post]
+func ImportTasks(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = "json"
+		if strings.HasPrefix(c.ContentType(), "text/csv") {
+			format = "csv"
+		}
+	}
+	mode := c.DefaultQuery("mode", importCreate)
+	if mode != importCreate && mode != importUpsert {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("invalid mode: %s. Valid values are: [%s %s]", mode, importCreate, importUpsert)})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	reader, err := export.NewTaskReader(format, c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result := models.ImportResult{Mode: mode, DryRun: dryRun, Errors: []models.ImportError{}}
+	report := func(line int, id, message string) {
+		result.Failed++
+		if len(result.Errors) == models.MaxImportErrors {
+			result.ErrorsTruncated = true
+			return
+		}
+		result.Errors = append(result.Errors, models.ImportError{Line: line, ID: id, Error: message})
+	}
+	for {
+		task, line, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *export.RowError
+		if errors.As(err, &rowErr) {
+			result.Rows++
+			report(rowErr.Line, "", rowErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			// Nothing after this point can be read
+			result.Incomplete = true
+			result.Errors = append(result.Errors, models.ImportError{Line: line, Error: err.Error()})
+			c.JSON(http.StatusBadRequest, result)
+			return
+		}
+
+		result.Rows++
+		if mode == importCreate {
+			task.ID = ""
+		}
+		updated, err := importTask(c, task, dryRun)
+		switch {
+		case err != nil:
+			report(line, task.ID, err.Error())
+		case updated:
+			result.Updated++
+		default:
+			result.Created++
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// importTask saves one row of an import, with the checks of POST and PUT
+// /tasks. It reports whether an existing task was updated rather than created.
+func importTask(c *gin.Context, task *models.Task, dryRun bool) (bool, error) {
+	if err := validateTask(task); err != nil {
+		return false, err
+	}
+	if !parentVisible(c, task.ParentID) {
+		return false, fmt.Errorf("%v: %s", database.ErrParentNotFound, *task.ParentID)
+	}
+
+	var existing *models.Task
+	if task.ID != "" {
+		if _, err := uuid.Parse(task.ID); err != nil {
+			return false, fmt.Errorf("invalid id %q: %v", task.ID, err)
+		}
+		var status int
+		var message string
+		existing, status, message = loadTaskFor(c, task.ID, policy.Update)
+		if status != 0 && status != http.StatusNotFound {
+			return false, errors.New(message)
+		}
+		if status == http.StatusNotFound {
+			// A task the caller may not see must not be overwritten by a create either
+			if _, err := database.GetTaskByID(task.ID); !errors.Is(err, database.ErrTaskNotFound) {
+				return false, errors.New(message)
+			}
+		}
+	}
+	// Creating a task, or moving one to another project, needs the right to create tasks there
+	if existing == nil || task.Project != existing.Project {
+		if status, message := checkAccess(c, policy.Create, task); status != 0 {
+			return false, errors.New(message)
+		}
+	}
+
+	task.UpdatedBy, task.Version = callerID(c), 0
+	if existing != nil {
+		if dryRun {
+			return true, database.CheckTask(task.ID, *task)
+		}
+		_, err := database.UpdateTask(task.ID, task)
+		return true, err
+	}
+	task.OwnerID, task.CreatedBy = callerID(c), callerID(c)
+	if dryRun {
+		return false, database.CheckTask("", *task)
+	}
+	return false, database.CreateTaskWithID(task)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// defaultNextLimit is how many tasks GET /tasks/next returns without a limit.
+const defaultNextLimit = 10
+
+// GetNextTasks godoc
+// @Summary Get the tasks to work on next
+// @Description Get the open tasks the caller may see, most urgent first, each with its urgency score and the terms it adds up from. Like Taskwarrior's urgency, the score weighs the priority, how near (or past) the due date is, the task's age, how many open tasks wait for it, whether it waits for an open task itself, and its labels. The weights are set with URGENCY_WEIGHTS and URGENCY_LABEL_WEIGHTS. The filters of GET /tasks narrow the candidates.
+// @Tags tasks
+// @Produce json
+// @Param limit query int false "Number of tasks (default 10, max 1000)"
+// @Param priority query string false "Comma-separated priorities" Enums(Low, Medium, High)
+// @Param status query string false "Comma-separated statuses"
+// @Param label query []string false "Label to match (repeat for several)" collectionFormat(multi)
+// @Param label_match query string false "Match any or all of the labels" Enums(any, all)
+// @Param overdue query bool false "Only overdue (true) or not overdue (false) tasks"
+// @Param due_before query string false "Due strictly before (RFC 3339)"
+// @Param due_after query string false "Due strictly after (RFC 3339)"
+// @Param created_before query string false "Created strictly before (RFC 3339)"
+// @Param created_after query string false "Created strictly after (RFC 3339)"
+// @Param q query string false "Case-insensitive text match on title or description"
+// @Param parent_id query string false "Only direct subtasks of this task"
+// @Param project query string false "Only tasks of this project"
+// @Success 200 {array} models.Task
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/next [get]
+func GetNextTasks(c *gin.Context) {
+	filter, err := models.ParseTaskFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	filter.VisibleTo = middleware.CurrentIdentity(c)
+
+	limit := defaultNextLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > models.MaxPageSize {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("invalid limit: %s. Use a number between 1 and %d", raw, models.MaxPageSize)})
+			return
+		}
+	}
+
+	tasks, err := database.NextTasks(filter, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
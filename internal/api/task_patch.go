@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/patch"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+)
+
+// patchableFields are the members of a task a PATCH may change: the same
+// fields a PUT replaces. Everything else is maintained by the server or
+// changed through its own endpoint, e.g. status through transitions.
+var patchableFields = []string{"title", "description", "priority", "due_date", "labels", "parent_id", "recurrence", "project"}
+
+// PatchTask godoc
+// @Summary Change single fields of a task
+// @Description Apply a JSON Merge Patch (RFC 7396; Content-Type application/merge-patch+json or application/json) or a JSON Patch (RFC 6902; Content-Type application/json-patch+json) to the writable fields of a task: title, description, priority, due_date, labels, parent_id, recurrence and project. The patched task is validated like a PUT. For recurring tasks, scope=series also copies the title, description, priority, labels and recurrence to every open occurrence of the series.
+// @Tags tasks
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param scope query string false "Edit this occurrence or the whole series" Enums(occurrence, series)
+// @Param If-Match header string false "ETag the task must still have"
+// @Param patch body object true "Merge patch object or array of JSON Patch operations"
+// @Success 200 {object} models.Task
+// @Header 200 {string} ETag "New version of the task"
+// @Failure 400 {object} models.ErrorResponse "Malformed patch, read-only field or invalid resulting task"
+// @Failure 403 {object} models.ErrorResponse "The caller may not change the task"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "A JSON Patch test operation failed"
+// @Failure 412 {object} models.ErrorResponse "The task was changed since it was read (If-Match)"
+// @Failure 415 {object} models.ErrorResponse "Unsupported patch format"
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id} [patch]
+func PatchTask(c *gin.Context) {
+	taskID := c.Param("id")
+	scope, err := database.ParseSeriesScope(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	contentType := c.ContentType()
+	if contentType != patch.MergePatchType && contentType != patch.JSONPatchType && contentType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{
+			Error: fmt.Sprintf("unsupported patch format %q; use %s or %s", contentType, patch.MergePatchType, patch.JSONPatchType),
+		})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	existing, ok := authorizeTask(c, taskID, policy.Update)
+	if !ok {
+		return
+	}
+	version, ok := checkIfMatch(c, existing)
+	if !ok {
+		return
+	}
+	doc, err := patchableDocument(existing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if contentType == patch.JSONPatchType {
+		var ops []patch.Operation
+		if ops, err = patch.ParseJSONPatch(body); err == nil {
+			doc, err = patch.ApplyJSONPatch(doc, ops)
+		}
+	} else {
+		var mergePatch interface{}
+		if mergePatch, err = patch.Decode(body); err != nil {
+			err = fmt.Errorf("%w: %v", patch.ErrInvalidPatch, err)
+		} else if _, isObject := mergePatch.(map[string]interface{}); !isObject {
+			err = fmt.Errorf("%w: a merge patch for a task must be an object", patch.ErrInvalidPatch)
+		} else {
+			doc = patch.MergePatch(doc, mergePatch)
+		}
+	}
+	switch {
+	case errors.Is(err, patch.ErrTestFailed):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	task, err := taskFromDocument(doc)
+	if err == nil {
+		err = validateTask(task)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	task.Version = version
+	saveTask(c, existing, task, scope)
+}
+
+// patchableDocument returns the writable fields of task as a JSON object.
+func patchableDocument(task *models.Task) (interface{}, error) {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode task: %v", err)
+	}
+	full, err := patch.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode task: %v", err)
+	}
+	doc := map[string]interface{}{}
+	for _, name := range patchableFields {
+		doc[name] = full.(map[string]interface{})[name]
+	}
+	return doc, nil
+}
+
+// taskFromDocument reads a patched document back into a task, rejecting
+// members outside patchableFields.
+func taskFromDocument(doc interface{}) (*models.Task, error) {
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the patched task must be an object")
+	}
+	var unknown []string
+	for name := range object {
+		if !isPatchable(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("fields cannot be patched: %v; patchable fields are %v", unknown, patchableFields)
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	var task models.Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("invalid patched task: %v", err)
+	}
+	return &task, nil
+}
+
+func isPatchable(name string) bool {
+	for _, field := range patchableFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+)
+
+// ExplainTaskPriority godoc
+// @Summary Explain the priority of a task
+// @Description Show how the priority policy arrives at the task's current priority: whether a client chose it (pinned) and the policy respects that, and for every rule of the policy whether the task matches it and whether it changed the priority. decided_by names the rule that decided the priority, or "user" for a pinned priority, "default" when no rule applied and "status" for tasks in a terminal status, which keep theirs.
+// @Tags tasks
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {object} models.PriorityExplanation
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id}/priority [get]
+func ExplainTaskPriority(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, ok := authorizeTask(c, taskID, policy.Read); !ok {
+		return
+	}
+	explanation, err := database.ExplainTaskPriority(taskID)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, explanation)
+}
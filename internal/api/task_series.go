@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+)
+
+// GetTaskSeries godoc
+// @Summary List the occurrences of a recurring task
+// @Description Get every occurrence of the series the task belongs to, oldest first. A task that does not recur is returned on its own.
+// @Tags tasks
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {array} models.Task
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id}/series [get]
+func GetTaskSeries(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, ok := authorizeTask(c, taskID, policy.Read); !ok {
+		return
+	}
+	series, err := database.GetSeries(taskID)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, visibleTasks(c, series))
+}
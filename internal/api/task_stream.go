@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/stream"
+)
+
+// streamWriteTimeout bounds a WebSocket write, so a stalled client cannot hold the stream.
+const streamWriteTimeout = 10 * time.Second
+
+// The default upgrader only accepts same-origin browser connections
+var upgrader = websocket.Upgrader{}
+
+// StreamTaskChanges godoc
+// @Summary Follow task changes (Server-Sent Events)
+// @Description Stream the changes to the tasks the caller may see as Server-Sent Events, as they happen: created, updated, deleted, and overdue when the monitor flags a task. Each event's data is a models.TaskEvent, its event field the type and its id the event ID. The filters of GET /tasks narrow the tasks; an update taking a task out of them is sent as a left event. To resume after a disconnect, send the last event ID as the Last-Event-ID header (EventSource does so itself) or last_event_id parameter: the missed events follow, as long as the server still holds them. Otherwise a reset event asks the client to fetch the tasks again. Comments are sent as heartbeats while nothing changes. Only the changes made through this server are streamed, and overdue events only on the server running the background jobs.
+// @Tags tasks
+// @Produce text/event-stream
+// @Param last_event_id query string false "ID of the last event received, to resume after it"
+// @Param Last-Event-ID header string false "ID of the last event received, to resume after it"
+// @Param priority query string false "Comma-separated priorities" Enums(Low, Medium, High)
+// @Param status query string false "Comma-separated statuses"
+// @Param label query []string false "Label to match (repeat for several)" collectionFormat(multi)
+// @Param label_match query string false "Match any or all of the labels" Enums(any, all)
+// @Param overdue query bool false "Only overdue (true) or not overdue (false) tasks"
+// @Param due_before query string false "Due strictly before (RFC 3339)"
+// @Param due_after query string false "Due strictly after (RFC 3339)"
+// @Param created_before query string false "Created strictly before (RFC 3339)"
+// @Param created_after query string false "Created strictly after (RFC 3339)"
+// @Param q query string false "Case-insensitive text match on title or description"
+// @Param parent_id query string false "Only direct subtasks of this task"
+// @Param project query string false "Only tasks of this project"
+// @Success 200 {object} models.TaskEvent "A stream of events"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse "The change stream is not running"
+// @Security ApiKeyAuth
+// @Router /tasks/stream [get]
+func StreamTaskChanges(c *gin.Context) {
+	changes, filter, lastEventID, ok := streamRequest(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // Keep proxies such as nginx from buffering the stream
+	c.Status(http.StatusOK)
+	w := c.Writer
+	write := func(format string, args ...interface{}) error {
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	}
+	// Ask EventSource to reconnect after 3s; this also sends the headers at once
+	if write("retry: 3000\n\n") != nil {
+		return
+	}
+
+	send := func(event models.TaskEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return write("id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	}
+	heartbeat := func() error {
+		return write(": heartbeat\n\n")
+	}
+	followTasks(c.Request.Context(), changes, filter, lastEventID, send, heartbeat)
+}
+
+// StreamTaskChangesWebSocket godoc
+// @Summary Follow task changes (WebSocket)
+// @Description The stream of GET /tasks/stream over a WebSocket: every event is a text message holding a models.TaskEvent, including the reset event. It takes the same filters, and last_event_id to resume. The server pings while nothing changes and ignores the messages it receives.
+// @Tags tasks
+// @Param last_event_id query string false "ID of the last event received, to resume after it"
+// @Param priority query string false "Comma-separated priorities" Enums(Low, Medium, High)
+// @Param status query string false "Comma-separated statuses"
+// @Param label query []string false "Label to match (repeat for several)" collectionFormat(multi)
+// @Param label_match query string false "Match any or all of the labels" Enums(any, all)
+// @Param overdue query bool false "Only overdue (true) or not overdue (false) tasks"
+// @Param due_before query string false "Due strictly before (RFC 3339)"
+// @Param due_after query string false "Due strictly after (RFC 3339)"
+// @Param created_before query string false "Created strictly before (RFC 3339)"
+// @Param created_after query string false "Created strictly after (RFC 3339)"
+// @Param q query string false "Case-insensitive text match on title or description"
+// @Param parent_id query string false "Only direct subtasks of this task"
+// @Param project query string false "Only tasks of this project"
+// @Success 101 {object} models.TaskEvent "Switching to the WebSocket protocol"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse "The change stream is not running"
+// @Security ApiKeyAuth
+// @Router /tasks/stream/ws [get]
+func StreamTaskChangesWebSocket(c *gin.Context) {
+	changes, filter, lastEventID, ok := streamRequest(c)
+	if !ok {
+		return
+	}
+	// Upgrade answers a failed handshake itself
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// The client only sends control frames, but reading handles them and
+	// notices when the client goes away
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(event models.TaskEvent) error {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return conn.WriteJSON(event)
+	}
+	heartbeat := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+	}
+	followTasks(ctx, changes, filter, lastEventID, send, heartbeat)
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+		time.Now().Add(streamWriteTimeout))
+}
+
+// streamRequest reads the filters and resume point of a stream request. On
+// failure the response has been written and ok is false.
+func streamRequest(c *gin.Context) (changes *stream.Log, filter models.TaskFilter, lastEventID string, ok bool) {
+	changes = stream.Current()
+	if changes == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "The change stream is not running"})
+		return nil, filter, "", false
+	}
+	filter, err := models.ParseTaskFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return nil, filter, "", false
+	}
+	filter.VisibleTo = middleware.CurrentIdentity(c)
+
+	lastEventID = c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	return changes, filter, lastEventID, true
+}
+
+// followTasks sends the events matching filter, starting with the ones
+// missed since lastEventID, until ctx is done, the log ends the subscription
+// or a send fails. heartbeat is called while nothing is sent.
+func followTasks(ctx context.Context, changes *stream.Log, filter models.TaskFilter, lastEventID string,
+	send func(models.TaskEvent) error, heartbeat func() error) {
+	sub, missed, head, complete := changes.Subscribe(lastEventID)
+	defer sub.Close()
+
+	if !complete {
+		if send(models.TaskEvent{ID: head, Type: models.TaskEventReset, Time: models.Now()}) != nil {
+			return
+		}
+	}
+	for _, e := range missed {
+		if event, ok := e.For(filter); ok {
+			if send(event) != nil {
+				return
+			}
+		}
+	}
+
+	ticker := time.NewTicker(changes.Options().Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, open := <-sub.Events():
+			if !open {
+				return
+			}
+			if event, ok := e.For(filter); ok {
+				if send(event) != nil {
+					return
+				}
+				ticker.Reset(changes.Options().Heartbeat)
+			}
+		case <-ticker.C:
+			if heartbeat() != nil {
+				return
+			}
+		}
+	}
+}
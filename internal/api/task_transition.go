@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
+)
+
+// TransitionTask godoc
+// @Summary Change the status of a task
+// @Description Move a task to another status. Only moves allowed by the configured workflow are accepted; started_at and completed_at are maintained automatically. A task cannot be started or finished, only cancelled, while any of its dependencies is still open.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param transition body models.TransitionRequest true "Target status"
+// @Success 200 {object} models.Task
+// @Header 200 {string} ETag "New version and effective priority of the task"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse "The caller may not change the task"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Illegal transition, open dependencies or concurrent status change"
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tasks/{id}/transitions [post]
+func TransitionTask(c *gin.Context) {
+	taskID := c.Param("id")
+	var request models.TransitionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if _, ok := authorizeTask(c, taskID, policy.Update); !ok {
+		return
+	}
+
+	task, err := database.TransitionTask(taskID, request.Status, callerID(c))
+	switch {
+	case errors.Is(err, database.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		return
+	case errors.Is(err, workflow.ErrIllegalTransition), errors.Is(err, database.ErrStatusConflict),
+		errors.Is(err, database.ErrBlockedByDependency):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Header("ETag", taskETag(task))
+	c.JSON(http.StatusOK, task)
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minRefreshInterval bounds how often an unknown kid can force a refetch, so
+// tokens with made-up key IDs cannot hammer the JWKS endpoint.
+const minRefreshInterval = 10 * time.Second
+
+// maxJWKSSize caps the JWKS document read from a file or URL.
+const maxJWKSSize = 1 << 20
+
+// jsonWebKey is one entry of a JWKS document (RFC 7517). Only the members
+// needed for RSA, EC P-256 and symmetric signing keys are read.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// verificationKey is a parsed JWK together with the algorithm it may verify.
+type verificationKey struct {
+	kid string
+	alg string // empty: any algorithm matching the key type
+	key interface{}
+}
+
+// KeySet is a JWKS loaded from a file or an http(s) URL. Keys are cached and
+// reloaded once the cache is older than the refresh interval, or right away
+// when a token names a key ID the set does not have yet, which is how a
+// rotated-in key gets picked up. A failed reload keeps the previous keys.
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      []verificationKey
+	loadedAt  time.Time
+	attemptAt time.Time
+}
+
+// NewKeySet returns a KeySet reading source, a file path or an http(s) URL,
+// and reloading it every refresh. Nothing is loaded until the first lookup;
+// call Load to fail early on a bad source.
+func NewKeySet(source string, refresh time.Duration) *KeySet {
+	return &KeySet{source: source, refresh: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Load (re)reads the key set now.
+func (s *KeySet) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reload(time.Now())
+}
+
+// lookup returns the keys that may verify a token with the given kid and alg.
+// A token without kid may be verified by any key of the set.
+func (s *KeySet) lookup(kid, alg string) ([]verificationKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.keys == nil || now.Sub(s.loadedAt) >= s.refresh {
+		if err := s.reload(now); err != nil && s.keys == nil {
+			return nil, err
+		}
+	}
+	matches := s.match(kid, alg)
+	if len(matches) == 0 && kid != "" && now.Sub(s.attemptAt) >= minRefreshInterval {
+		if err := s.reload(now); err == nil {
+			matches = s.match(kid, alg)
+		}
+	}
+	return matches, nil
+}
+
+func (s *KeySet) match(kid, alg string) []verificationKey {
+	var matches []verificationKey
+	for _, key := range s.keys {
+		if (kid == "" || key.kid == kid) && (key.alg == "" || key.alg == alg) && keyFitsAlg(key.key, alg) {
+			matches = append(matches, key)
+		}
+	}
+	return matches
+}
+
+// reload must be called with s.mu held.
+func (s *KeySet) reload(now time.Time) error {
+	s.attemptAt = now
+	data, err := s.read()
+	if err == nil {
+		var keys []verificationKey
+		if keys, err = parseJWKS(data); err == nil {
+			s.keys, s.loadedAt = keys, now
+			return nil
+		}
+	}
+	err = fmt.Errorf("failed to load JWKS from %s: %v", s.source, err)
+	if s.keys != nil {
+		log.Printf("Keeping the %d cached JWKS key(s): %v\n", len(s.keys), err)
+	}
+	return err
+}
+
+func (s *KeySet) read() ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(s.source)
+	}
+	resp, err := s.client.Get(s.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// parseJWKS reads a JWKS document. Keys of unsupported types or meant for
+// encryption are skipped; a set without any usable key is an error.
+func parseJWKS(data []byte) ([]verificationKey, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %v", err)
+	}
+
+	keys := []verificationKey{}
+	for i, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %v", i, jwk.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		keys = append(keys, verificationKey{kid: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key material, or returns nil for key types this
+// package does not verify with.
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %v", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA keys must have at least 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %v", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %v", err)
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on P-256")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) < 32 {
+			return nil, fmt.Errorf("symmetric keys must be at least 256 bits of base64url")
+		}
+		return secret, nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("not a base64url number")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,295 @@
+// Package auth verifies the JWT bearer tokens issued by the API gateway.
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is wrapped by every verification failure.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Supported signing algorithms.
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+	HS256 = "HS256"
+)
+
+// Claims are the verified claims of a token that the API uses.
+type Claims struct {
+	Subject  string
+	Name     string
+	Issuer   string
+	Audience []string
+	Roles    []string
+}
+
+// Verifier checks the signature and the exp, nbf, iss and aud claims of a
+// token and extracts its Claims.
+type Verifier struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+	// RolesClaim names the claim holding the caller's roles. A dotted path
+	// reaches into nested objects, e.g. "realm_access.roles".
+	RolesClaim string
+	// Leeway is the clock skew tolerated on exp and nbf.
+	Leeway time.Duration
+	// Now is the clock; time.Now when nil.
+	Now func() time.Time
+}
+
+// VerifierFromEnv builds a Verifier from JWT_JWKS (a file path or an http(s)
+// URL), JWT_ISSUER, JWT_AUDIENCE, JWT_ROLES_CLAIM (default "roles"),
+// JWT_JWKS_REFRESH (default 5m) and JWT_LEEWAY (default 1m). It returns nil
+// when JWT_JWKS is not set.
+func VerifierFromEnv() (*Verifier, error) {
+	source := os.Getenv("JWT_JWKS")
+	if source == "" {
+		return nil, nil
+	}
+	v := &Verifier{
+		Issuer:     os.Getenv("JWT_ISSUER"),
+		Audience:   os.Getenv("JWT_AUDIENCE"),
+		RolesClaim: os.Getenv("JWT_ROLES_CLAIM"),
+		Leeway:     time.Minute,
+	}
+	if v.Issuer == "" || v.Audience == "" {
+		return nil, fmt.Errorf("JWT_ISSUER and JWT_AUDIENCE are required with JWT_JWKS")
+	}
+	if v.RolesClaim == "" {
+		v.RolesClaim = "roles"
+	}
+
+	refresh := 5 * time.Minute
+	for name, target := range map[string]*time.Duration{"JWT_JWKS_REFRESH": &refresh, "JWT_LEEWAY": &v.Leeway} {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("%s: %q is not a duration", name, value)
+			}
+			*target = d
+		}
+	}
+
+	v.Keys = NewKeySet(source, refresh)
+	if err := v.Keys.Load(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// LooksLikeJWT tells a compact JWS apart from an opaque API key.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks token and returns its claims. Errors wrap ErrInvalidToken.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		Typ string `json:"typ"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if header.Alg != RS256 && header.Alg != ES256 && header.Alg != HS256 {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature is not base64url", ErrInvalidToken)
+	}
+
+	keys, err := v.Keys.lookup(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if verifySignature(header.Alg, key.key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("%w: no %s key with kid %q", ErrInvalidToken, header.Alg, header.Kid)
+		}
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+	return v.checkClaims(claims)
+}
+
+func (v *Verifier) checkClaims(raw map[string]interface{}) (*Claims, error) {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	exp, ok, err := numericDate(raw, "exp")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: exp is required", ErrInvalidToken)
+	}
+	if !now.Before(exp.Add(v.Leeway)) {
+		return nil, fmt.Errorf("%w: token expired at %s", ErrInvalidToken, exp.UTC().Format(time.RFC3339))
+	}
+	if nbf, ok, err := numericDate(raw, "nbf"); err != nil {
+		return nil, err
+	} else if ok && now.Add(v.Leeway).Before(nbf) {
+		return nil, fmt.Errorf("%w: token not valid before %s", ErrInvalidToken, nbf.UTC().Format(time.RFC3339))
+	}
+
+	claims := &Claims{}
+	claims.Issuer, _ = raw["iss"].(string)
+	if claims.Issuer != v.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	}
+	claims.Audience = stringList(raw["aud"])
+	if aud, ok := raw["aud"].(string); ok {
+		claims.Audience = []string{aud}
+	}
+	if !contains(claims.Audience, v.Audience) {
+		return nil, fmt.Errorf("%w: token is not meant for audience %q", ErrInvalidToken, v.Audience)
+	}
+	claims.Subject, _ = raw["sub"].(string)
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: sub is required", ErrInvalidToken)
+	}
+
+	for _, name := range []string{"name", "preferred_username"} {
+		if claims.Name, _ = raw[name].(string); claims.Name != "" {
+			break
+		}
+	}
+	claims.Roles = stringList(lookupPath(raw, v.RolesClaim))
+	return claims, nil
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("not base64url")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+// keyFitsAlg keeps a key from being used with an algorithm of another family,
+// e.g. an RSA public key as an HS256 secret.
+func keyFitsAlg(key interface{}, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return alg == RS256
+	case *ecdsa.PublicKey:
+		return alg == ES256
+	case []byte:
+		return alg == HS256
+	}
+	return false
+}
+
+func verifySignature(alg string, key interface{}, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch alg {
+	case RS256:
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		// JWS encodes the signature as r || s, 32 bytes each
+		if len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.(*ecdsa.PublicKey), digest[:], r, s)
+	case HS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	return false
+}
+
+func numericDate(raw map[string]interface{}, name string) (time.Time, bool, error) {
+	value, ok := raw[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a number", ErrInvalidToken, name)
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a number", ErrInvalidToken, name)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true, nil
+}
+
+// lookupPath follows a dotted claim name into nested objects.
+func lookupPath(raw map[string]interface{}, path string) interface{} {
+	var value interface{} = raw
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// stringList reads a claim that is either a string or an array of strings.
+// A space-separated string (as in the OAuth scope claim) counts as a list.
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://issuer.example"
+	testAudience = "tasks"
+)
+
+var testNow = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+// testKeys are the signing keys of the tests and their JWKs.
+type testKeys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+var (
+	keysOnce sync.Once
+	keys     testKeys
+)
+
+func signingKeys(t *testing.T) testKeys {
+	t.Helper()
+	keysOnce.Do(func() {
+		var err error
+		if keys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			panic(err)
+		}
+		if keys.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			panic(err)
+		}
+		keys.secret = []byte("0123456789abcdef0123456789abcdef")
+	})
+	return keys
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	params := key.Curve.Params()
+	size := (params.BitSize + 7) / 8
+	return map[string]string{"kty": "EC", "kid": kid, "crv": params.Name, "x": b64(key.X.FillBytes(make([]byte, size))), "y": b64(key.Y.FillBytes(make([]byte, size)))}
+}
+
+func octJWK(kid string, secret []byte) map[string]string {
+	return map[string]string{"kty": "oct", "kid": kid, "k": b64(secret)}
+}
+
+func jwks(t *testing.T, jwks ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"keys": jwks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// sign builds a compact JWS of claims; key is a private key, or the secret for HS256.
+func sign(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, k, digest[:]); err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case nil:
+	default:
+		t.Fatalf("unsupported key %T", key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss": testIssuer, "aud": testAudience, "sub": "user-1", "name": "Alice",
+		"exp": testNow.Add(time.Hour).Unix(), "roles": []string{"viewer"},
+	}
+}
+
+func withClaims(changes map[string]interface{}) map[string]interface{} {
+	claims := validClaims()
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+// newVerifier returns a Verifier over a JWKS file holding data.
+func newVerifier(t *testing.T, data []byte) *Verifier {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &Verifier{
+		Keys:       NewKeySet(path, time.Hour),
+		Issuer:     testIssuer,
+		Audience:   testAudience,
+		RolesClaim: "roles",
+		Leeway:     time.Minute,
+		Now:        func() time.Time { return testNow },
+	}
+}
+
+func TestVerify(t *testing.T) {
+	k := signingKeys(t)
+	v := newVerifier(t, jwks(t, rsaJWK("rsa", &k.rsa.PublicKey), ecJWK("ec", &k.ec.PublicKey), octJWK("oct", k.secret)))
+	// The RSA public key as an HS256 secret, as in the classic key confusion attack
+	rsaAsSecret := []byte(rsaJWK("rsa", &k.rsa.PublicKey)["n"])
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string // Empty for a valid token
+	}{
+		{"RS256", sign(t, RS256, "rsa", k.rsa, validClaims()), ""},
+		{"ES256", sign(t, ES256, "ec", k.ec, validClaims()), ""},
+		{"HS256", sign(t, HS256, "oct", k.secret, validClaims()), ""},
+		{"no kid tries every key", sign(t, ES256, "", k.ec, validClaims()), ""},
+
+		{"alg none", sign(t, "none", "rsa", nil, validClaims()), "unsupported algorithm"},
+		{"HS256 with an RSA kid", sign(t, HS256, "rsa", rsaAsSecret, validClaims()), `no HS256 key with kid "rsa"`},
+		{"RS256 with an oct kid", sign(t, RS256, "oct", k.rsa, validClaims()), `no RS256 key with kid "oct"`},
+		{"ES256 with an RSA kid", sign(t, ES256, "rsa", k.ec, validClaims()), `no ES256 key with kid "rsa"`},
+		{"signed by another key", sign(t, HS256, "oct", []byte("another secret of at least 32 bytes"), validClaims()), "bad signature"},
+		{"malformed", "not.a-token", "malformed token"},
+
+		{"exp within leeway", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"exp": testNow.Add(-30 * time.Second).Unix()})), ""},
+		{"exp past leeway", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"exp": testNow.Add(-2 * time.Minute).Unix()})), "token expired"},
+		{"exp missing", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"exp": nil})), "exp is required"},
+		{"exp not a number", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"exp": "tomorrow"})), "exp is not a number"},
+		{"nbf within leeway", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"nbf": testNow.Add(30 * time.Second).Unix()})), ""},
+		{"nbf past leeway", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"nbf": testNow.Add(2 * time.Minute).Unix()})), "token not valid before"},
+
+		{"wrong issuer", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"iss": "https://evil.example"})), "unexpected issuer"},
+		{"issuer missing", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"iss": nil})), "unexpected issuer"},
+		{"audience list", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"aud": []string{"other", testAudience}})), ""},
+		{"wrong audience", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"aud": "other"})), "not meant for audience"},
+		{"audience missing", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"aud": nil})), "not meant for audience"},
+		{"subject missing", sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{"sub": nil})), "sub is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.Subject != "user-1" || claims.Issuer != testIssuer || claims.Name != "Alice" {
+					t.Fatalf("claims = %+v", claims)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Verify error = %v, want ErrInvalidToken with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRolesClaim(t *testing.T) {
+	k := signingKeys(t)
+	v := newVerifier(t, jwks(t, octJWK("oct", k.secret)))
+	v.RolesClaim = "realm_access.roles"
+	token := sign(t, HS256, "oct", k.secret, withClaims(map[string]interface{}{
+		"realm_access": map[string]interface{}{"roles": []string{"admin", "editor"}},
+	}))
+	claims, err := v.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if strings.Join(claims.Roles, ",") != "admin,editor" {
+		t.Fatalf("roles = %v", claims.Roles)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	k := signingKeys(t)
+	shortRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryption := octJWK("enc", k.secret)
+	encryption["use"] = "enc"
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int // Usable keys
+		wantErr string
+	}{
+		{"every supported type", jwks(t, rsaJWK("rsa", &k.rsa.PublicKey), ecJWK("ec", &k.ec.PublicKey), octJWK("oct", k.secret)), 3, ""},
+		{"short RSA key", jwks(t, rsaJWK("short", &shortRSA.PublicKey)), 0, "at least 2048 bits"},
+		{"short oct key", jwks(t, octJWK("short", k.secret[:16])), 0, "at least 256 bits"},
+		{"point off the curve", jwks(t, map[string]string{"kty": "EC", "kid": "bad", "crv": "P-256", "x": b64([]byte{1}), "y": b64([]byte{2})}), 0, "not on P-256"},
+		{"other curves are skipped", jwks(t, ecJWK("p384", &p384.PublicKey), octJWK("oct", k.secret)), 1, ""},
+		{"encryption keys are skipped", jwks(t, encryption, octJWK("oct", k.secret)), 1, ""},
+		{"no usable key", jwks(t, encryption), 0, "no usable signing keys"},
+		{"not JSON", []byte("{"), 0, "invalid JWKS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseJWKS(tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseJWKS error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(parsed) != tt.want {
+				t.Fatalf("parseJWKS = %d key(s), %v; want %d", len(parsed), err, tt.want)
+			}
+		})
+	}
+}
+
+// TestUnknownKidRefetch rotates a key in on a JWKS endpoint: a token naming
+// the new kid reloads the set, but at most once every minRefreshInterval.
+func TestUnknownKidRefetch(t *testing.T) {
+	k := signingKeys(t)
+	rotated := []byte("a rotated-in secret of 32 bytes!")
+
+	var fetches atomic.Int32
+	var mu sync.Mutex
+	served := jwks(t, octJWK("old", k.secret))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(served)
+	}))
+	defer server.Close()
+
+	v := newVerifier(t, nil)
+	v.Keys = NewKeySet(server.URL, time.Hour)
+	if err := v.Keys.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// Pretend the last fetch was long enough ago to allow another
+	age := func() {
+		v.Keys.mu.Lock()
+		v.Keys.attemptAt = time.Now().Add(-2 * minRefreshInterval)
+		v.Keys.mu.Unlock()
+	}
+
+	steps := []struct {
+		name        string
+		token       string
+		age         bool
+		wantFetches int32
+		wantValid   bool
+	}{
+		{"known kid uses the cache", sign(t, HS256, "old", k.secret, validClaims()), false, 1, true},
+		{"unknown kid right after a fetch waits", sign(t, HS256, "made-up", k.secret, validClaims()), false, 1, false},
+		{"unknown kid refetches", sign(t, HS256, "made-up", k.secret, validClaims()), true, 2, false},
+		{"made-up kids are rate limited", sign(t, HS256, "made-up-2", k.secret, validClaims()), false, 2, false},
+		{"rotated-in kid is fetched", sign(t, HS256, "new", rotated, validClaims()), true, 3, true},
+		{"rotated-in kid is cached", sign(t, HS256, "new", rotated, validClaims()), false, 3, true},
+	}
+	for _, step := range steps {
+		if step.name == "rotated-in kid is fetched" {
+			mu.Lock()
+			served = jwks(t, octJWK("new", rotated))
+			mu.Unlock()
+		}
+		if step.age {
+			age()
+		}
+		_, err := v.Verify(step.token)
+		if (err == nil) != step.wantValid {
+			t.Fatalf("%s: Verify error = %v, want valid %v", step.name, err, step.wantValid)
+		}
+		if got := fetches.Load(); got != step.wantFetches {
+			t.Fatalf("%s: %d fetches, want %d", step.name, got, step.wantFetches)
+		}
+	}
+}
+
+// TestFailedReloadKeepsKeys checks that an unreachable JWKS does not drop the cached keys.
+func TestFailedReloadKeepsKeys(t *testing.T) {
+	k := signingKeys(t)
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(jwks(t, octJWK("oct", k.secret)))
+	}))
+	defer server.Close()
+
+	v := newVerifier(t, nil)
+	v.Keys = NewKeySet(server.URL, time.Nanosecond) // Every lookup reloads
+	token := sign(t, HS256, "oct", k.secret, validClaims())
+	if _, err := v.Verify(token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	failing.Store(true)
+	if _, err := v.Verify(token); err != nil {
+		t.Fatalf("Verify with the JWKS down: %v", err)
+	}
+}
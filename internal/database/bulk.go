@@ -0,0 +1,122 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// TaskWriter makes the changes of a bulk request. InTransaction hands out one
+// whose writes commit together; DirectWriter applies each write on its own.
+type TaskWriter interface {
+	CreateTask(task *models.Task) error
+	UpdateTask(taskId string, task *models.Task) (*models.Task, error)
+	DeleteTask(taskId string, mode DeleteMode, version int64) error
+	RelabelTask(taskId string, add, remove []string, version int64, by *string) (*models.Task, error)
+}
+
+// DirectWriter writes through the package functions, one change at a time.
+var DirectWriter TaskWriter = directWriter{}
+
+type directWriter struct{}
+
+func (directWriter) CreateTask(task *models.Task) error { return CreateTask(task) }
+
+func (directWriter) UpdateTask(taskId string, task *models.Task) (*models.Task, error) {
+	return UpdateTask(taskId, task)
+}
+
+func (directWriter) DeleteTask(taskId string, mode DeleteMode, version int64) error {
+	return DeleteTask(taskId, mode, version)
+}
+
+func (directWriter) RelabelTask(taskId string, add, remove []string, version int64, by *string) (*models.Task, error) {
+	return RelabelTask(taskId, add, remove, version, by)
+}
+
+// storeWriter writes to the store of a transaction. The caller holds recurrenceMu.
+type storeWriter struct {
+	s TaskStore
+}
+
+func (w storeWriter) CreateTask(task *models.Task) error { return createTask(w.s, task) }
+
+func (w storeWriter) UpdateTask(taskId string, task *models.Task) (*models.Task, error) {
+	return updateTask(w.s, taskId, task)
+}
+
+func (w storeWriter) DeleteTask(taskId string, mode DeleteMode, version int64) error {
+	return deleteTask(w.s, taskId, mode, version)
+}
+
+func (w storeWriter) RelabelTask(taskId string, add, remove []string, version int64, by *string) (*models.Task, error) {
+	return relabelTask(w.s, taskId, add, remove, version, by)
+}
+
+// InTransaction runs fn with a TaskWriter whose changes are all kept when fn
+// returns nil and all discarded when it returns an error. fn must make its
+// reads before: the store is held for the length of the transaction.
+func InTransaction(fn func(TaskWriter) error) error {
+	return inTransaction(func(s TaskStore) error { return fn(storeWriter{s}) })
+}
+
+// inTransaction runs fn on the store of a transaction while holding
+// recurrenceMu. Observers hear of its changes once it commits.
+func inTransaction(fn func(TaskStore) error) error {
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	recurrenceMu.Lock()
+	defer recurrenceMu.Unlock()
+	tx := &pendingChanges{}
+	err := store.Transaction(func(s TaskStore) error {
+		tx.TaskStore, tx.changes = s, nil
+		return fn(tx)
+	})
+	if err == nil {
+		notifyObservers(tx.changes...)
+	}
+	return err
+}
+
+// RelabelTask adds and removes labels of a task, leaving its other labels in
+// place, and returns the updated task. A non-zero version makes the change
+// conditional like in UpdateTask. by is the ID of the user making the change.
+func RelabelTask(taskId string, add, remove []string, version int64, by *string) (*models.Task, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	return relabelTask(store, taskId, add, remove, version, by)
+}
+
+func relabelTask(s TaskStore, taskId string, add, remove []string, version int64, by *string) (*models.Task, error) {
+	existing, err := s.GetTaskByID(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := map[string]bool{}
+	for _, label := range remove {
+		removed[strings.TrimSpace(label)] = true
+	}
+	labels := []string{}
+	present := map[string]bool{}
+	for _, label := range existing.Labels {
+		if !removed[label] && !present[label] {
+			labels = append(labels, label)
+			present[label] = true
+		}
+	}
+	for _, label := range add {
+		label = strings.TrimSpace(label)
+		if label != "" && !present[label] {
+			labels = append(labels, label)
+			present[label] = true
+		}
+	}
+
+	task := *existing
+	task.Labels, task.Version, task.UpdatedBy = labels, version, by
+	return updateTask(s, taskId, &task)
+}
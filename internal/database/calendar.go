@@ -0,0 +1,59 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// ErrInvalidCalendarToken is returned for unknown or replaced calendar feed tokens.
+var ErrInvalidCalendarToken = errors.New("invalid calendar token")
+
+// calendarTokenPrefix starts every calendar feed token.
+const calendarTokenPrefix = "tmcal_"
+
+// IssueCalendarToken gives a user a new calendar feed token, replacing the
+// previous one. The token only opens the feed, since it travels in URLs that
+// calendar apps store and share. Like an API key it cannot be recovered later.
+func IssueCalendarToken(userID string) (string, error) {
+	if store == nil {
+		return "", fmt.Errorf("database connection is nil")
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate calendar token: %v", err)
+	}
+	plaintext := calendarTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	if err := store.SetCalendarToken(userID, hashAPIKey(plaintext), models.Now()); err != nil {
+		return "", fmt.Errorf("failed to store calendar token: %v", err)
+	}
+	return plaintext, nil
+}
+
+// RevokeCalendarToken disables the calendar feed token of a user, if any.
+func RevokeCalendarToken(userID string) error {
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	return store.DeleteCalendarToken(userID)
+}
+
+// AuthenticateCalendarToken resolves a calendar feed token to the identity of
+// its owner. Unknown tokens return ErrInvalidCalendarToken.
+func AuthenticateCalendarToken(plaintext string) (*models.Identity, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	userID, err := store.GetCalendarTokenUser(hashAPIKey(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	user, err := store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Identity{UserID: user.ID, Name: user.Name, IsAdmin: user.IsAdmin}, nil
+}
@@ -0,0 +1,301 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/priority"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
+)
+
+var (
+	// ErrDependencyNotFound is returned when removing an edge that does not exist.
+	ErrDependencyNotFound = errors.New("dependency not found")
+	// ErrDependencyExists is returned when adding an edge twice.
+	ErrDependencyExists = errors.New("dependency already exists")
+	// ErrDependencyTaskNotFound is returned when depends_on_id names a task that does not exist.
+	ErrDependencyTaskNotFound = errors.New("dependency task not found")
+	// ErrDependencyCycle is returned when an edge would close a loop in the dependency graph.
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrBlockedByDependency is returned when starting a task whose dependencies are still open.
+	ErrBlockedByDependency = errors.New("task is blocked by open dependencies")
+)
+
+// dependencyMu serializes the check-then-insert in AddDependency, so two
+// concurrent requests cannot each add half of a cycle.
+var dependencyMu sync.Mutex
+
+// AddDependency records that taskID cannot start until dependsOnID is finished.
+func AddDependency(taskID, dependsOnID string) (*models.Dependency, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if taskID == dependsOnID {
+		return nil, fmt.Errorf("%w: a task cannot depend on itself", ErrDependencyCycle)
+	}
+	if _, err := store.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	if _, err := store.GetTaskByID(dependsOnID); errors.Is(err, ErrTaskNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyTaskNotFound, dependsOnID)
+	} else if err != nil {
+		return nil, err
+	}
+
+	dependencyMu.Lock()
+	defer dependencyMu.Unlock()
+
+	deps, err := store.ListDependencies()
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range deps {
+		if dep.TaskID == taskID && dep.DependsOnID == dependsOnID {
+			return nil, ErrDependencyExists
+		}
+	}
+	// The new edge closes a loop when dependsOnID already waits on taskID
+	if path := dependencyPath(deps, dependsOnID, taskID); path != nil {
+		return nil, fmt.Errorf("%w: %s already depends on %s (%s)", ErrDependencyCycle, dependsOnID, taskID, strings.Join(path, " -> "))
+	}
+
+	dep := models.Dependency{TaskID: taskID, DependsOnID: dependsOnID, CreatedAt: models.Now()}
+	if err := store.AddDependency(dep); err != nil {
+		return nil, fmt.Errorf("failed to add dependency: %v", err)
+	}
+	return &dep, nil
+}
+
+// dependencyPath returns the chain of task IDs leading from one task to
+// another along depends-on edges, or nil when there is none.
+func dependencyPath(deps []models.Dependency, from, to string) []string {
+	next := map[string][]string{}
+	for _, dep := range deps {
+		next[dep.TaskID] = append(next[dep.TaskID], dep.DependsOnID)
+	}
+
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			var path []string
+			for ; id != ""; id = previous[id] {
+				path = append([]string{id}, path...)
+			}
+			return path
+		}
+		for _, n := range next[id] {
+			if _, seen := previous[n]; !seen {
+				previous[n] = id
+				queue = append(queue, n)
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveDependency deletes the edge taskID -> dependsOnID.
+func RemoveDependency(taskID, dependsOnID string) error {
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	return store.RemoveDependency(taskID, dependsOnID)
+}
+
+// GetTaskDependencies lists the edges in both directions for one task.
+func GetTaskDependencies(taskID string) (*models.TaskDependencies, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if _, err := store.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	deps, err := store.ListDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.TaskDependencies{BlockedBy: []models.Dependency{}, Blocks: []models.Dependency{}}
+	for _, dep := range deps {
+		if dep.TaskID == taskID {
+			result.BlockedBy = append(result.BlockedBy, dep)
+		}
+		if dep.DependsOnID == taskID {
+			result.Blocks = append(result.Blocks, dep)
+		}
+	}
+	return result, nil
+}
+
+// GetBlockers returns the direct dependencies of a task that are not finished
+// yet, i.e. are not in a terminal workflow status.
+func GetBlockers(taskID string) ([]models.Task, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if _, err := store.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	deps, err := store.ListDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	wf := workflow.Current()
+	blockers := []models.Task{}
+	for _, dep := range deps {
+		if dep.TaskID != taskID {
+			continue
+		}
+		task, err := store.GetTaskByID(dep.DependsOnID)
+		if err != nil {
+			return nil, err
+		}
+		if !wf.IsTerminal(task.Status) {
+			priority.Current().Apply(task)
+			blockers = append(blockers, *task)
+		}
+	}
+	return blockers, nil
+}
+
+// checkBlockers refuses to start or finish a task while any of its dependencies is open.
+func checkBlockers(taskID string) error {
+	blockers, err := GetBlockers(taskID)
+	if err != nil {
+		return err
+	}
+	if len(blockers) == 0 {
+		return nil
+	}
+	ids := make([]string, len(blockers))
+	for i, blocker := range blockers {
+		ids[i] = blocker.ID
+	}
+	return fmt.Errorf("%w: waiting for %s", ErrBlockedByDependency, strings.Join(ids, ", "))
+}
+
+// CriticalPath finds the chain of open, dependent tasks that takes the longest
+// to get through. A task's share of the chain is the time between its due date
+// and the due date of the task it waits for (or now, for the first task), so
+// the path leads to the latest deadline; ties go to the chain with more tasks.
+// A task without dependencies is a chain of its own, so without any the path
+// is the open task due last. Tasks without a due date add no time, and the
+// path is empty only when no task is open. A non-nil visibleTo limits the
+// path to the tasks that caller may see.
+func CriticalPath(visibleTo *models.Identity) (*models.CriticalPath, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	tasks, err := store.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+	deps, err := store.ListDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	wf := workflow.Current()
+	open := map[string]models.Task{}
+	for _, task := range tasks {
+		if !wf.IsTerminal(task.Status) && (visibleTo == nil || visibleTo.CanSee(task)) {
+			open[task.ID] = task
+		}
+	}
+
+	// Only edges between open tasks constrain the remaining work
+	waiters := map[string][]string{}
+	pending := map[string]int{}
+	for id := range open {
+		pending[id] = 0
+	}
+	for _, dep := range deps {
+		_, from := open[dep.TaskID]
+		_, to := open[dep.DependsOnID]
+		if !from || !to {
+			continue
+		}
+		waiters[dep.DependsOnID] = append(waiters[dep.DependsOnID], dep.TaskID)
+		pending[dep.TaskID]++
+	}
+
+	now := models.Now()
+	result := &models.CriticalPath{Tasks: []models.Task{}, Start: now}
+	if len(open) == 0 {
+		return result, nil
+	}
+
+	type step struct {
+		duration time.Duration
+		count    int
+		previous string
+	}
+	span := func(from time.Time, task models.Task) time.Duration {
+		if task.DueDate.IsZero() || !task.DueDate.After(from) {
+			return 0
+		}
+		return task.DueDate.Sub(from)
+	}
+	anchor := func(task models.Task) time.Time {
+		if task.DueDate.IsZero() {
+			return now.Time
+		}
+		return task.DueDate.Time
+	}
+	better := func(a, b step) bool {
+		return a.duration > b.duration || (a.duration == b.duration && a.count > b.count)
+	}
+
+	// Longest path over a topological order (Kahn), visiting IDs in sorted
+	// order so equal paths always resolve the same way
+	var queue []string
+	for id, n := range pending {
+		if n == 0 {
+			queue = append(queue, id)
+		}
+	}
+	sort.Strings(queue)
+
+	best := map[string]step{}
+	for _, id := range queue {
+		best[id] = step{duration: span(now.Time, open[id]), count: 1}
+	}
+	end := ""
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if end == "" || better(best[id], best[end]) {
+			end = id
+		}
+
+		next := append([]string{}, waiters[id]...)
+		sort.Strings(next)
+		for _, w := range next {
+			candidate := step{duration: best[id].duration + span(anchor(open[id]), open[w]), count: best[id].count + 1, previous: id}
+			if current, ok := best[w]; !ok || better(candidate, current) {
+				best[w] = candidate
+			}
+			pending[w]--
+			if pending[w] == 0 {
+				queue = append(queue, w)
+			}
+		}
+	}
+
+	for id := end; id != ""; id = best[id].previous {
+		task := open[id]
+		priority.Current().Apply(&task)
+		result.Tasks = append([]models.Task{task}, result.Tasks...)
+	}
+	result.Finish = open[end].DueDate
+	result.DurationSeconds = int64(best[end].duration / time.Second)
+	return result, nil
+}
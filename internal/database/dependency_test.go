@@ -0,0 +1,154 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+func TestCriticalPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		due    map[string]time.Duration // Open tasks by ID; 0 is no due date
+		done   []string
+		deps   [][2]string // Task, the task it waits for
+		path   string
+		finish time.Duration // -1 is no finish
+	}{
+		{"no tasks", nil, nil, nil, "", -1},
+		{"only finished tasks", map[string]time.Duration{"a": time.Hour}, []string{"a"}, nil, "", -1},
+		{"no dependencies", map[string]time.Duration{"a": time.Hour, "b": 3 * time.Hour, "c": 2 * time.Hour}, nil, nil,
+			"b", 3 * time.Hour},
+		{"no due dates", map[string]time.Duration{"b": 0, "a": 0}, nil, nil, "a", -1},
+		{"chain", map[string]time.Duration{"a": time.Hour, "b": 2 * time.Hour, "c": 4 * time.Hour, "d": 3 * time.Hour}, nil,
+			[][2]string{{"b", "a"}, {"c", "b"}}, "a b c", 4 * time.Hour},
+		{"a later task of its own", map[string]time.Duration{"a": time.Hour, "b": 2 * time.Hour, "c": 5 * time.Hour}, nil,
+			[][2]string{{"b", "a"}}, "c", 5 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, s TaskStore) {
+				useStore(t, s)
+				now := models.Now().Time
+				for id, due := range tt.due {
+					task := models.Task{ID: id, Title: id, Status: models.StatusTodo, Labels: []string{},
+						CreatedAt: models.Now(), UpdatedAt: models.Now(), Version: 1}
+					if due != 0 {
+						task.DueDate = models.NewTimestamp(now.Add(due))
+					}
+					if err := s.CreateTask(&task); err != nil {
+						t.Fatalf("create %s: %v", id, err)
+					}
+				}
+				for _, id := range tt.done {
+					if err := s.UpdateTaskStatus(id, models.StatusTodo, &models.Task{Status: models.StatusDone}); err != nil {
+						t.Fatalf("finish %s: %v", id, err)
+					}
+				}
+				for _, dep := range tt.deps {
+					if err := s.AddDependency(models.Dependency{TaskID: dep[0], DependsOnID: dep[1], CreatedAt: models.Now()}); err != nil {
+						t.Fatalf("add dependency %v: %v", dep, err)
+					}
+				}
+
+				path, err := CriticalPath(nil)
+				if err != nil {
+					t.Fatalf("CriticalPath: %v", err)
+				}
+				var ids []string
+				for _, task := range path.Tasks {
+					ids = append(ids, task.ID)
+				}
+				if got := strings.Join(ids, " "); got != tt.path {
+					t.Errorf("path = %q, want %q", got, tt.path)
+				}
+				switch {
+				case tt.finish < 0 && !path.Finish.IsZero():
+					t.Errorf("finish = %s, want none", path.Finish)
+				case tt.finish >= 0 && !path.Finish.Equal(now.Add(tt.finish)):
+					t.Errorf("finish = %s, want %s", path.Finish, now.Add(tt.finish))
+				}
+			})
+		})
+	}
+}
+
+func TestTransitionTaskBlockers(t *testing.T) {
+	tests := []struct {
+		name        string
+		to          models.Status
+		blockerDone bool
+		want        error
+	}{
+		{"start with an open blocker", models.StatusInProgress, false, ErrBlockedByDependency},
+		{"finish with an open blocker", models.StatusDone, false, ErrBlockedByDependency},
+		{"cancel with an open blocker", models.StatusCancelled, false, nil},
+		{"mark blocked with an open blocker", models.StatusBlocked, false, nil},
+		{"finish with a finished blocker", models.StatusDone, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, s TaskStore) {
+				useStore(t, s)
+				createTestTask(t, s, "blocker", nil)
+				createTestTask(t, s, "task", nil)
+				if err := s.AddDependency(models.Dependency{TaskID: "task", DependsOnID: "blocker", CreatedAt: models.Now()}); err != nil {
+					t.Fatalf("add dependency: %v", err)
+				}
+				if tt.blockerDone {
+					if _, err := TransitionTask("blocker", models.StatusDone, nil); err != nil {
+						t.Fatalf("finish the blocker: %v", err)
+					}
+				}
+
+				_, err := TransitionTask("task", tt.to, nil)
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("TransitionTask to %s = %v, want %v", tt.to, err, tt.want)
+				}
+				want := tt.to
+				if tt.want != nil {
+					want = models.StatusTodo
+				}
+				if task, _ := s.GetTaskByID("task"); task.Status != want {
+					t.Fatalf("status after the transition = %s, want %s", task.Status, want)
+				}
+			})
+		})
+	}
+}
+
+func TestTransitionTaskResponse(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		useStore(t, s)
+		low := models.Low
+		task := createTestTask(t, s, "task", nil)
+		task.Priority = &low
+		if _, err := s.UpdateTask("task", &task); err != nil {
+			t.Fatalf("store a low priority: %v", err)
+		}
+		parent := "task"
+		createTestTask(t, s, "child", &parent)
+
+		moved, err := TransitionTask("task", models.StatusInProgress, nil)
+		if err != nil {
+			t.Fatalf("TransitionTask: %v", err)
+		}
+		// Due within the hour, the default policy raises it like a read would
+		want, err := GetTaskByID("task")
+		if err != nil {
+			t.Fatalf("GetTaskByID: %v", err)
+		}
+		if moved.Priority == nil || *moved.Priority != *want.Priority || *moved.Priority == models.Low {
+			t.Errorf("priority after the transition = %v, want %v", moved.Priority, *want.Priority)
+		}
+		if moved.Rollup == nil || moved.Rollup.ChildCount != 1 {
+			t.Errorf("rollup after the transition = %+v, want one child", moved.Rollup)
+		}
+		if moved.Version != want.Version {
+			t.Errorf("version after the transition = %d, want %d", moved.Version, want.Version)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"sync"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// ChangeKind says what happened to a task.
+type ChangeKind string
+
+const (
+	TaskCreated ChangeKind = "created"
+	TaskUpdated ChangeKind = "updated"
+	TaskDeleted ChangeKind = "deleted"
+	// TaskOverdue is the update flagging a task as overdue
+	TaskOverdue ChangeKind = "overdue"
+)
+
+// TaskChange is a write to a task, as the package functions made it.
+type TaskChange struct {
+	Kind ChangeKind
+	Task models.Task
+	// Previous is the task before an update, when the writer had it at hand
+	Previous *models.Task
+}
+
+var (
+	observersMu    sync.Mutex
+	observers      = map[int]func(TaskChange){}
+	nextObserverID int
+)
+
+// ObserveTasks calls fn after every task the package functions create, update
+// or delete, and returns a function that stops the calls. Changes made in a
+// transaction are passed on once it commits. fn runs on the writing goroutine
+// and must return quickly.
+func ObserveTasks(fn func(TaskChange)) (stop func()) {
+	observersMu.Lock()
+	defer observersMu.Unlock()
+	id := nextObserverID
+	nextObserverID++
+	observers[id] = fn
+	return func() {
+		observersMu.Lock()
+		defer observersMu.Unlock()
+		delete(observers, id)
+	}
+}
+
+func notifyObservers(changes ...TaskChange) {
+	observersMu.Lock()
+	fns := make([]func(TaskChange), 0, len(observers))
+	for _, fn := range observers {
+		fns = append(fns, fn)
+	}
+	observersMu.Unlock()
+	for _, change := range changes {
+		for _, fn := range fns {
+			fn(change)
+		}
+	}
+}
+
+// pendingChanges is the store of a transaction, holding back its changes
+// until it commits.
+type pendingChanges struct {
+	TaskStore
+	changes []TaskChange
+}
+
+// taskChanged passes a change on to the observers, or holds it back when s is
+// the store of a transaction.
+func taskChanged(s TaskStore, kind ChangeKind, task models.Task) {
+	recordChange(s, TaskChange{Kind: kind, Task: task})
+}
+
+// taskUpdated is taskChanged for an update of previous.
+func taskUpdated(s TaskStore, kind ChangeKind, previous, task models.Task) {
+	recordChange(s, TaskChange{Kind: kind, Task: task, Previous: &previous})
+}
+
+func recordChange(s TaskStore, change TaskChange) {
+	if tx, ok := s.(*pendingChanges); ok {
+		tx.changes = append(tx.changes, change)
+		return
+	}
+	notifyObservers(change)
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// ErrGrantNotFound is returned when a grant ID does not exist.
+var ErrGrantNotFound = errors.New("grant not found")
+
+// ErrInvalidGrant is returned for grant requests that name an unknown user or
+// task, or both a project and a task.
+var ErrInvalidGrant = errors.New("invalid grant")
+
+// GrantFilter selects grants; empty fields match everything.
+type GrantFilter struct {
+	UserID  string
+	Project string
+	TaskID  string
+}
+
+// GrantStore is the persistence contract for role grants.
+type GrantStore interface {
+	CreateGrant(grant *models.Grant) error
+	UpdateGrantRole(id string, role models.Role) error
+	GetGrant(id string) (*models.Grant, error)
+	ListGrants(filter GrantFilter) ([]models.Grant, error)
+	DeleteGrant(id string) error
+}
+
+// grantMu serializes GrantRole, which keeps one grant per user and scope.
+var grantMu sync.Mutex
+
+// GrantRole gives a user a role globally, on a project or on a task. A user
+// has at most one grant per scope, so granting again changes the role of the
+// existing grant; created tells the two cases apart.
+func GrantRole(request models.GrantRequest, by *string) (grant *models.Grant, created bool, err error) {
+	if store == nil {
+		return nil, false, fmt.Errorf("database connection is nil")
+	}
+	role, err := models.ParseRole(string(request.Role))
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidGrant, err)
+	}
+	user, err := FindUser(strings.TrimSpace(request.User))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, false, fmt.Errorf("%w: user %s not found", ErrInvalidGrant, request.User)
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	grant = &models.Grant{UserID: user.ID, Role: role, CreatedBy: by}
+	project, taskID := strings.TrimSpace(request.Project), strings.TrimSpace(request.TaskID)
+	switch {
+	case project != "" && taskID != "":
+		return nil, false, fmt.Errorf("%w: set project or task_id, not both", ErrInvalidGrant)
+	case project != "":
+		grant.Project = &project
+	case taskID != "":
+		if _, err := store.GetTaskByID(taskID); errors.Is(err, ErrTaskNotFound) {
+			return nil, false, fmt.Errorf("%w: task %s not found", ErrInvalidGrant, taskID)
+		} else if err != nil {
+			return nil, false, err
+		}
+		grant.TaskID = &taskID
+	}
+
+	grantMu.Lock()
+	defer grantMu.Unlock()
+
+	existing, err := store.ListGrants(GrantFilter{UserID: user.ID, Project: project, TaskID: taskID})
+	if err != nil {
+		return nil, false, err
+	}
+	for _, other := range existing {
+		if sameScope(other, *grant) {
+			if err := store.UpdateGrantRole(other.ID, role); err != nil {
+				return nil, false, fmt.Errorf("failed to update grant: %v", err)
+			}
+			other.Role = role
+			return &other, false, nil
+		}
+	}
+
+	grant.ID = uuid.New().String()
+	grant.CreatedAt = models.Now()
+	if err := store.CreateGrant(grant); err != nil {
+		return nil, false, fmt.Errorf("failed to create grant: %v", err)
+	}
+	return grant, true, nil
+}
+
+func sameScope(a, b models.Grant) bool {
+	equal := func(x, y *string) bool { return (x == nil && y == nil) || (x != nil && y != nil && *x == *y) }
+	return a.UserID == b.UserID && equal(a.Project, b.Project) && equal(a.TaskID, b.TaskID)
+}
+
+// GetGrant returns one grant.
+func GetGrant(id string) (*models.Grant, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	return store.GetGrant(id)
+}
+
+// ListGrants returns the grants matching filter.
+func ListGrants(filter GrantFilter) ([]models.Grant, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	return store.ListGrants(filter)
+}
+
+// RevokeGrant deletes a grant.
+func RevokeGrant(id string) error {
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	return store.DeleteGrant(id)
+}
+
+// ResolveAccess fills in the global role and the grants of an authenticated
+// caller. The global role is admin for admin users, otherwise the highest of
+// their global grant and the roles in their token; callers with neither get
+// defaultRole.
+func ResolveAccess(identity *models.Identity, defaultRole models.Role) error {
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	grants, err := store.ListGrants(GrantFilter{UserID: identity.UserID})
+	if err != nil {
+		return err
+	}
+
+	var role models.Role
+	for _, name := range identity.Roles {
+		role = models.MaxRole(role, models.Role(name))
+	}
+	identity.Grants = identity.Grants[:0]
+	for _, grant := range grants {
+		if grant.IsGlobal() {
+			role = models.MaxRole(role, grant.Role)
+		} else {
+			identity.Grants = append(identity.Grants, grant)
+		}
+	}
+	if role == "" {
+		role = defaultRole
+	}
+	if identity.IsAdmin || role == models.RoleAdmin {
+		identity.IsAdmin, role = true, models.RoleAdmin
+	}
+	identity.Role = role
+	return nil
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
+)
+
+var (
+	// ErrParentNotFound is returned when parent_id names a task that does not exist.
+	ErrParentNotFound = errors.New("parent task not found")
+	// ErrHierarchyCycle is returned when a parent_id would make a task its own ancestor.
+	ErrHierarchyCycle = errors.New("parent would create a cycle")
+)
+
+// DeleteMode decides what happens to the subtasks of a deleted task.
+type DeleteMode string
+
+const (
+	// DeleteReparent moves the children up to the deleted task's parent.
+	DeleteReparent DeleteMode = "reparent"
+	// DeleteCascade deletes the whole subtree.
+	DeleteCascade DeleteMode = "cascade"
+)
+
+// ParseDeleteMode reads the ?children= value of a delete; empty means reparent.
+func ParseDeleteMode(value string) (DeleteMode, error) {
+	switch DeleteMode(value) {
+	case "", DeleteReparent:
+		return DeleteReparent, nil
+	case DeleteCascade:
+		return DeleteCascade, nil
+	}
+	return "", fmt.Errorf("invalid children mode: %s. Valid values are: [%s %s]", value, DeleteReparent, DeleteCascade)
+}
+
+// normalizeParentID turns an empty parent_id into no parent.
+func normalizeParentID(task *models.Task) {
+	if task.ParentID != nil && *task.ParentID == "" {
+		task.ParentID = nil
+	}
+}
+
+// checkParent makes sure parentID exists in s and that hanging taskID under it
+// keeps the hierarchy a tree. taskID is empty for tasks that are not created yet.
+func checkParent(s TaskStore, taskID string, parentID *string) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == taskID {
+		return fmt.Errorf("%w: a task cannot be its own parent", ErrHierarchyCycle)
+	}
+
+	// Walk up from the new parent; meeting taskID on the way means a cycle
+	seen := map[string]bool{}
+	for id := *parentID; id != ""; {
+		if id == taskID {
+			return fmt.Errorf("%w: task %s is a descendant of %s", ErrHierarchyCycle, *parentID, taskID)
+		}
+		if seen[id] {
+			// An existing loop above us; refuse to join it
+			return fmt.Errorf("%w: the ancestors of %s loop", ErrHierarchyCycle, *parentID)
+		}
+		seen[id] = true
+
+		ancestor, err := s.GetTaskByID(id)
+		if errors.Is(err, ErrTaskNotFound) && id == *parentID {
+			return fmt.Errorf("%w: %s", ErrParentNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+		id = ""
+		if ancestor.ParentID != nil {
+			id = *ancestor.ParentID
+		}
+	}
+	return nil
+}
+
+// newRollup summarizes direct subtask counts. Children in a terminal workflow
+// status (done, cancelled, ...) count as complete. It returns nil for leaf tasks.
+func newRollup(byStatus map[models.Status]int) *models.TaskRollup {
+	wf := workflow.Current()
+	rollup := &models.TaskRollup{ChildrenByStatus: map[models.Status]int{}}
+	complete := 0
+	for status, count := range byStatus {
+		rollup.ChildrenByStatus[status] = count
+		rollup.ChildCount += count
+		if wf.IsTerminal(status) {
+			complete += count
+		}
+	}
+	if rollup.ChildCount == 0 {
+		return nil
+	}
+	rollup.PercentComplete = float64(complete*10000/rollup.ChildCount) / 100
+	return rollup
+}
+
+// addRollups fills the Rollup field of every task that has subtasks in s.
+func addRollups(s TaskStore, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	counts, err := s.CountChildrenByStatus(ids)
+	if err != nil {
+		return fmt.Errorf("failed to count subtasks: %v", err)
+	}
+	for i := range tasks {
+		tasks[i].Rollup = newRollup(counts[tasks[i].ID])
+	}
+	return nil
+}
+
+// buildTree nests the flat subtree rooted at rootID, with rollups computed from
+// the loaded children. Children are ordered by creation time.
+func buildTree(rootID string, subtree []models.Task) *models.Task {
+	children := map[string][]models.Task{}
+	var root models.Task
+	for _, task := range subtree {
+		if task.ID == rootID {
+			root = task
+		} else if task.ParentID != nil {
+			children[*task.ParentID] = append(children[*task.ParentID], task)
+		}
+	}
+
+	var attach func(task *models.Task)
+	attach = func(task *models.Task) {
+		kids := children[task.ID]
+		sort.SliceStable(kids, func(i, j int) bool {
+			if kids[i].CreatedAt.Equal(kids[j].CreatedAt.Time) {
+				return kids[i].ID < kids[j].ID
+			}
+			return kids[i].CreatedAt.Before(kids[j].CreatedAt.Time)
+		})
+		byStatus := map[models.Status]int{}
+		for i := range kids {
+			attach(&kids[i])
+			byStatus[kids[i].Status]++
+		}
+		task.Children = kids
+		task.Rollup = newRollup(byStatus)
+	}
+	attach(&root)
+	return &root
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+var (
+	// ErrIdempotencyKeyInUse is returned while the first request with a key is still running.
+	ErrIdempotencyKeyInUse = errors.New("a request with this idempotency key is still in progress")
+	// ErrIdempotencyKeyReused is returned when a key comes back with a different request.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+	// ErrIdempotencyRecordNotFound is returned by stores for unknown keys.
+	ErrIdempotencyRecordNotFound = errors.New("idempotency key not found")
+)
+
+// idempotencyLease is how long a request may hold its key without finishing.
+// A record still in progress after that belongs to a request that died, and
+// the next retry takes the key over.
+const idempotencyLease = time.Minute
+
+// idempotencyPurgeInterval limits how often expired records are swept.
+const idempotencyPurgeInterval = time.Minute
+
+var (
+	idempotencyPurgeMu sync.Mutex
+	idempotencyPurgeAt time.Time
+)
+
+// IdempotencyRecord is a request made with an Idempotency-Key header and,
+// once it finished, the response to replay to its retries.
+type IdempotencyRecord struct {
+	Scope       string // The caller; keys of different users never collide
+	Key         string
+	Fingerprint string // Digest of the request, see middleware.Idempotency
+	StatusCode  int    // 0 while the request is in progress
+	ContentType string
+	Body        []byte
+	CreatedAt   models.Timestamp
+	ExpiresAt   models.Timestamp
+}
+
+// IdempotencyStore is the persistence contract for idempotency keys.
+type IdempotencyStore interface {
+	// CreateIdempotencyRecord stores record unless its scope already holds the
+	// key, and reports whether it did.
+	CreateIdempotencyRecord(record *IdempotencyRecord) (bool, error)
+	GetIdempotencyRecord(scope, key string) (*IdempotencyRecord, error)
+	// CompleteIdempotencyRecord stores the response of a finished request.
+	CompleteIdempotencyRecord(scope, key string, statusCode int, contentType string, body []byte) error
+	DeleteIdempotencyRecord(scope, key string) error
+	// DeleteExpiredIdempotencyRecords removes the records that expired before now.
+	DeleteExpiredIdempotencyRecords(now models.Timestamp) error
+}
+
+// BeginIdempotentRequest claims key for a request with the given fingerprint
+// for ttl. A nil record means the key is new: the caller handles the request
+// and then calls FinishIdempotentRequest. Otherwise the record holds the
+// response to replay. A key in use by a running request returns
+// ErrIdempotencyKeyInUse, and one used for another request
+// ErrIdempotencyKeyReused.
+func BeginIdempotentRequest(scope, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	now := time.Now()
+	purgeExpiredIdempotencyRecords(now)
+
+	// Two rounds: the first may find an expired or abandoned record to clear
+	for attempt := 0; attempt < 2; attempt++ {
+		record := &IdempotencyRecord{
+			Scope:       scope,
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   models.NewTimestamp(now),
+			ExpiresAt:   models.NewTimestamp(now.Add(ttl)),
+		}
+		created, err := store.CreateIdempotencyRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return nil, nil
+		}
+
+		existing, err := store.GetIdempotencyRecord(scope, key)
+		if errors.Is(err, ErrIdempotencyRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		abandoned := existing.StatusCode == 0 && now.Sub(existing.CreatedAt.Time) > idempotencyLease
+		if existing.ExpiresAt.Before(now) || abandoned {
+			if err := store.DeleteIdempotencyRecord(scope, key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if existing.Fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if existing.StatusCode == 0 {
+			return nil, ErrIdempotencyKeyInUse
+		}
+		return existing, nil
+	}
+	return nil, ErrIdempotencyKeyInUse
+}
+
+// FinishIdempotentRequest stores the response of a request begun with
+// BeginIdempotentRequest. Server errors are not kept: the key is released so
+// that a retry runs the request again.
+func FinishIdempotentRequest(scope, key string, statusCode int, contentType string, body []byte) error {
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if statusCode >= 500 {
+		return store.DeleteIdempotencyRecord(scope, key)
+	}
+	return store.CompleteIdempotencyRecord(scope, key, statusCode, contentType, body)
+}
+
+// purgeExpiredIdempotencyRecords sweeps expired records, at most once per
+// idempotencyPurgeInterval.
+func purgeExpiredIdempotencyRecords(now time.Time) {
+	idempotencyPurgeMu.Lock()
+	due := now.Sub(idempotencyPurgeAt) >= idempotencyPurgeInterval
+	if due {
+		idempotencyPurgeAt = now
+	}
+	idempotencyPurgeMu.Unlock()
+
+	if due {
+		if err := store.DeleteExpiredIdempotencyRecords(models.NewTimestamp(now)); err != nil {
+			log.Printf("Failed to purge expired idempotency keys: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// ErrLeaseNotFound is returned by stores for a lease nobody holds.
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// Lease is a named role held by one server at a time, e.g. running the
+// background jobs, until it expires.
+type Lease struct {
+	Name       string
+	Holder     string // ID of the server holding the lease
+	AcquiredAt models.Timestamp
+	ExpiresAt  models.Timestamp
+}
+
+// LeaseStore is the persistence contract for leases.
+type LeaseStore interface {
+	// AcquireLease gives holder the lease name until ttl from now when it is
+	// free, expired or already held by holder, and reports whether it did. A
+	// holder renewing its lease keeps its first AcquiredAt. Stores shared by
+	// several servers tell the time by the database clock, so a lease expires
+	// at the same moment for all of them.
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// ReleaseLease gives up a lease if holder still holds it.
+	ReleaseLease(name, holder string) error
+	// GetLease returns a lease, expired or not, or ErrLeaseNotFound.
+	GetLease(name string) (*Lease, error)
+}
+
+// AcquireLease takes or renews the lease name for holder for ttl, and
+// reports whether holder has it now. ctx bounds the wait for the database.
+func AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	if store == nil {
+		return false, fmt.Errorf("database connection is nil")
+	}
+	return store.AcquireLease(ctx, name, holder, ttl)
+}
+
+// ReleaseLease gives up the lease name if holder holds it, so another server
+// can take it without waiting for it to expire.
+func ReleaseLease(name, holder string) error {
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	return store.ReleaseLease(name, holder)
+}
+
+// GetLease returns the lease name, or nil when nobody holds it.
+func GetLease(name string) (*Lease, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	lease, err := store.GetLease(name)
+	if errors.Is(err, ErrLeaseNotFound) || (err == nil && !lease.ExpiresAt.After(time.Now())) {
+		return nil, nil
+	}
+	return lease, err
+}
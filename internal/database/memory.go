@@ -0,0 +1,680 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// MemoryStore is a TaskStore that keeps tasks in a map. It is meant for tests
+// and quick local runs; nothing survives a restart.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tasks  map[string]models.Task
+	deps   []models.Dependency
+	users  map[string]models.User
+	keys   map[string]models.APIKey
+	grants map[string]models.Grant
+	// idempotency records by scope and key
+	idempotency map[[2]string]IdempotencyRecord
+	// calendar feed token digests by user ID
+	calendarTokens map[string]string
+	leases         map[string]Lease
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tasks:  make(map[string]models.Task),
+		users:  make(map[string]models.User),
+		keys:   make(map[string]models.APIKey),
+		grants: make(map[string]models.Grant),
+
+		idempotency:    make(map[[2]string]IdempotencyRecord),
+		calendarTokens: make(map[string]string),
+		leases:         make(map[string]Lease),
+	}
+}
+
+// cloneTask copies the slice and pointer fields so callers never share state with the store.
+func cloneTask(task models.Task) models.Task {
+	if task.Priority != nil {
+		p := *task.Priority
+		task.Priority = &p
+	}
+	for _, field := range []**string{&task.ParentID, &task.SeriesID, &task.OwnerID, &task.CreatedBy, &task.UpdatedBy} {
+		if *field != nil {
+			value := **field
+			*field = &value
+		}
+	}
+	task.Labels = append([]string{}, task.Labels...)
+	return task
+}
+
+func (m *MemoryStore) CreateTask(task *models.Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if task.SeriesID != nil {
+		for _, other := range m.tasks {
+			if other.SeriesID != nil && *other.SeriesID == *task.SeriesID && other.Occurrence == task.Occurrence {
+				return ErrOccurrenceExists
+			}
+		}
+	}
+	stored := cloneTask(*task)
+	stored.Labels = normalizeLabels(stored.Labels)
+	m.tasks[task.ID] = stored
+	return nil
+}
+
+func (m *MemoryStore) GetTasks() ([]models.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tasks := make([]models.Task, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		tasks = append(tasks, cloneTask(task))
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		ri, rj := priorityRank(tasks[i].Priority), priorityRank(tasks[j].Priority)
+		if ri != rj {
+			return ri < rj
+		}
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt.Time)
+	})
+	return tasks, nil
+}
+
+func (m *MemoryStore) ListTasks(query models.TaskQuery) (*TaskPage, error) {
+	tasks, err := m.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+	return pageInMemory(tasks, query)
+}
+
+func (m *MemoryStore) GetTaskByID(taskId string) (*models.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	task, ok := m.tasks[taskId]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	task = cloneTask(task)
+	return &task, nil
+}
+
+func (m *MemoryStore) UpdateTask(taskId string, task *models.Task) (*models.Task, error) {
+	m.mu.Lock()
+	existing, ok := m.tasks[taskId]
+	if ok && task.Version != 0 && existing.Version != task.Version {
+		m.mu.Unlock()
+		return nil, ErrVersionConflict
+	}
+	if ok {
+		existing.Title = task.Title
+		existing.Description = task.Description
+		existing.Priority = task.Priority
+		existing.PriorityPinned = task.PriorityPinned
+		existing.DueDate = task.DueDate
+		existing.ParentID = task.ParentID
+		existing.Recurrence = task.Recurrence
+		existing.Project = task.Project
+		existing.SeriesID = task.SeriesID
+		existing.Occurrence = task.Occurrence
+		existing.OwnerID = task.OwnerID
+		existing.UpdatedAt = task.UpdatedAt
+		existing.UpdatedBy = task.UpdatedBy
+		existing.Labels = normalizeLabels(task.Labels)
+		existing.Version++
+		m.tasks[taskId] = cloneTask(existing)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	return m.GetTaskByID(taskId)
+}
+
+func (m *MemoryStore) UpdateTaskPriority(taskID string, newPriority string, updatedAt models.Timestamp) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.tasks[taskID]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	p := models.Priority(newPriority)
+	task.Priority, task.PriorityPinned = &p, true
+	task.UpdatedAt = updatedAt
+	task.Version++
+	m.tasks[taskID] = task
+	return nil
+}
+
+func (m *MemoryStore) UpdateTaskStatus(taskID string, from models.Status, task *models.Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.tasks[taskID]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if existing.Status != from {
+		return ErrStatusConflict
+	}
+	existing.Status = task.Status
+	existing.StartedAt = task.StartedAt
+	existing.CompletedAt = task.CompletedAt
+	existing.UpdatedAt = task.UpdatedAt
+	existing.UpdatedBy = task.UpdatedBy
+	existing.Version++
+	m.tasks[taskID] = cloneTask(existing)
+	return nil
+}
+
+func (m *MemoryStore) MarkTaskOverdue(taskID string, priority *models.Priority) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.tasks[taskID]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	task.IsOverdue = true
+	task.Priority = priority
+	task.Version++
+	m.tasks[taskID] = cloneTask(task)
+	return nil
+}
+
+func (m *MemoryStore) RefreshTaskPriority(taskID string, priority *models.Priority) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.tasks[taskID]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if task.PriorityPinned {
+		return nil
+	}
+	task.Priority = priority
+	task.Version++
+	m.tasks[taskID] = cloneTask(task)
+	return nil
+}
+
+func (m *MemoryStore) GetSubtree(rootID string) ([]models.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	root, ok := m.tasks[rootID]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	tasks := []models.Task{cloneTask(root)}
+	for i := 0; i < len(tasks); i++ {
+		for _, task := range m.tasks {
+			if task.ParentID != nil && *task.ParentID == tasks[i].ID {
+				tasks = append(tasks, cloneTask(task))
+			}
+		}
+	}
+	return tasks, nil
+}
+
+func (m *MemoryStore) CountChildrenByStatus(parentIDs []string) (map[string]map[models.Status]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		wanted[id] = true
+	}
+	counts := map[string]map[models.Status]int{}
+	for _, task := range m.tasks {
+		if task.ParentID == nil || !wanted[*task.ParentID] {
+			continue
+		}
+		if counts[*task.ParentID] == nil {
+			counts[*task.ParentID] = map[models.Status]int{}
+		}
+		counts[*task.ParentID][task.Status]++
+	}
+	return counts, nil
+}
+
+func (m *MemoryStore) GetSeries(seriesID string) ([]models.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tasks := []models.Task{}
+	for _, task := range m.tasks {
+		if task.SeriesID != nil && *task.SeriesID == seriesID {
+			tasks = append(tasks, cloneTask(task))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Occurrence < tasks[j].Occurrence })
+	return tasks, nil
+}
+
+func (m *MemoryStore) AddDependency(dep models.Dependency) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deps = append(m.deps, dep)
+	return nil
+}
+
+func (m *MemoryStore) RemoveDependency(taskID, dependsOnID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, dep := range m.deps {
+		if dep.TaskID == taskID && dep.DependsOnID == dependsOnID {
+			m.deps = append(m.deps[:i], m.deps[i+1:]...)
+			return nil
+		}
+	}
+	return ErrDependencyNotFound
+}
+
+func (m *MemoryStore) ListDependencies() ([]models.Dependency, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]models.Dependency{}, m.deps...), nil
+}
+
+// pruneDependencies drops the edges and grants of deleted tasks, like ON
+// DELETE CASCADE does for the SQL stores. The caller holds the write lock.
+func (m *MemoryStore) pruneDependencies() {
+	for id, grant := range m.grants {
+		if grant.TaskID == nil {
+			continue
+		}
+		if _, ok := m.tasks[*grant.TaskID]; !ok {
+			delete(m.grants, id)
+		}
+	}
+
+	kept := m.deps[:0]
+	for _, dep := range m.deps {
+		_, from := m.tasks[dep.TaskID]
+		_, to := m.tasks[dep.DependsOnID]
+		if from && to {
+			kept = append(kept, dep)
+		}
+	}
+	m.deps = kept
+}
+
+func (m *MemoryStore) DeleteTask(taskId string, version int64, cascade bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted, ok := m.tasks[taskId]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if version != 0 && deleted.Version != version {
+		return ErrVersionConflict
+	}
+	delete(m.tasks, taskId)
+	defer m.pruneDependencies()
+
+	if !cascade {
+		for id, task := range m.tasks {
+			if task.ParentID != nil && *task.ParentID == taskId {
+				task.ParentID = deleted.ParentID
+				task.Version++
+				m.tasks[id] = cloneTask(task)
+			}
+		}
+		return nil
+	}
+
+	// Keep sweeping until no task points at a deleted parent
+	removed := map[string]bool{taskId: true}
+	for changed := true; changed; {
+		changed = false
+		for id, task := range m.tasks {
+			if task.ParentID != nil && removed[*task.ParentID] {
+				removed[id] = true
+				delete(m.tasks, id)
+				changed = true
+			}
+		}
+	}
+	return nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func (m *MemoryStore) CreateGrant(grant *models.Grant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.grants[grant.ID] = *grant
+	return nil
+}
+
+func (m *MemoryStore) UpdateGrantRole(id string, role models.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	grant, ok := m.grants[id]
+	if !ok {
+		return ErrGrantNotFound
+	}
+	grant.Role = role
+	m.grants[id] = grant
+	return nil
+}
+
+func (m *MemoryStore) GetGrant(id string) (*models.Grant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	grant, ok := m.grants[id]
+	if !ok {
+		return nil, ErrGrantNotFound
+	}
+	return &grant, nil
+}
+
+func (m *MemoryStore) ListGrants(filter GrantFilter) ([]models.Grant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	grants := []models.Grant{}
+	for _, grant := range m.grants {
+		if (filter.UserID == "" || grant.UserID == filter.UserID) &&
+			(filter.Project == "" || derefString(grant.Project) == filter.Project) &&
+			(filter.TaskID == "" || derefString(grant.TaskID) == filter.TaskID) {
+			grants = append(grants, grant)
+		}
+	}
+	sort.Slice(grants, func(i, j int) bool {
+		if !grants[i].CreatedAt.Equal(grants[j].CreatedAt.Time) {
+			return grants[i].CreatedAt.Before(grants[j].CreatedAt.Time)
+		}
+		return grants[i].ID < grants[j].ID
+	})
+	return grants, nil
+}
+
+func (m *MemoryStore) DeleteGrant(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.grants[id]; !ok {
+		return ErrGrantNotFound
+	}
+	delete(m.grants, id)
+	return nil
+}
+
+func (m *MemoryStore) CreateIdempotencyRecord(record *IdempotencyRecord) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := [2]string{record.Scope, record.Key}
+	if _, ok := m.idempotency[id]; ok {
+		return false, nil
+	}
+	m.idempotency[id] = *record
+	return true, nil
+}
+
+func (m *MemoryStore) GetIdempotencyRecord(scope, key string) (*IdempotencyRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.idempotency[[2]string{scope, key}]
+	if !ok {
+		return nil, ErrIdempotencyRecordNotFound
+	}
+	return &record, nil
+}
+
+func (m *MemoryStore) CompleteIdempotencyRecord(scope, key string, statusCode int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := [2]string{scope, key}
+	if record, ok := m.idempotency[id]; ok {
+		record.StatusCode, record.ContentType = statusCode, contentType
+		record.Body = append([]byte{}, body...)
+		m.idempotency[id] = record
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteIdempotencyRecord(scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.idempotency, [2]string{scope, key})
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpiredIdempotencyRecords(now models.Timestamp) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, record := range m.idempotency {
+		if record.ExpiresAt.Before(now.Time) {
+			delete(m.idempotency, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateUser(user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[user.ID] = *user
+	return nil
+}
+
+func (m *MemoryStore) GetUserByID(id string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (m *MemoryStore) GetUserByName(name string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, user := range m.users {
+		if user.Name == name {
+			return &user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (m *MemoryStore) ListUsers() ([]models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	users := make([]models.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+func (m *MemoryStore) CreateAPIKey(key *models.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key.ID] = *key
+	return nil
+}
+
+func (m *MemoryStore) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			return &key, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (m *MemoryStore) ListAPIKeys(userID string) ([]models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []models.APIKey{}
+	for _, key := range m.keys {
+		if userID == "" || key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt.Time) })
+	return keys, nil
+}
+
+func (m *MemoryStore) RevokeAPIKey(id string, at models.Timestamp) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if key.RevokedAt.IsZero() {
+		key.RevokedAt = at
+		m.keys[id] = key
+	}
+	return nil
+}
+
+func (m *MemoryStore) TouchAPIKey(id string, at models.Timestamp) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if key, ok := m.keys[id]; ok {
+		key.LastUsedAt = at
+		m.keys[id] = key
+	}
+	return nil
+}
+
+func (m *MemoryStore) SetCalendarToken(userID, hash string, at models.Timestamp) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calendarTokens[userID] = hash
+	return nil
+}
+
+func (m *MemoryStore) GetCalendarTokenUser(hash string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for userID, stored := range m.calendarTokens {
+		if stored == hash {
+			return userID, nil
+		}
+	}
+	return "", ErrInvalidCalendarToken
+}
+
+func (m *MemoryStore) DeleteCalendarToken(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.calendarTokens, userID)
+	return nil
+}
+
+func (m *MemoryStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := models.Now()
+	lease := Lease{Name: name, Holder: holder, AcquiredAt: now, ExpiresAt: models.NewTimestamp(now.Add(ttl))}
+	current, ok := m.leases[lease.Name]
+	if ok && current.Holder != lease.Holder && !current.ExpiresAt.Before(lease.AcquiredAt.Time) {
+		return false, nil
+	}
+	if ok && current.Holder == lease.Holder {
+		lease.AcquiredAt = current.AcquiredAt
+	}
+	m.leases[lease.Name] = lease
+	return true, nil
+}
+
+func (m *MemoryStore) ReleaseLease(name, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if lease, ok := m.leases[name]; ok && lease.Holder == holder {
+		delete(m.leases, name)
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetLease(name string) (*Lease, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	lease, ok := m.leases[name]
+	if !ok {
+		return nil, ErrLeaseNotFound
+	}
+	return &lease, nil
+}
+
+// Transaction runs fn against a copy of the store and keeps the copy's
+// contents when fn returns nil. The store is locked throughout, so other
+// callers wait for the transaction instead of seeing part of it.
+func (m *MemoryStore) Transaction(fn func(TaskStore) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Stored values are replaced, never changed in place, so shallow copies of
+	// the maps are enough to keep the copy apart from the store
+	work := &MemoryStore{
+		tasks:       make(map[string]models.Task, len(m.tasks)),
+		deps:        append([]models.Dependency{}, m.deps...),
+		users:       make(map[string]models.User, len(m.users)),
+		keys:        make(map[string]models.APIKey, len(m.keys)),
+		grants:      make(map[string]models.Grant, len(m.grants)),
+		idempotency: make(map[[2]string]IdempotencyRecord, len(m.idempotency)),
+
+		calendarTokens: make(map[string]string, len(m.calendarTokens)),
+		leases:         make(map[string]Lease, len(m.leases)),
+	}
+	for id, task := range m.tasks {
+		work.tasks[id] = task
+	}
+	for id, user := range m.users {
+		work.users[id] = user
+	}
+	for id, key := range m.keys {
+		work.keys[id] = key
+	}
+	for id, grant := range m.grants {
+		work.grants[id] = grant
+	}
+	for id, record := range m.idempotency {
+		work.idempotency[id] = record
+	}
+	for id, hash := range m.calendarTokens {
+		work.calendarTokens[id] = hash
+	}
+	for name, lease := range m.leases {
+		work.leases[name] = lease
+	}
+
+	if err := fn(work); err != nil {
+		return err
+	}
+	m.tasks, m.deps, m.users, m.keys, m.grants, m.idempotency = work.tasks, work.deps, work.users, work.keys, work.grants, work.idempotency
+	m.calendarTokens, m.leases = work.calendarTokens, work.leases
+	return nil
+}
+
+// Close is a no-op for the in-memory store.
+func (m *MemoryStore) Close() error {
+	return nil
+}
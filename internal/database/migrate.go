@@ -0,0 +1,430 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationFileName matches "0001_create_tasks.up.sql" style names.
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// postgresMigrationLockID is the pg_advisory_lock key that serializes concurrent migrators.
+const postgresMigrationLockID = 72_617_001
+
+// ErrSchemaTooNew is returned when the database has migrations applied that this binary does not know about.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// Migration is one embedded schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus reports whether a migration has been applied and whether its script still matches.
+type MigrationStatus struct {
+	Version          int    `json:"version"`
+	Name             string `json:"name"`
+	Applied          bool   `json:"applied"`
+	AppliedAt        string `json:"applied_at,omitempty"`
+	ChecksumMismatch bool   `json:"checksum_mismatch"`
+	Unknown          bool   `json:"unknown"` // Applied in the database but not embedded in this binary
+}
+
+// appliedMigration is a row of the schema_migrations table.
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt string
+}
+
+// Migrator applies the embedded migrations for one SQL dialect.
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// LoadMigrations reads the embedded migrations for dialect, ordered by version.
+func LoadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %v", dialect, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// NewMigrator returns a Migrator for db using the embedded migrations of dialect.
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := LoadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// MigratorFor returns the Migrator of a SQL-backed store.
+func MigratorFor(s TaskStore) (*Migrator, error) {
+	sqlBacked, ok := s.(interface{ Migrator() (*Migrator, error) })
+	if !ok {
+		return nil, fmt.Errorf("the %T backend has no schema to migrate", s)
+	}
+	return sqlBacked.Migrator()
+}
+
+// Migrations returns the embedded migrations, ordered by version.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// LatestVersion is the highest version embedded in this binary.
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// CurrentVersion is the highest version recorded in schema_migrations.
+func (m *Migrator) CurrentVersion() (int, error) {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	return highestVersion(applied), nil
+}
+
+// Status lists every embedded migration plus any unknown ones found in the database.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, mig := range m.migrations {
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if row, ok := applied[mig.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.AppliedAt
+			status.ChecksumMismatch = row.Checksum != mig.Checksum
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: row.AppliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Verify fails when the database is ahead of this binary or an applied script was edited afterwards.
+func (m *Migrator) Verify() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.Unknown {
+			return fmt.Errorf("%w: version %d (%s) is applied but this binary only knows up to version %d", ErrSchemaTooNew, status.Version, status.Name, m.LatestVersion())
+		}
+		if status.ChecksumMismatch {
+			return fmt.Errorf("checksum mismatch for migration %d (%s): the script changed after it was applied", status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up() (int, error) {
+	return m.To(m.LatestVersion())
+}
+
+// Down rolls back the given number of most recent migrations.
+func (m *Migrator) Down(steps int) (int, error) {
+	if steps < 1 {
+		return 0, fmt.Errorf("steps must be at least 1")
+	}
+	current, err := m.CurrentVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	target := 0
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if m.migrations[i].Version > current {
+			continue
+		}
+		count++
+		if count > steps {
+			target = m.migrations[i].Version
+			break
+		}
+	}
+	return m.To(target)
+}
+
+// To migrates up or down until version is the newest applied migration, and
+// returns how many migrations ran.
+func (m *Migrator) To(version int) (int, error) {
+	if version < 0 || version > m.LatestVersion() {
+		return 0, fmt.Errorf("unknown target version %d. Latest version is %d", version, m.LatestVersion())
+	}
+	if version != 0 && m.find(version) == nil {
+		return 0, fmt.Errorf("unknown target version %d", version)
+	}
+	if err := m.Verify(); err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	// Re-read under the lock so a concurrent migrator's work is not repeated.
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	ran := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok || mig.Version > version {
+			continue
+		}
+		if err := m.apply(ctx, conn, mig); err != nil {
+			return ran, err
+		}
+		ran++
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok || mig.Version <= version {
+			continue
+		}
+		if err := m.revert(ctx, conn, mig); err != nil {
+			return ran, err
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+// EnsureSchema is run at startup: it refuses a schema newer than the binary,
+// applies pending migrations when autoMigrate is set, and otherwise refuses an
+// out-of-date schema.
+func (m *Migrator) EnsureSchema(autoMigrate bool) error {
+	if err := m.Verify(); err != nil {
+		return err
+	}
+	current, err := m.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	if current == m.LatestVersion() {
+		log.Printf("Database schema is up to date (version %d).\n", current)
+		return nil
+	}
+	if !autoMigrate {
+		return fmt.Errorf("database schema is at version %d but this binary needs version %d; run the migrate up command", current, m.LatestVersion())
+	}
+
+	ran, err := m.Up()
+	if err != nil {
+		return err
+	}
+	log.Printf("Applied %d migration(s); database schema is now at version %d.\n", ran, m.LatestVersion())
+	return nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		)`)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var row appliedMigration
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = row
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %v", mig.Version, mig.Name, err)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)`,
+		mig.Version, mig.Name, mig.Checksum, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Applied migration %d (%s)\n", mig.Version, mig.Name)
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("migration %d (%s) has no down script", mig.Version, mig.Name)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+		return fmt.Errorf("rollback of migration %d (%s) failed: %v", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Rolled back migration %d (%s)\n", mig.Version, mig.Name)
+	return nil
+}
+
+// lock serializes migrators across processes. SQLite already allows a single
+// writer, so only Postgres needs an explicit advisory lock.
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if m.dialect != DriverPostgres {
+		return func() {}, nil
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationLockID); err != nil {
+		return nil, fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, postgresMigrationLockID)
+	}, nil
+}
+
+func highestVersion(applied map[int]appliedMigration) int {
+	highest := 0
+	for version := range applied {
+		if version > highest {
+			highest = version
+		}
+	}
+	return highest
+}
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS tasks_due_date_idx;
+ALTER TABLE tasks ALTER COLUMN is_overdue DROP NOT NULL;
+
+ALTER TABLE tasks
+    ALTER COLUMN due_date TYPE TEXT USING to_char(due_date AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+    ALTER COLUMN created_at TYPE TEXT USING to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+    ALTER COLUMN updated_at TYPE TEXT USING to_char(updated_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"');
+
+ALTER TABLE tasks ADD COLUMN labels TEXT;
+UPDATE tasks t SET labels = (
+    SELECT string_agg(l.label, ',' ORDER BY l.position)
+    FROM task_labels l
+    WHERE l.task_id = t.id
+);
+
+DROP TABLE task_labels;
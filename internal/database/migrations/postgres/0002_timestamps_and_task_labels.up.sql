@@ -0,0 +1,29 @@
+-- Labels move from a comma-joined TEXT column to their own table. position
+-- keeps the order clients sent them in.
+CREATE TABLE task_labels (
+    task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    label TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (task_id, label)
+);
+CREATE INDEX task_labels_label_idx ON task_labels (label);
+
+INSERT INTO task_labels (task_id, label, position)
+SELECT t.id, btrim(l.label), MIN(l.ord) - 1
+FROM tasks t
+CROSS JOIN LATERAL unnest(string_to_array(t.labels, ',')) WITH ORDINALITY AS l(label, ord)
+WHERE btrim(l.label) <> ''
+GROUP BY t.id, btrim(l.label);
+
+ALTER TABLE tasks DROP COLUMN labels;
+
+-- Timestamps were written as RFC 3339 text, which Postgres casts directly.
+ALTER TABLE tasks
+    ALTER COLUMN due_date TYPE TIMESTAMPTZ USING NULLIF(btrim(due_date), '')::timestamptz,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING NULLIF(btrim(created_at), '')::timestamptz,
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING NULLIF(btrim(updated_at), '')::timestamptz;
+
+UPDATE tasks SET is_overdue = FALSE WHERE is_overdue IS NULL;
+ALTER TABLE tasks ALTER COLUMN is_overdue SET NOT NULL;
+
+CREATE INDEX tasks_due_date_idx ON tasks (due_date);
@@ -0,0 +1,5 @@
+ALTER TABLE tasks ADD COLUMN status TEXT NOT NULL DEFAULT 'todo';
+ALTER TABLE tasks ADD COLUMN started_at TIMESTAMPTZ;
+ALTER TABLE tasks ADD COLUMN completed_at TIMESTAMPTZ;
+
+CREATE INDEX tasks_status_idx ON tasks (status);
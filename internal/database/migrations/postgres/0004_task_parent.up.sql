@@ -0,0 +1,5 @@
+-- Subtasks point at their parent. The store re-parents or deletes children
+-- explicitly; the cascade only guards against orphans.
+ALTER TABLE tasks ADD COLUMN parent_id TEXT REFERENCES tasks(id) ON DELETE CASCADE;
+
+CREATE INDEX tasks_parent_id_idx ON tasks (parent_id);
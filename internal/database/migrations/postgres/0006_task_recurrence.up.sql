@@ -0,0 +1,7 @@
+-- Recurring tasks carry an RRULE. Every occurrence of a series shares
+-- series_id (the ID of the first occurrence) and is numbered by occurrence.
+ALTER TABLE tasks ADD COLUMN recurrence TEXT;
+ALTER TABLE tasks ADD COLUMN series_id TEXT;
+ALTER TABLE tasks ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX tasks_series_occurrence_idx ON tasks (series_id, occurrence);
@@ -0,0 +1,3 @@
+-- Every write to a task bumps its version; the API serves it as the ETag and
+-- compares it on If-Match so concurrent edits do not overwrite each other.
+ALTER TABLE tasks ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
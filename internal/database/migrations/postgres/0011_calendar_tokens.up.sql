@@ -0,0 +1,7 @@
+-- Secret tokens of subscribable calendar feeds, one per user. Only a digest
+-- of the token is kept, like for API keys.
+CREATE TABLE calendar_tokens (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL
+);
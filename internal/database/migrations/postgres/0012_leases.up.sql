@@ -0,0 +1,9 @@
+-- Leases let one of several servers sharing the database take on a job, e.g.
+-- running the background work. A lease lapses at expires_at unless its holder
+-- renews it, and another server may then take it.
+CREATE TABLE leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
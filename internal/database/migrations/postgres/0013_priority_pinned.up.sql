@@ -0,0 +1,3 @@
+-- Set when a client chose the task's priority, which the priority policy may
+-- then leave alone; otherwise the policy works the priority out.
+ALTER TABLE tasks ADD COLUMN priority_pinned BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,13 @@
+-- Baseline schema. IF NOT EXISTS lets databases created before migrations
+-- existed adopt version 1 without changes.
+CREATE TABLE IF NOT EXISTS tasks (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT,
+    priority TEXT,
+    due_date TEXT,
+    labels TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    is_overdue BOOLEAN DEFAULT FALSE
+);
@@ -0,0 +1,25 @@
+ALTER TABLE tasks RENAME TO tasks_v2;
+
+CREATE TABLE tasks (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT,
+    priority TEXT,
+    due_date TEXT,
+    labels TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    is_overdue BOOLEAN DEFAULT FALSE
+);
+
+INSERT INTO tasks (id, title, description, priority, due_date, labels, created_at, updated_at, is_overdue)
+SELECT t.id, t.title, t.description, t.priority,
+       strftime('%Y-%m-%dT%H:%M:%SZ', t.due_date),
+       (SELECT group_concat(label, ',') FROM (SELECT label FROM task_labels WHERE task_id = t.id ORDER BY position)),
+       strftime('%Y-%m-%dT%H:%M:%SZ', t.created_at),
+       strftime('%Y-%m-%dT%H:%M:%SZ', t.updated_at),
+       t.is_overdue
+FROM tasks_v2 t;
+
+DROP TABLE task_labels;
+DROP TABLE tasks_v2;
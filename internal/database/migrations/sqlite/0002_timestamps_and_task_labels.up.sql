@@ -0,0 +1,53 @@
+-- SQLite cannot change column types in place, so the tasks table is rebuilt.
+-- Timestamps are stored as UTC "YYYY-MM-DD HH:MM:SS+00:00" text, which sorts
+-- chronologically and is what the driver writes for time values.
+ALTER TABLE tasks RENAME TO tasks_v1;
+
+CREATE TABLE tasks (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT,
+    priority TEXT,
+    due_date TIMESTAMP,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    is_overdue BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO tasks (id, title, description, priority, due_date, created_at, updated_at, is_overdue)
+SELECT id, title, description, priority,
+       strftime('%Y-%m-%d %H:%M:%S', NULLIF(trim(due_date), '')) || '+00:00',
+       strftime('%Y-%m-%d %H:%M:%S', NULLIF(trim(created_at), '')) || '+00:00',
+       strftime('%Y-%m-%d %H:%M:%S', NULLIF(trim(updated_at), '')) || '+00:00',
+       COALESCE(is_overdue, FALSE)
+FROM tasks_v1;
+
+-- Labels move from a comma-joined TEXT column to their own table. position
+-- keeps the order clients sent them in.
+CREATE TABLE task_labels (
+    task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    label TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (task_id, label)
+);
+CREATE INDEX task_labels_label_idx ON task_labels (label);
+
+WITH RECURSIVE split(task_id, label, rest, position) AS (
+    SELECT id, NULL, labels || ',', -1 FROM tasks_v1 WHERE labels IS NOT NULL AND labels <> ''
+    UNION ALL
+    SELECT task_id,
+           trim(substr(rest, 1, instr(rest, ',') - 1)),
+           substr(rest, instr(rest, ',') + 1),
+           position + 1
+    FROM split
+    WHERE rest <> ''
+)
+INSERT INTO task_labels (task_id, label, position)
+SELECT task_id, label, MIN(position)
+FROM split
+WHERE label IS NOT NULL AND label <> ''
+GROUP BY task_id, label;
+
+DROP TABLE tasks_v1;
+
+CREATE INDEX tasks_due_date_idx ON tasks (due_date);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS tasks_status_idx;
+
+ALTER TABLE tasks DROP COLUMN completed_at;
+ALTER TABLE tasks DROP COLUMN started_at;
+ALTER TABLE tasks DROP COLUMN status;
@@ -0,0 +1,5 @@
+-- Subtasks point at their parent. SQLite cannot drop a column that carries a
+-- foreign key, so the reference is enforced by the store instead of the schema.
+ALTER TABLE tasks ADD COLUMN parent_id TEXT;
+
+CREATE INDEX tasks_parent_id_idx ON tasks (parent_id);
@@ -0,0 +1,10 @@
+-- task_id cannot start until depends_on_id is finished. The graph is kept
+-- acyclic by the application when edges are added.
+CREATE TABLE task_dependencies (
+    task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    depends_on_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (task_id, depends_on_id),
+    CHECK (task_id <> depends_on_id)
+);
+CREATE INDEX task_dependencies_depends_on_idx ON task_dependencies (depends_on_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS tasks_owner_id_idx;
+ALTER TABLE tasks DROP COLUMN updated_by;
+ALTER TABLE tasks DROP COLUMN created_by;
+ALTER TABLE tasks DROP COLUMN owner_id;
+
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,27 @@
+CREATE TABLE users (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Only a SHA-256 digest of each key is stored; prefix identifies a key in
+-- listings without revealing it.
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+-- As with parent_id, SQLite could not drop these columns again if they
+-- carried foreign keys, so the references are not declared.
+ALTER TABLE tasks ADD COLUMN owner_id TEXT;
+ALTER TABLE tasks ADD COLUMN created_by TEXT;
+ALTER TABLE tasks ADD COLUMN updated_by TEXT;
+CREATE INDEX tasks_owner_id_idx ON tasks (owner_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS role_grants;
+
+DROP INDEX IF EXISTS tasks_project_idx;
+ALTER TABLE tasks DROP COLUMN project;
@@ -0,0 +1,19 @@
+-- Tasks can belong to a project; roles can be granted on a whole project.
+ALTER TABLE tasks ADD COLUMN project TEXT;
+CREATE INDEX tasks_project_idx ON tasks (project);
+
+-- A grant gives a user a role (viewer, editor or admin) everywhere, on one
+-- project or on one task. At most one of project and task_id is set; neither
+-- means a global grant. The application keeps one grant per user and scope.
+CREATE TABLE role_grants (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor', 'admin')),
+    project TEXT,
+    task_id TEXT REFERENCES tasks(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    created_by TEXT,
+    CHECK (project IS NULL OR task_id IS NULL)
+);
+CREATE INDEX role_grants_user_id_idx ON role_grants (user_id);
+CREATE INDEX role_grants_task_id_idx ON role_grants (task_id);
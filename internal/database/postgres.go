@@ -0,0 +1,34 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// PostgresStore is the TaskStore backed by a PostgreSQL server.
+type PostgresStore struct {
+	*sqlStore
+}
+
+// NewPostgresStore connects to databaseURL. The schema is managed by the Migrator.
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	// Open the database connection
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Printf("Failed to connect to the database: %v\n", err)
+		return nil, err
+	}
+
+	// Test the database connection
+	err = db.Ping()
+	if err != nil {
+		log.Printf("Failed to ping the database: %v\n", err)
+		db.Close()
+		return nil, err
+	}
+	log.Println("Database connection established successfully.")
+
+	return &PostgresStore{sqlStore: &sqlStore{db: db, dialect: DriverPostgres}}, nil
+}
@@ -0,0 +1,390 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded or
+// does not belong to the requested sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// missingTimestamp stands in for unset timestamps when sorting, so tasks
+// without a due date sort after every dated task in ascending order.
+var missingTimestamp = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// TaskPage is one page of a task listing. The cursors are empty when there is
+// no page in that direction.
+type TaskPage struct {
+	Tasks      []models.Task
+	NextCursor string
+	PrevCursor string
+}
+
+// pageCursor is the decoded form of the opaque cursor handed to clients. It
+// records the sort key and ID of the row the page starts after.
+type pageCursor struct {
+	Sort     string      `json:"s"`
+	Order    string      `json:"o"`
+	Key      interface{} `json:"k"`
+	ID       string      `json:"id"`
+	Backward bool        `json:"b,omitempty"`
+}
+
+func encodeCursor(query models.TaskQuery, task models.Task, backward bool) string {
+	key := sortKey(task, query.Sort)
+	if t, ok := key.(time.Time); ok {
+		key = t.Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(pageCursor{Sort: query.Sort, Order: query.Order, Key: key, ID: task.ID, Backward: backward})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns nil when the query has no cursor.
+func decodeCursor(query models.TaskQuery) (*pageCursor, error) {
+	if query.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cur pageCursor
+	if err := json.Unmarshal(data, &cur); err != nil || cur.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if cur.Sort != query.Sort || cur.Order != query.Order {
+		return nil, fmt.Errorf("%w: it was issued for sort=%s&order=%s", ErrInvalidCursor, cur.Sort, cur.Order)
+	}
+
+	// Restore the key to the Go type sortKey produces for this field; a key of
+	// another type would not compare with the keys of the tasks
+	switch sortKey(models.Task{}, cur.Sort).(type) {
+	case int:
+		value, ok := cur.Key.(float64)
+		if !ok || value != float64(int(value)) {
+			return nil, ErrInvalidCursor
+		}
+		cur.Key = int(value)
+	case time.Time:
+		value, ok := cur.Key.(string)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cur.Key = t
+	case string:
+		if _, ok := cur.Key.(string); !ok {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cur, nil
+}
+
+// sortKey is the value a task is ordered by for field. It matches the SQL
+// expression built by sortExpression.
+func sortKey(task models.Task, field string) interface{} {
+	timeKey := func(t models.Timestamp) time.Time {
+		if t.IsZero() {
+			return missingTimestamp
+		}
+		return t.Time.UTC()
+	}
+
+	switch field {
+	case "priority":
+		return priorityRank(task.Priority)
+	case "due_date":
+		return timeKey(task.DueDate)
+	case "created_at":
+		return timeKey(task.CreatedAt)
+	case "updated_at":
+		return timeKey(task.UpdatedAt)
+	case "title":
+		return task.Title
+	case "description":
+		return task.Description
+	case "status":
+		return string(task.Status)
+	case "is_overdue":
+		if task.IsOverdue {
+			return 1
+		}
+		return 0
+	}
+	return task.ID
+}
+
+func compareKeys(a, b interface{}) int {
+	switch av := a.(type) {
+	case int:
+		bv := b.(int)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case time.Time:
+		return av.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(av, b.(string))
+	}
+	return 0
+}
+
+// compareTasks orders two tasks by the query's sort key, then by ID.
+func compareTasks(query models.TaskQuery, a, b models.Task) int {
+	c := compareKeys(sortKey(a, query.Sort), sortKey(b, query.Sort))
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if query.Order == models.SortDesc {
+		c = -c
+	}
+	return c
+}
+
+// finishPage trims rows fetched with limit+1 down to one page and works out
+// the cursors. Rows read backwards are restored to display order first.
+func finishPage(query models.TaskQuery, cur *pageCursor, rows []models.Task) *TaskPage {
+	backward := cur != nil && cur.Backward
+	hasMore := len(rows) > query.Limit
+	if hasMore {
+		rows = rows[:query.Limit]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if rows == nil {
+		rows = []models.Task{}
+	}
+	page := &TaskPage{Tasks: rows}
+	if len(rows) == 0 {
+		return page
+	}
+	// Moving forward there is a previous page whenever we started from a
+	// cursor; moving backward there is always a next page.
+	if (!backward && hasMore) || backward {
+		page.NextCursor = encodeCursor(query, rows[len(rows)-1], false)
+	}
+	if (backward && hasMore) || (!backward && cur != nil) {
+		page.PrevCursor = encodeCursor(query, rows[0], true)
+	}
+	return page
+}
+
+// pageInMemory applies a query to an already loaded task list.
+func pageInMemory(tasks []models.Task, query models.TaskQuery) (*TaskPage, error) {
+	cur, err := decodeCursor(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []models.Task
+	for _, task := range tasks {
+		if query.Matches(task) {
+			matched = append(matched, task)
+		}
+	}
+
+	backward := cur != nil && cur.Backward
+	sort.SliceStable(matched, func(i, j int) bool {
+		c := compareTasks(query, matched[i], matched[j])
+		if backward {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	var rows []models.Task
+	for _, task := range matched {
+		if cur != nil {
+			c := compareKeys(sortKey(task, query.Sort), cur.Key)
+			if c == 0 {
+				c = strings.Compare(task.ID, cur.ID)
+			}
+			if query.Order == models.SortDesc {
+				c = -c
+			}
+			if (!backward && c <= 0) || (backward && c >= 0) {
+				continue
+			}
+		}
+		rows = append(rows, task)
+		if len(rows) > query.Limit {
+			break
+		}
+	}
+	return finishPage(query, cur, rows), nil
+}
+
+// sqlBuilder collects positional arguments while a statement is assembled.
+type sqlBuilder struct {
+	args []interface{}
+}
+
+// arg registers value and returns its $N placeholder.
+func (b *sqlBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *sqlBuilder) list(values []string) string {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = b.arg(value)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// sortExpression is the SQL counterpart of sortKey.
+func (b *sqlBuilder) sortExpression(field string) string {
+	switch field {
+	case "priority":
+		return `CASE WHEN priority = 'High' THEN 1 WHEN priority = 'Medium' THEN 2 WHEN priority = 'Low' THEN 3 ELSE 4 END`
+	case "due_date", "created_at", "updated_at":
+		return fmt.Sprintf("COALESCE(%s, %s)", field, b.arg(missingTimestamp))
+	case "title", "description":
+		return fmt.Sprintf("COALESCE(%s, '')", field)
+	case "status":
+		return "status"
+	case "is_overdue":
+		return `CASE WHEN is_overdue THEN 1 ELSE 0 END`
+	}
+	return "id"
+}
+
+// visibility matches the tasks an identity may see: its own and those covered
+// by one of its project or task grants (see models.Identity.RoleOn).
+func (b *sqlBuilder) visibility(identity *models.Identity) string {
+	visible := []string{"owner_id = " + b.arg(identity.UserID)}
+	var taskIDs, projects []string
+	for _, grant := range identity.Grants {
+		switch {
+		case grant.TaskID != nil:
+			taskIDs = append(taskIDs, *grant.TaskID)
+		case grant.Project != nil:
+			projects = append(projects, *grant.Project)
+		}
+	}
+	if len(taskIDs) > 0 {
+		visible = append(visible, "id IN ("+b.list(taskIDs)+")")
+	}
+	if len(projects) > 0 {
+		visible = append(visible, "project IN ("+b.list(projects)+")")
+	}
+	return "(" + strings.Join(visible, " OR ") + ")"
+}
+
+// filterConditions translates a TaskFilter into WHERE conditions over the tasks table.
+func (b *sqlBuilder) filterConditions(filter models.TaskFilter) []string {
+	var conditions []string
+
+	if len(filter.Priorities) > 0 {
+		priorities := make([]string, len(filter.Priorities))
+		for i, p := range filter.Priorities {
+			priorities[i] = string(p)
+		}
+		conditions = append(conditions, "priority IN ("+b.list(priorities)+")")
+	}
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		conditions = append(conditions, "status IN ("+b.list(statuses)+")")
+	}
+
+	if labels := normalizeLabels(filter.Labels); len(labels) > 0 {
+		if filter.LabelMatch == models.LabelMatchAll {
+			conditions = append(conditions, fmt.Sprintf(
+				"(SELECT COUNT(DISTINCT l.label) FROM task_labels l WHERE l.task_id = tasks.id AND l.label IN (%s)) = %s",
+				b.list(labels), b.arg(len(labels))))
+		} else {
+			conditions = append(conditions,
+				"EXISTS (SELECT 1 FROM task_labels l WHERE l.task_id = tasks.id AND l.label IN ("+b.list(labels)+"))")
+		}
+	}
+
+	if filter.Overdue != nil {
+		conditions = append(conditions, "is_overdue = "+b.arg(*filter.Overdue))
+	}
+	if !filter.DueBefore.IsZero() {
+		conditions = append(conditions, "due_date < "+b.arg(filter.DueBefore))
+	}
+	if !filter.DueAfter.IsZero() {
+		conditions = append(conditions, "due_date > "+b.arg(filter.DueAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+b.arg(filter.CreatedBefore))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at > "+b.arg(filter.CreatedAfter))
+	}
+
+	if filter.ParentID != "" {
+		conditions = append(conditions, "parent_id = "+b.arg(filter.ParentID))
+	}
+	if filter.Project != "" {
+		conditions = append(conditions, "project = "+b.arg(filter.Project))
+	}
+	if filter.VisibleTo != nil && !filter.VisibleTo.IsAdmin {
+		conditions = append(conditions, b.visibility(filter.VisibleTo))
+	}
+
+	if filter.Search != "" {
+		pattern := b.arg("%" + escapeLike(strings.ToLower(filter.Search)) + "%")
+		conditions = append(conditions, fmt.Sprintf(
+			`(LOWER(title) LIKE %s ESCAPE '\' OR LOWER(COALESCE(description, '')) LIKE %s ESCAPE '\')`, pattern, pattern))
+	}
+	return conditions
+}
+
+// listQuery builds the SELECT for one page: filters, keyset condition, order and limit+1.
+func (b *sqlBuilder) listQuery(query models.TaskQuery, cur *pageCursor) string {
+	conditions := b.filterConditions(query.TaskFilter)
+
+	backward := cur != nil && cur.Backward
+	descending := query.Order == models.SortDesc
+	if backward {
+		descending = !descending
+	}
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if cur != nil {
+		key := b.arg(cur.Key)
+		id := b.arg(cur.ID)
+		expr := b.sortExpression(query.Sort)
+		conditions = append(conditions, fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s))", expr, comparison, key, expr, key, comparison, id))
+	}
+
+	statement := `SELECT ` + taskColumns + ` FROM tasks`
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", b.sortExpression(query.Sort), direction, direction, b.arg(query.Limit+1))
+	return statement
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
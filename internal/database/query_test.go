@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	zLogger "github.com/iabdulzahid/go-logger/logger"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// useStore makes s the store of the package functions for the length of the test.
+func useStore(t *testing.T, s TaskStore) {
+	t.Helper()
+	previous := store
+	SetStore(s)
+	t.Cleanup(func() { SetStore(previous) })
+}
+
+// TestListTasksEffectivePriority stores priorities the default policy has
+// since moved on from, as it does when due dates draw near, and checks that
+// once RefreshPriorities stored the new ones, priority filters and sorts go by
+// the priority the API shows.
+func TestListTasksEffectivePriority(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		useStore(t, s)
+		now := time.Now()
+		low, high := models.Low, models.High
+		tasks := []struct {
+			id       string
+			due      time.Duration
+			stored   *models.Priority
+			pinned   bool
+			expected models.Priority
+		}{
+			{"soon", time.Hour, &low, false, models.High},             // Stored Low, due-soon makes it High
+			{"later", 30 * 24 * time.Hour, &high, false, models.Low},  // Stored High, nothing matches any more
+			{"week", 100 * time.Hour, nil, false, models.Medium},      // due-this-week
+			{"pinned", 30 * 24 * time.Hour, &high, true, models.High}, // The user's choice stands
+		}
+		for i, task := range tasks {
+			created := models.Timestamp{Time: now.Add(time.Duration(i) * time.Second)}
+			err := s.CreateTask(&models.Task{ID: task.id, Title: task.id, DueDate: models.Timestamp{Time: now.Add(task.due)},
+				Priority: task.stored, PriorityPinned: task.pinned, Status: models.StatusTodo, Labels: []string{},
+				CreatedAt: created, UpdatedAt: created, Version: 1})
+			if err != nil {
+				t.Fatalf("create %s: %v", task.id, err)
+			}
+		}
+		if changed, err := RefreshPriorities(context.Background()); err != nil || changed != 3 {
+			t.Fatalf("RefreshPriorities = %d, %v; want 3 changed", changed, err)
+		}
+		if changed, err := RefreshPriorities(context.Background()); err != nil || changed != 0 {
+			t.Fatalf("RefreshPriorities again = %d, %v; want none changed", changed, err)
+		}
+		if pinned, _ := s.GetTaskByID("pinned"); pinned.Version != 1 {
+			t.Fatalf("pinned task was written: %+v", pinned)
+		}
+
+		list := func(query models.TaskQuery) string {
+			t.Helper()
+			if query.Order == "" {
+				query.Order = models.SortAsc
+			}
+			if query.Limit == 0 {
+				query.Limit = 10
+			}
+			var ids []string
+			for {
+				page, err := ListTasks(zLogger.Logger{}, query)
+				if err != nil {
+					t.Fatalf("ListTasks: %v", err)
+				}
+				for _, task := range page.Tasks {
+					ids = append(ids, task.ID+"="+string(*task.Priority))
+				}
+				if page.NextCursor == "" {
+					return strings.Join(ids, " ")
+				}
+				query.Cursor = page.NextCursor
+			}
+		}
+
+		tests := []struct {
+			name  string
+			query models.TaskQuery
+			want  string
+		}{
+			{"filter High", models.TaskQuery{TaskFilter: models.TaskFilter{Priorities: []models.Priority{models.High}}, Sort: "id"},
+				"pinned=High soon=High"},
+			{"filter Low", models.TaskQuery{TaskFilter: models.TaskFilter{Priorities: []models.Priority{models.Low}}, Sort: "id"},
+				"later=Low"},
+			{"sort by priority", models.TaskQuery{Sort: "priority"}, "pinned=High soon=High week=Medium later=Low"},
+			{"sort by priority, a page at a time", models.TaskQuery{Sort: "priority", Order: models.SortDesc, Limit: 1},
+				"later=Low week=Medium soon=High pinned=High"},
+		}
+		for _, tt := range tests {
+			if got := list(tt.query); got != tt.want {
+				t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+			}
+		}
+
+		var streamed []string
+		query := models.TaskQuery{TaskFilter: models.TaskFilter{Priorities: []models.Priority{models.High, models.Medium}}, Sort: "priority", Order: models.SortAsc}
+		err := StreamTasks(context.Background(), zLogger.Logger{}, query, func(task models.Task) error {
+			streamed = append(streamed, task.ID)
+			return nil
+		})
+		if got := strings.Join(streamed, " "); err != nil || got != "pinned soon week" {
+			t.Errorf("StreamTasks = %s, %v; want pinned soon week", got, err)
+		}
+	})
+}
+
+// TestListTasksCursorKeyType hands ListTasks cursors whose key does not have
+// the type of the sort field, as a client may forge them.
+func TestListTasksCursorKeyType(t *testing.T) {
+	cursor := func(sort string, key interface{}) string {
+		data, _ := json.Marshal(map[string]interface{}{"s": sort, "o": models.SortAsc, "k": key, "id": "t1"})
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		want   error
+	}{
+		{"string key for priority", "priority", cursor("priority", "x"), ErrInvalidCursor},
+		{"number key for title", "title", cursor("title", 5), ErrInvalidCursor},
+		{"number key for due_date", "due_date", cursor("due_date", 5), ErrInvalidCursor},
+		{"fractional key for priority", "priority", cursor("priority", 1.5), ErrInvalidCursor},
+		{"string key for title", "title", cursor("title", "Task t1"), nil},
+		{"number key for priority", "priority", cursor("priority", 2), nil},
+		{"timestamp key for due_date", "due_date", cursor("due_date", "2030-01-07T09:00:00Z"), nil},
+	}
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		useStore(t, s)
+		createTestTask(t, s, "t1", nil)
+		createTestTask(t, s, "t2", nil)
+		for _, tt := range tests {
+			query := models.TaskQuery{Sort: tt.sort, Order: models.SortAsc, Limit: 10, Cursor: tt.cursor}
+			if _, err := ListTasks(zLogger.Logger{}, query); !errors.Is(err, tt.want) {
+				t.Errorf("%s: ListTasks = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,326 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	zLogger "github.com/iabdulzahid/go-logger/logger"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/priority"
+	"github.com/iabdulzahid/golang_task_manager/internal/recurrence"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
+)
+
+// SeriesScope decides whether an edit or delete of a recurring task applies
+// to that occurrence only or to the whole series.
+type SeriesScope string
+
+const (
+	ScopeOccurrence SeriesScope = "occurrence"
+	ScopeSeries     SeriesScope = "series"
+)
+
+// ParseSeriesScope reads the ?scope= value of an edit or delete; empty means occurrence.
+func ParseSeriesScope(value string) (SeriesScope, error) {
+	switch SeriesScope(value) {
+	case "", ScopeOccurrence:
+		return ScopeOccurrence, nil
+	case ScopeSeries:
+		return ScopeSeries, nil
+	}
+	return "", fmt.Errorf("invalid scope: %s. Valid values are: [%s %s]", value, ScopeOccurrence, ScopeSeries)
+}
+
+// recurrenceMu serializes the creation of next occurrences, so a completion
+// and the monitor cannot both continue the same series.
+var recurrenceMu sync.Mutex
+
+// prepareRecurrence validates task.Recurrence and stores it in canonical form.
+// Errors wrap recurrence.ErrInvalidRule.
+func prepareRecurrence(task *models.Task) error {
+	task.Recurrence = strings.TrimSpace(task.Recurrence)
+	if task.Recurrence == "" {
+		return nil
+	}
+	rule, err := recurrence.Parse(task.Recurrence)
+	if err != nil {
+		return err
+	}
+	if task.DueDate.IsZero() {
+		return fmt.Errorf("%w: recurring tasks need a due_date", recurrence.ErrInvalidRule)
+	}
+	task.Recurrence = rule.String()
+	return nil
+}
+
+// SpawnNextOccurrence creates the occurrence that follows task, if task is the
+// latest occurrence of its series and the rule has one left. Occurrences that
+// already lie in the past are skipped, so a series that was left alone for a
+// while resumes at the next future date instead of piling up overdue copies.
+// It returns nil when nothing was created.
+func SpawnNextOccurrence(task models.Task) (*models.Task, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	recurrenceMu.Lock()
+	defer recurrenceMu.Unlock()
+	return spawnNextOccurrence(store, task)
+}
+
+// spawnNextOccurrence is SpawnNextOccurrence on s; the caller holds recurrenceMu.
+func spawnNextOccurrence(s TaskStore, task models.Task) (*models.Task, error) {
+	if task.Recurrence == "" || task.SeriesID == nil {
+		return nil, nil
+	}
+
+	series, err := s.GetSeries(*task.SeriesID)
+	if err != nil {
+		return nil, err
+	}
+	if len(series) == 0 || series[len(series)-1].ID != task.ID {
+		// Someone else already continued the series
+		return nil, nil
+	}
+	latest := series[len(series)-1]
+	rule, err := recurrence.Parse(latest.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	now := models.Now()
+	due, n := latest.DueDate.Time, latest.Occurrence
+	for {
+		next, ok := rule.Next(due, n)
+		if !ok {
+			return nil, nil
+		}
+		due, n = next, n+1
+		if due.After(now.Time) {
+			break
+		}
+	}
+
+	next := &models.Task{
+		ID:          uuid.New().String(),
+		Title:       latest.Title,
+		Description: latest.Description,
+		Priority:    latest.Priority,
+		DueDate:     models.NewTimestamp(due),
+		Labels:      append([]string{}, latest.Labels...),
+		ParentID:    latest.ParentID,
+		Recurrence:  latest.Recurrence,
+		Project:     latest.Project,
+		SeriesID:    latest.SeriesID,
+		Occurrence:  n,
+		OwnerID:     latest.OwnerID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
+	}
+	wf := workflow.Current()
+	wf.Apply(next, wf.Initial(), now)
+	next.PriorityPinned = latest.PriorityPinned
+	if !next.PriorityPinned {
+		priority.Current().Apply(next)
+	}
+
+	err = s.CreateTask(next)
+	if errors.Is(err, ErrOccurrenceExists) {
+		// Another server continued the series first
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the next occurrence: %v", err)
+	}
+	taskChanged(s, TaskCreated, *next)
+	log.Printf("Created occurrence %d of series %s due %s\n", next.Occurrence, *next.SeriesID, next.DueDate)
+	return next, nil
+}
+
+// skipOccurrence runs before an occurrence is deleted. If it is the latest
+// one, the series goes on with the occurrence after it; when the rule has none
+// left, the other occurrences stop recurring so the monitor does not recreate
+// the deleted one from its predecessor. The caller holds recurrenceMu.
+func skipOccurrence(s TaskStore, task models.Task) error {
+	next, err := spawnNextOccurrence(s, task)
+	if err != nil || next != nil {
+		return err
+	}
+
+	series, err := s.GetSeries(*task.SeriesID)
+	if err != nil {
+		return err
+	}
+	if len(series) == 0 || series[len(series)-1].ID != task.ID {
+		return nil
+	}
+	for _, occurrence := range series {
+		if occurrence.ID != task.ID && occurrence.Recurrence != "" {
+			occurrence.Recurrence = ""
+			updated, err := s.UpdateTask(occurrence.ID, &occurrence)
+			if err != nil {
+				return err
+			}
+			taskChanged(s, TaskUpdated, *updated)
+		}
+	}
+	return nil
+}
+
+// AdvanceRecurringTasks continues every series whose latest occurrence is
+// finished or past its due date. The TaskMonitor calls it on each
+// reconciliation sweep.
+func AdvanceRecurringTasks(logger zLogger.Logger, tasks []models.Task) {
+	latest := map[string]models.Task{}
+	for _, task := range tasks {
+		if task.SeriesID == nil {
+			continue
+		}
+		if current, ok := latest[*task.SeriesID]; !ok || task.Occurrence > current.Occurrence {
+			latest[*task.SeriesID] = task
+		}
+	}
+
+	now := time.Now()
+	wf := workflow.Current()
+	for _, task := range latest {
+		if !wf.IsTerminal(task.Status) && task.DueDate.After(now) {
+			continue
+		}
+		if _, err := SpawnNextOccurrence(task); err != nil {
+			logger.Error(fmt.Sprintf("TaskMonitor: Error creating the next occurrence of task %s", task.ID), err)
+		}
+	}
+}
+
+// GetSeries returns every occurrence of the series a task belongs to. A task
+// that does not recur is returned on its own.
+func GetSeries(taskId string) ([]models.Task, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	task, err := store.GetTaskByID(taskId)
+	if err != nil {
+		return nil, err
+	}
+	series := []models.Task{*task}
+	if task.SeriesID != nil {
+		if series, err = store.GetSeries(*task.SeriesID); err != nil {
+			return nil, err
+		}
+	}
+	for i := range series {
+		priority.Current().Apply(&series[i])
+	}
+	return series, nil
+}
+
+// UpdateTaskSeries updates one occurrence like UpdateTask and copies its
+// title, description, priority, labels, recurrence and project to every other open
+// occurrence of the series. Due dates and parents stay per occurrence, and
+// finished occurrences are left as they were. The whole series is updated
+// or none of it.
+func UpdateTaskSeries(taskId string, task *models.Task) (*models.Task, error) {
+	var updated *models.Task
+	err := inTransaction(func(s TaskStore) error {
+		var err error
+		updated, err = updateTaskSeries(s, taskId, task)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// updateTaskSeries is UpdateTaskSeries on s; the caller holds recurrenceMu.
+func updateTaskSeries(s TaskStore, taskId string, task *models.Task) (*models.Task, error) {
+	updated, err := updateTask(s, taskId, task)
+	if err != nil || updated.SeriesID == nil {
+		return updated, err
+	}
+
+	series, err := s.GetSeries(*updated.SeriesID)
+	if err != nil {
+		return nil, err
+	}
+	wf := workflow.Current()
+	for _, occurrence := range series {
+		if occurrence.ID == updated.ID || wf.IsTerminal(occurrence.Status) {
+			continue
+		}
+		previous := occurrence
+		occurrence.Title = updated.Title
+		occurrence.Description = updated.Description
+		// Unpinned priorities follow each occurrence's own due date
+		occurrence.Priority, occurrence.PriorityPinned = updated.Priority, updated.PriorityPinned
+		if !occurrence.PriorityPinned {
+			priority.Current().Apply(&occurrence)
+		}
+		occurrence.Labels = updated.Labels
+		occurrence.Recurrence = updated.Recurrence
+		occurrence.Project = updated.Project
+		occurrence.UpdatedAt, occurrence.UpdatedBy = updated.UpdatedAt, updated.UpdatedBy
+		changed, err := s.UpdateTask(occurrence.ID, &occurrence)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update occurrence %s: %w", occurrence.ID, err)
+		}
+		taskUpdated(s, TaskUpdated, previous, *changed)
+	}
+	return updated, nil
+}
+
+// DeleteTaskSeries ends a recurring task: open occurrences are deleted (their
+// subtasks handled according to mode) and the finished ones stop recurring,
+// so the monitor does not bring the series back. A non-zero version is the
+// one the task named by taskId must still have. The whole series is deleted
+// or none of it.
+func DeleteTaskSeries(taskId string, mode DeleteMode, version int64) error {
+	return inTransaction(func(s TaskStore) error {
+		return deleteTaskSeries(s, taskId, mode, version)
+	})
+}
+
+// deleteTaskSeries is DeleteTaskSeries on s; the caller holds recurrenceMu.
+// It works on the stored rows, so unpinned priorities are written back as
+// they were rather than as the policy sees them.
+func deleteTaskSeries(s TaskStore, taskId string, mode DeleteMode, version int64) error {
+	task, err := s.GetTaskByID(taskId)
+	if err != nil {
+		return err
+	}
+	if version != 0 && task.Version != version {
+		return ErrVersionConflict
+	}
+	series := []models.Task{*task}
+	if task.SeriesID != nil {
+		if series, err = s.GetSeries(*task.SeriesID); err != nil {
+			return err
+		}
+	}
+
+	wf := workflow.Current()
+	for _, occurrence := range series {
+		if !wf.IsTerminal(occurrence.Status) {
+			if err := removeTask(s, occurrence, occurrence.Version, mode == DeleteCascade); err != nil {
+				return err
+			}
+			continue
+		}
+		if occurrence.Recurrence != "" {
+			previous := occurrence
+			occurrence.Recurrence = ""
+			updated, err := s.UpdateTask(occurrence.ID, &occurrence)
+			if err != nil {
+				return err
+			}
+			taskUpdated(s, TaskUpdated, previous, *updated)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// createOccurrence stores occurrence number occurrence of series s1 with an unpinned
+// priority the default policy no longer agrees with.
+func createOccurrence(t *testing.T, s TaskStore, id string, occurrence int, status models.Status, stored models.Priority) {
+	t.Helper()
+	now := models.Now()
+	series := "s1"
+	task := models.Task{ID: id, Title: "Standup", DueDate: models.Timestamp{Time: now.Add(time.Hour)}, Priority: &stored,
+		Status: status, Labels: []string{}, Recurrence: "FREQ=DAILY", SeriesID: &series, Occurrence: occurrence, CreatedAt: now, UpdatedAt: now, Version: 1}
+	if err := s.CreateTask(&task); err != nil {
+		t.Fatalf("create %s: %v", id, err)
+	}
+}
+
+func TestDeleteTaskSeries(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		useStore(t, s)
+		createOccurrence(t, s, "done", 1, models.StatusDone, models.Low)
+		createOccurrence(t, s, "open", 2, models.StatusTodo, models.Low)
+		createOccurrence(t, s, "next", 3, models.StatusTodo, models.Low)
+
+		if err := DeleteTaskSeries("open", DeleteReparent, 2); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("DeleteTaskSeries at a stale version = %v, want %v", err, ErrVersionConflict)
+		}
+		if series, err := s.GetSeries("s1"); err != nil || len(series) != 3 {
+			t.Fatalf("series after a failed delete: %+v, %v", series, err)
+		}
+
+		if err := DeleteTaskSeries("open", DeleteReparent, 1); err != nil {
+			t.Fatalf("DeleteTaskSeries: %v", err)
+		}
+		series, err := s.GetSeries("s1")
+		if err != nil || len(series) != 1 {
+			t.Fatalf("series after delete: %+v, %v", series, err)
+		}
+		// The finished occurrence stops recurring and keeps its stored
+		// priority, not the High the policy gives it for being due soon
+		done := series[0]
+		if done.ID != "done" || done.Recurrence != "" || done.Priority == nil || *done.Priority != models.Low || done.PriorityPinned {
+			t.Fatalf("finished occurrence after delete: %+v", done)
+		}
+	})
+}
+
+func TestUpdateTaskSeries(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		useStore(t, s)
+		createOccurrence(t, s, "done", 1, models.StatusDone, models.Low)
+		createOccurrence(t, s, "open", 2, models.StatusTodo, models.Low)
+		createOccurrence(t, s, "next", 3, models.StatusTodo, models.Low)
+
+		edit := &models.Task{Title: "Daily standup", DueDate: models.Timestamp{Time: time.Now().Add(time.Hour)},
+			Status: models.StatusTodo, Labels: []string{"team"}, Recurrence: "FREQ=DAILY", Version: 1}
+		if _, err := UpdateTaskSeries("open", edit); err != nil {
+			t.Fatalf("UpdateTaskSeries: %v", err)
+		}
+		for _, want := range []struct {
+			id    string
+			title string
+		}{
+			{"done", "Standup"},
+			{"open", "Daily standup"},
+			{"next", "Daily standup"},
+		} {
+			task, err := s.GetTaskByID(want.id)
+			if err != nil || task.Title != want.title {
+				t.Errorf("%s after a series update: %+v, %v", want.id, task, err)
+			}
+		}
+	})
+}
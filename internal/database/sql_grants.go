@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+const grantColumns = `id, user_id, role, project, task_id, created_at, created_by`
+
+func scanGrant(row rowScanner) (*models.Grant, error) {
+	var grant models.Grant
+	var project, taskID, createdBy sql.NullString
+	if err := row.Scan(&grant.ID, &grant.UserID, &grant.Role, &project, &taskID, &grant.CreatedAt, &createdBy); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrGrantNotFound
+		}
+		return nil, err
+	}
+	grant.Project = stringPtr(project)
+	grant.TaskID = stringPtr(taskID)
+	grant.CreatedBy = stringPtr(createdBy)
+	return &grant, nil
+}
+
+func (s *sqlStore) CreateGrant(grant *models.Grant) error {
+	_, err := s.conn().Exec(`INSERT INTO role_grants (id, user_id, role, project, task_id, created_at, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		grant.ID, grant.UserID, grant.Role, grant.Project, grant.TaskID, grant.CreatedAt, grant.CreatedBy)
+	return err
+}
+
+func (s *sqlStore) UpdateGrantRole(id string, role models.Role) error {
+	result, err := s.conn().Exec(`UPDATE role_grants SET role = $1 WHERE id = $2`, role, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrGrantNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) GetGrant(id string) (*models.Grant, error) {
+	return scanGrant(s.conn().QueryRow(`SELECT `+grantColumns+` FROM role_grants WHERE id = $1`, id))
+}
+
+func (s *sqlStore) ListGrants(filter GrantFilter) ([]models.Grant, error) {
+	b := &sqlBuilder{}
+	var conditions []string
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = "+b.arg(filter.UserID))
+	}
+	if filter.Project != "" {
+		conditions = append(conditions, "project = "+b.arg(filter.Project))
+	}
+	if filter.TaskID != "" {
+		conditions = append(conditions, "task_id = "+b.arg(filter.TaskID))
+	}
+	query := `SELECT ` + grantColumns + ` FROM role_grants`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := s.conn().Query(query+" ORDER BY created_at, id", b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.Grant{}
+	for rows.Next() {
+		grant, err := scanGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, *grant)
+	}
+	return grants, rows.Err()
+}
+
+func (s *sqlStore) DeleteGrant(id string) error {
+	result, err := s.conn().Exec(`DELETE FROM role_grants WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrGrantNotFound
+	}
+	return nil
+}
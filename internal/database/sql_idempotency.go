@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+func (s *sqlStore) CreateIdempotencyRecord(record *IdempotencyRecord) (bool, error) {
+	result, err := s.conn().Exec(`
+		INSERT INTO idempotency_keys (scope, idempotency_key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, idempotency_key) DO NOTHING`,
+		record.Scope, record.Key, record.Fingerprint, record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (s *sqlStore) GetIdempotencyRecord(scope, key string) (*IdempotencyRecord, error) {
+	record := IdempotencyRecord{Scope: scope, Key: key}
+	var body string
+	err := s.conn().QueryRow(`
+		SELECT fingerprint, status_code, content_type, body, created_at, expires_at
+		FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2`, scope, key).
+		Scan(&record.Fingerprint, &record.StatusCode, &record.ContentType, &body, &record.CreatedAt, &record.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrIdempotencyRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	record.Body = []byte(body)
+	return &record, nil
+}
+
+func (s *sqlStore) CompleteIdempotencyRecord(scope, key string, statusCode int, contentType string, body []byte) error {
+	_, err := s.conn().Exec(`
+		UPDATE idempotency_keys SET status_code = $1, content_type = $2, body = $3
+		WHERE scope = $4 AND idempotency_key = $5`,
+		statusCode, contentType, string(body), scope, key)
+	return err
+}
+
+func (s *sqlStore) DeleteIdempotencyRecord(scope, key string) error {
+	_, err := s.conn().Exec(`DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2`, scope, key)
+	return err
+}
+
+func (s *sqlStore) DeleteExpiredIdempotencyRecords(now models.Timestamp) error {
+	_, err := s.conn().Exec(`DELETE FROM idempotency_keys WHERE expires_at < $1`, now)
+	return err
+}
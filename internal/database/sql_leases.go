@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+func (s *sqlStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	// Servers sharing a Postgres database go by its clock. SQLite lives in
+	// the file system of one host, so the server's own clock is the same one.
+	now, expires := "$3", "$4"
+	args := []interface{}{name, holder}
+	if s.dialect == DriverPostgres {
+		now, expires = "now()", "now() + make_interval(secs => $3)"
+		args = append(args, ttl.Seconds())
+	} else {
+		at := models.Now()
+		args = append(args, at, models.NewTimestamp(at.Add(ttl)))
+	}
+	result, err := s.conn().ExecContext(ctx, `
+		INSERT INTO leases (name, holder, acquired_at, expires_at)
+		VALUES ($1, $2, `+now+`, `+expires+`)
+		ON CONFLICT (name) DO UPDATE SET
+			holder = excluded.holder,
+			acquired_at = CASE WHEN leases.holder = excluded.holder THEN leases.acquired_at ELSE excluded.acquired_at END,
+			expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at < excluded.acquired_at`,
+		args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (s *sqlStore) ReleaseLease(name, holder string) error {
+	_, err := s.conn().Exec(`DELETE FROM leases WHERE name = $1 AND holder = $2`, name, holder)
+	return err
+}
+
+func (s *sqlStore) GetLease(name string) (*Lease, error) {
+	lease := Lease{Name: name}
+	err := s.conn().QueryRow(`SELECT holder, acquired_at, expires_at FROM leases WHERE name = $1`, name).
+		Scan(&lease.Holder, &lease.AcquiredAt, &lease.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrLeaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
@@ -0,0 +1,578 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// sqlStore holds the queries shared by the Postgres and SQLite backends.
+// Both drivers accept $N placeholders, so the statements are written once.
+type sqlStore struct {
+	db      *sql.DB
+	tx      *sql.Tx // Set on the store Transaction hands to its callback
+	dialect string
+}
+
+// queryer is what *sql.DB and *sql.Tx have in common.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// sqlTx is a transaction as the store methods use it.
+type sqlTx interface {
+	queryer
+	Commit() error
+	Rollback() error
+}
+
+// joinedTx is the transaction of a store bound by Transaction. Methods that
+// open their own transaction join it instead, leaving the commit or rollback
+// to the outer one.
+type joinedTx struct {
+	*sql.Tx
+}
+
+func (joinedTx) Commit() error   { return nil }
+func (joinedTx) Rollback() error { return nil }
+
+// occurrenceTaken reports whether err is the unique index on
+// (series_id, occurrence) turning down a second copy of an occurrence, as
+// lib/pq and SQLite word it.
+func occurrenceTaken(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "tasks_series_occurrence_idx") ||
+		strings.Contains(message, "tasks.series_id, tasks.occurrence")
+}
+
+// conn returns the transaction the store is bound to, or the pool.
+func (s *sqlStore) conn() queryer {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// begin starts a transaction, or joins the one the store is bound to.
+func (s *sqlStore) begin() (sqlTx, error) {
+	if s.tx != nil {
+		return joinedTx{s.tx}, nil
+	}
+	return s.db.Begin()
+}
+
+// Transaction runs fn against a copy of the store bound to a new transaction,
+// which is committed when fn returns nil and rolled back otherwise.
+func (s *sqlStore) Transaction(fn func(TaskStore) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(&sqlStore{db: s.db, tx: tx, dialect: s.dialect}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DB exposes the underlying connection pool.
+func (s *sqlStore) DB() *sql.DB {
+	return s.db
+}
+
+// Migrator returns the schema migrator for this connection.
+func (s *sqlStore) Migrator() (*Migrator, error) {
+	return NewMigrator(s.db, s.dialect)
+}
+
+// Close releases the connection pool.
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// taskColumns is the column list every task query selects, in scanTask order.
+const taskColumns = `id, title, description, priority, due_date, status, started_at, completed_at, created_at, updated_at, is_overdue, parent_id, recurrence, series_id, occurrence,
+	owner_id, created_by, updated_by, project, version, priority_pinned`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(row rowScanner, task *models.Task) error {
+	var description, recurrence sql.NullString
+	var parentID, seriesID, ownerID, createdBy, updatedBy, project sql.NullString
+	err := row.Scan(&task.ID, &task.Title, &description, &task.Priority, &task.DueDate, &task.Status, &task.StartedAt, &task.CompletedAt,
+		&task.CreatedAt, &task.UpdatedAt, &task.IsOverdue, &parentID, &recurrence, &seriesID, &task.Occurrence,
+		&ownerID, &createdBy, &updatedBy, &project, &task.Version, &task.PriorityPinned)
+	task.Description = description.String
+	task.Recurrence = recurrence.String
+	task.Project = project.String
+	task.ParentID = stringPtr(parentID)
+	task.SeriesID = stringPtr(seriesID)
+	task.OwnerID = stringPtr(ownerID)
+	task.CreatedBy = stringPtr(createdBy)
+	task.UpdatedBy = stringPtr(updatedBy)
+	task.Labels = []string{}
+	return err
+}
+
+// stringPtr maps NULL to nil.
+func stringPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+// nullString stores empty strings as NULL.
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// scanTasks drains rows into a slice and closes them, so follow-up queries
+// can run on SQLite's single connection.
+func scanTasks(rows *sql.Rows) ([]models.Task, error) {
+	defer rows.Close()
+	var tasks []models.Task
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// normalizeLabels trims labels and drops blanks and duplicates, keeping the first occurrence.
+func normalizeLabels(labels []string) []string {
+	seen := make(map[string]bool, len(labels))
+	normalized := []string{}
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+		normalized = append(normalized, label)
+	}
+	return normalized
+}
+
+// replaceLabels rewrites the task_labels rows of a task inside tx.
+func replaceLabels(tx queryer, taskID string, labels []string) error {
+	if _, err := tx.Exec(`DELETE FROM task_labels WHERE task_id = $1`, taskID); err != nil {
+		return err
+	}
+	for position, label := range normalizeLabels(labels) {
+		_, err := tx.Exec(`INSERT INTO task_labels (task_id, label, position) VALUES ($1, $2, $3)`, taskID, label, position)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadLabels fills in the Labels of tasks with one query over task_labels.
+func (s *sqlStore) loadLabels(tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(tasks))
+	for i := range tasks {
+		index[tasks[i].ID] = i
+	}
+
+	// Pages are looked up by ID; full listings simply read the whole table
+	query := `SELECT task_id, label FROM task_labels ORDER BY task_id, position`
+	var b sqlBuilder
+	if len(tasks) <= models.MaxPageSize {
+		ids := make([]string, len(tasks))
+		for i := range tasks {
+			ids[i] = tasks[i].ID
+		}
+		query = `SELECT task_id, label FROM task_labels WHERE task_id IN (` + b.list(ids) + `) ORDER BY task_id, position`
+	}
+
+	rows, err := s.conn().Query(query, b.args...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch labels: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID, label string
+		if err := rows.Scan(&taskID, &label); err != nil {
+			return err
+		}
+		if i, ok := index[taskID]; ok {
+			tasks[i].Labels = append(tasks[i].Labels, label)
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) CreateTask(task *models.Task) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Postgres gives up on a transaction after a failed statement; the
+	// savepoint lets the caller's transaction go on past a taken occurrence
+	if _, err := tx.Exec(`SAVEPOINT create_task`); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO tasks (id, title, description, priority, due_date, status, started_at, completed_at, created_at, updated_at,
+			parent_id, recurrence, series_id, occurrence, owner_id, created_by, updated_by, project, version, priority_pinned)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+	`
+	_, err = tx.Exec(query, task.ID, task.Title, task.Description, task.Priority, task.DueDate,
+		task.Status, task.StartedAt, task.CompletedAt, task.CreatedAt, task.UpdatedAt,
+		task.ParentID, nullString(task.Recurrence), task.SeriesID, task.Occurrence,
+		task.OwnerID, task.CreatedBy, task.UpdatedBy, nullString(task.Project), task.Version, task.PriorityPinned)
+	if occurrenceTaken(err) {
+		if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT create_task`); err != nil {
+			return err
+		}
+		return ErrOccurrenceExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`RELEASE SAVEPOINT create_task`); err != nil {
+		return err
+	}
+	if err := replaceLabels(tx, task.ID, task.Labels); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) GetTasks() ([]models.Task, error) {
+	// Query to retrieve tasks sorted by priority, then by due date
+	query := `
+        SELECT ` + taskColumns + `
+        FROM tasks
+        ORDER BY CASE
+            WHEN priority = 'High' THEN 1
+            WHEN priority = 'Medium' THEN 2
+            WHEN priority = 'Low' THEN 3
+            ELSE 4
+        END, due_date`
+
+	rows, err := s.conn().Query(query)
+	if err != nil {
+		log.Printf("Error fetching tasks: %v", err)
+		return nil, fmt.Errorf("failed to fetch tasks from database: %v", err)
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			log.Printf("Error scanning task: %v", err)
+			continue // Skip this task and continue with the next one
+		}
+		tasks = append(tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over rows: %v", err)
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+	rows.Close()
+
+	if err := s.loadLabels(tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *sqlStore) ListTasks(query models.TaskQuery) (*TaskPage, error) {
+	cur, err := decodeCursor(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var b sqlBuilder
+	rows, err := s.conn().Query(b.listQuery(query, cur), b.args...)
+	if err != nil {
+		log.Printf("Error listing tasks: %v", err)
+		return nil, fmt.Errorf("failed to list tasks from database: %v", err)
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := finishPage(query, cur, tasks)
+	if err := s.loadLabels(page.Tasks); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *sqlStore) GetTaskByID(taskId string) (*models.Task, error) {
+	row := s.conn().QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = $1`, taskId)
+
+	var task models.Task
+	if err := scanTask(row, &task); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTaskNotFound
+		}
+		return nil, err
+	}
+
+	tasks := []models.Task{task}
+	if err := s.loadLabels(tasks); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
+}
+
+func (s *sqlStore) UpdateTask(taskId string, task *models.Task) (*models.Task, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE tasks SET title = $1, description = $2, priority = $3, due_date = $4, parent_id = $5,
+			recurrence = $6, series_id = $7, occurrence = $8, owner_id = $9, updated_at = $10, updated_by = $11,
+			project = $12, priority_pinned = $13, version = version + 1
+		WHERE id = $14 AND ($15 = 0 OR version = $15)`,
+		task.Title, task.Description, task.Priority, task.DueDate, task.ParentID,
+		nullString(task.Recurrence), task.SeriesID, task.Occurrence, task.OwnerID, task.UpdatedAt, task.UpdatedBy,
+		nullString(task.Project), task.PriorityPinned, taskId, task.Version)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		// Ask through tx: SQLite has a single connection, which tx holds
+		return nil, conflictOrMissing(tx, taskId, ErrVersionConflict)
+	}
+	if err := replaceLabels(tx, taskId, task.Labels); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Fetch and return the updated task
+	return s.GetTaskByID(taskId)
+}
+
+func (s *sqlStore) UpdateTaskPriority(taskID string, newPriority string, updatedAt models.Timestamp) error {
+	result, err := s.conn().Exec(`UPDATE tasks SET priority = $1, priority_pinned = TRUE, updated_at = $2, version = version + 1 WHERE id = $3`, newPriority, updatedAt, taskID)
+	return missingIfNoRows(result, err)
+}
+
+func (s *sqlStore) UpdateTaskStatus(taskID string, from models.Status, task *models.Task) error {
+	// Only move the task if nobody changed its status since it was read
+	result, err := s.conn().Exec(`
+		UPDATE tasks SET status = $1, started_at = $2, completed_at = $3, updated_at = $4, updated_by = $5,
+			version = version + 1
+		WHERE id = $6 AND status = $7`,
+		task.Status, task.StartedAt, task.CompletedAt, task.UpdatedAt, task.UpdatedBy, taskID, from)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return conflictOrMissing(s.conn(), taskID, ErrStatusConflict)
+	}
+	return nil
+}
+
+// conflictOrMissing explains a conditional write that matched no row: it
+// returns ErrTaskNotFound for a missing task, and conflict for one that
+// changed underneath us. q must be the connection or transaction of the write.
+func conflictOrMissing(q queryer, taskID string, conflict error) error {
+	var exists int
+	err := q.QueryRow(`SELECT 1 FROM tasks WHERE id = $1`, taskID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrTaskNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return conflict
+}
+
+func (s *sqlStore) MarkTaskOverdue(taskID string, priority *models.Priority) error {
+	result, err := s.conn().Exec("UPDATE tasks SET is_overdue = $1, priority = $2, version = version + 1 WHERE id = $3", true, priority, taskID)
+	return missingIfNoRows(result, err)
+}
+
+func (s *sqlStore) RefreshTaskPriority(taskID string, priority *models.Priority) error {
+	result, err := s.conn().Exec(`UPDATE tasks SET priority = $1, version = version + 1 WHERE id = $2 AND NOT priority_pinned`,
+		priority, taskID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return conflictOrMissing(s.conn(), taskID, nil)
+	}
+	return nil
+}
+
+// missingIfNoRows turns a write to a task that matched no row into ErrTaskNotFound.
+func missingIfNoRows(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) AddDependency(dep models.Dependency) error {
+	_, err := s.conn().Exec(`INSERT INTO task_dependencies (task_id, depends_on_id, created_at) VALUES ($1, $2, $3)`,
+		dep.TaskID, dep.DependsOnID, dep.CreatedAt)
+	return err
+}
+
+func (s *sqlStore) RemoveDependency(taskID, dependsOnID string) error {
+	result, err := s.conn().Exec(`DELETE FROM task_dependencies WHERE task_id = $1 AND depends_on_id = $2`, taskID, dependsOnID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrDependencyNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) ListDependencies() ([]models.Dependency, error) {
+	rows, err := s.conn().Query(`SELECT task_id, depends_on_id, created_at FROM task_dependencies ORDER BY created_at, task_id, depends_on_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deps := []models.Dependency{}
+	for rows.Next() {
+		var dep models.Dependency
+		if err := rows.Scan(&dep.TaskID, &dep.DependsOnID, &dep.CreatedAt); err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+	return deps, rows.Err()
+}
+
+func (s *sqlStore) GetSeries(seriesID string) ([]models.Task, error) {
+	rows, err := s.conn().Query(`SELECT `+taskColumns+` FROM tasks WHERE series_id = $1 ORDER BY occurrence`, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadLabels(tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// subtreeCTE selects the IDs of a task and all of its descendants.
+const subtreeCTE = `
+	WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM tasks WHERE id = $1
+		UNION ALL
+		SELECT t.id FROM tasks t JOIN subtree ON t.parent_id = subtree.id
+	)`
+
+func (s *sqlStore) GetSubtree(rootID string) ([]models.Task, error) {
+	rows, err := s.conn().Query(subtreeCTE+` SELECT `+taskColumns+` FROM tasks WHERE id IN (SELECT id FROM subtree)`, rootID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, ErrTaskNotFound
+	}
+	if err := s.loadLabels(tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *sqlStore) CountChildrenByStatus(parentIDs []string) (map[string]map[models.Status]int, error) {
+	counts := map[string]map[models.Status]int{}
+	if len(parentIDs) == 0 {
+		return counts, nil
+	}
+
+	var b sqlBuilder
+	rows, err := s.conn().Query(`SELECT parent_id, status, COUNT(*) FROM tasks WHERE parent_id IN (`+b.list(parentIDs)+`) GROUP BY parent_id, status`, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var parentID string
+		var status models.Status
+		var count int
+		if err := rows.Scan(&parentID, &status, &count); err != nil {
+			return nil, err
+		}
+		if counts[parentID] == nil {
+			counts[parentID] = map[models.Status]int{}
+		}
+		counts[parentID][status] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *sqlStore) DeleteTask(taskId string, version int64, cascade bool) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if cascade {
+		// The descendants go first, as the subtree is found from the task;
+		// task_labels rows go with the tasks through ON DELETE CASCADE
+		_, err = tx.Exec(subtreeCTE+` DELETE FROM tasks WHERE id IN (SELECT id FROM subtree) AND id <> $1`, taskId)
+	} else {
+		// Hand the children over to the deleted task's own parent
+		_, err = tx.Exec(`UPDATE tasks SET parent_id = (SELECT parent_id FROM tasks WHERE id = $1), version = version + 1 WHERE parent_id = $1`, taskId)
+	}
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM tasks WHERE id = $1 AND ($2 = 0 OR version = $2)`, taskId, version)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return conflictOrMissing(tx, taskId, ErrVersionConflict)
+	}
+	return tx.Commit()
+}
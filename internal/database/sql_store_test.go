@@ -0,0 +1,113 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// newSQLiteTestStore returns a migrated SQLite store in a temporary file.
+func newSQLiteTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	migrator, err := MigratorFor(s)
+	if err != nil {
+		t.Fatalf("migrator: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return s
+}
+
+// within fails the test when fn does not return in time, e.g. because it
+// waits for the single SQLite connection it already holds.
+func within(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("did not return within %s", d)
+	}
+}
+
+func TestSQLiteUpdateTaskStaleVersion(t *testing.T) {
+	s := newSQLiteTestStore(t)
+	now := models.Now()
+	task := &models.Task{ID: "t1", Title: "Write docs", DueDate: now, Status: models.StatusTodo, Labels: []string{},
+		CreatedAt: now, UpdatedAt: now, Version: 1}
+	if err := s.CreateTask(task); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := s.UpdateTask("t1", &models.Task{Title: "First edit", DueDate: now, Version: 1}); err != nil {
+		t.Fatalf("update at the current version: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		version int64
+		want    error
+	}{
+		{"stale version", "t1", 1, ErrVersionConflict},
+		{"missing task", "nope", 1, ErrTaskNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			within(t, 5*time.Second, func() {
+				_, err = s.UpdateTask(tt.id, &models.Task{Title: "Second edit", DueDate: now, Version: tt.version})
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("UpdateTask = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteDeleteTaskStaleVersion(t *testing.T) {
+	s := newSQLiteTestStore(t)
+	now := models.Now()
+	for _, id := range []string{"parent", "child"} {
+		task := &models.Task{ID: id, Title: id, DueDate: now, Status: models.StatusTodo, Labels: []string{},
+			CreatedAt: now, UpdatedAt: now, Version: 2}
+		if id == "child" {
+			task.ParentID = &[]string{"parent"}[0]
+		}
+		if err := s.CreateTask(task); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+
+	for _, cascade := range []bool{false, true} {
+		var err error
+		within(t, 5*time.Second, func() { err = s.DeleteTask("parent", 1, cascade) })
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("DeleteTask(cascade=%v) = %v, want %v", cascade, err, ErrVersionConflict)
+		}
+		// Nothing changed: the children stay where they were
+		child, err := s.GetTaskByID("child")
+		if err != nil || child.ParentID == nil || *child.ParentID != "parent" {
+			t.Fatalf("child after a failed delete: %+v, %v", child, err)
+		}
+	}
+
+	if err := s.DeleteTask("parent", 2, true); err != nil {
+		t.Fatalf("delete at the current version: %v", err)
+	}
+	if _, err := s.GetTaskByID("child"); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("child after a cascading delete: %v", err)
+	}
+}
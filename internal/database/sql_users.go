@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+const userColumns = `id, name, is_admin, created_at`
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, created_at, last_used_at, revoked_at`
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	if err := row.Scan(&user.ID, &user.Name, &user.IsAdmin, &user.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *sqlStore) CreateUser(user *models.User) error {
+	_, err := s.conn().Exec(`INSERT INTO users (id, name, is_admin, created_at) VALUES ($1, $2, $3, $4)`,
+		user.ID, user.Name, user.IsAdmin, user.CreatedAt)
+	return err
+}
+
+func (s *sqlStore) GetUserByID(id string) (*models.User, error) {
+	return scanUser(s.conn().QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+func (s *sqlStore) GetUserByName(name string) (*models.User, error) {
+	return scanUser(s.conn().QueryRow(`SELECT `+userColumns+` FROM users WHERE name = $1`, name))
+}
+
+func (s *sqlStore) ListUsers() ([]models.User, error) {
+	rows, err := s.conn().Query(`SELECT ` + userColumns + ` FROM users ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlStore) CreateAPIKey(key *models.APIKey) error {
+	_, err := s.conn().Exec(`INSERT INTO api_keys (id, user_id, name, prefix, key_hash, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		key.ID, key.UserID, key.Name, key.Prefix, key.Hash, key.CreatedAt)
+	return err
+}
+
+func (s *sqlStore) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	return scanAPIKey(s.conn().QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+}
+
+func (s *sqlStore) ListAPIKeys(userID string) ([]models.APIKey, error) {
+	query, args := `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`, []interface{}{}
+	if userID != "" {
+		query, args = `SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at, id`, []interface{}{userID}
+	}
+	rows, err := s.conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *sqlStore) RevokeAPIKey(id string, at models.Timestamp) error {
+	result, err := s.conn().Exec(`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2`, at, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) SetCalendarToken(userID, hash string, at models.Timestamp) error {
+	_, err := s.conn().Exec(`INSERT INTO calendar_tokens (user_id, token_hash, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at`,
+		userID, hash, at)
+	return err
+}
+
+func (s *sqlStore) GetCalendarTokenUser(hash string) (string, error) {
+	var userID string
+	err := s.conn().QueryRow(`SELECT user_id FROM calendar_tokens WHERE token_hash = $1`, hash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidCalendarToken
+	}
+	return userID, err
+}
+
+func (s *sqlStore) DeleteCalendarToken(userID string) error {
+	_, err := s.conn().Exec(`DELETE FROM calendar_tokens WHERE user_id = $1`, userID)
+	return err
+}
+
+func (s *sqlStore) TouchAPIKey(id string, at models.Timestamp) error {
+	_, err := s.conn().Exec(`UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, at, id)
+	return err
+}
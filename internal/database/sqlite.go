@@ -0,0 +1,47 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, no cgo required
+)
+
+// SQLiteStore is the TaskStore backed by a local SQLite file.
+type SQLiteStore struct {
+	*sqlStore
+}
+
+// NewSQLiteStore opens (or creates) the SQLite database at path. Use ":memory:"
+// for a throwaway database. The schema is managed by the Migrator.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		log.Printf("Failed to open the SQLite database: %v\n", err)
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection avoids "database is locked"
+	// errors and keeps ":memory:" databases from being split across connections.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		log.Printf("Failed to ping the SQLite database: %v\n", err)
+		db.Close()
+		return nil, err
+	}
+	log.Println("SQLite database opened successfully:", path)
+
+	return &SQLiteStore{sqlStore: &sqlStore{db: db, dialect: DriverSQLite}}, nil
+}
+
+// sqliteDSN adds the connection options the store relies on: foreign keys for
+// ON DELETE CASCADE, a sortable text format for timestamp columns, and a busy
+// timeout so servers sharing the file wait for each other's writes.
+func sqliteDSN(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// storeBackends are the TaskStore implementations the conformance tests run
+// against. Postgres shares its implementation with SQLite.
+var storeBackends = []struct {
+	name string
+	open func(t *testing.T) TaskStore
+}{
+	{"memory", func(t *testing.T) TaskStore { return NewMemoryStore() }},
+	{"sqlite", func(t *testing.T) TaskStore { return newSQLiteTestStore(t) }},
+}
+
+// forEachStore runs test against a fresh store of every backend.
+func forEachStore(t *testing.T, test func(t *testing.T, s TaskStore)) {
+	for _, backend := range storeBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+// createTestTask stores a task at version 1.
+func createTestTask(t *testing.T, s TaskStore, id string, parentID *string) models.Task {
+	t.Helper()
+	now := models.Now()
+	task := models.Task{ID: id, Title: "Task " + id, DueDate: models.Timestamp{Time: now.Add(time.Hour)}, Status: models.StatusTodo,
+		Labels: []string{"home"}, ParentID: parentID, CreatedAt: now, UpdatedAt: now, Version: 1}
+	if err := s.CreateTask(&task); err != nil {
+		t.Fatalf("create %s: %v", id, err)
+	}
+	return task
+}
+
+func TestStoreMissingTask(t *testing.T) {
+	high := models.High
+	writes := []struct {
+		name string
+		call func(s TaskStore) error
+	}{
+		{"GetTaskByID", func(s TaskStore) error { _, err := s.GetTaskByID("missing"); return err }},
+		{"UpdateTask", func(s TaskStore) error {
+			_, err := s.UpdateTask("missing", &models.Task{Title: "x", DueDate: models.Now()})
+			return err
+		}},
+		{"UpdateTaskPriority", func(s TaskStore) error { return s.UpdateTaskPriority("missing", "High", models.Now()) }},
+		{"UpdateTaskStatus", func(s TaskStore) error {
+			return s.UpdateTaskStatus("missing", models.StatusTodo, &models.Task{Status: models.StatusDone})
+		}},
+		{"MarkTaskOverdue", func(s TaskStore) error { return s.MarkTaskOverdue("missing", &high) }},
+		{"RefreshTaskPriority", func(s TaskStore) error { return s.RefreshTaskPriority("missing", &high) }},
+		{"GetSubtree", func(s TaskStore) error { _, err := s.GetSubtree("missing"); return err }},
+		{"DeleteTask", func(s TaskStore) error { return s.DeleteTask("missing", 0, false) }},
+		{"DeleteTask cascade", func(s TaskStore) error { return s.DeleteTask("missing", 0, true) }},
+	}
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		createTestTask(t, s, "present", nil)
+		for _, w := range writes {
+			if err := w.call(s); !errors.Is(err, ErrTaskNotFound) {
+				t.Errorf("%s = %v, want %v", w.name, err, ErrTaskNotFound)
+			}
+		}
+		// The other task is untouched
+		if task, err := s.GetTaskByID("present"); err != nil || task.Version != 1 {
+			t.Fatalf("present task: %+v, %v", task, err)
+		}
+	})
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		created := createTestTask(t, s, "t1", nil)
+		task, err := s.GetTaskByID("t1")
+		if err != nil {
+			t.Fatalf("GetTaskByID: %v", err)
+		}
+		if task.Title != created.Title || !task.DueDate.Equal(created.DueDate.Time) || task.Version != 1 ||
+			len(task.Labels) != 1 || task.Labels[0] != "home" || task.Priority != nil || task.PriorityPinned {
+			t.Fatalf("stored task = %+v, want %+v", task, created)
+		}
+
+		if err := s.UpdateTaskPriority("t1", "High", models.Now()); err != nil {
+			t.Fatalf("UpdateTaskPriority: %v", err)
+		}
+		medium := models.Medium
+		if err := s.MarkTaskOverdue("t1", &medium); err != nil {
+			t.Fatalf("MarkTaskOverdue: %v", err)
+		}
+		task, _ = s.GetTaskByID("t1")
+		if task.Priority == nil || *task.Priority != models.Medium || !task.PriorityPinned || !task.IsOverdue || task.Version != 3 {
+			t.Fatalf("task after priority and overdue writes = %+v", task)
+		}
+	})
+}
+
+func TestStoreConditionalWrites(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		createTestTask(t, s, "t1", nil)
+
+		edit := &models.Task{Title: "Edited", DueDate: models.Now(), Labels: []string{"work"}, Version: 1}
+		updated, err := s.UpdateTask("t1", edit)
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("UpdateTask at the current version = %+v, %v", updated, err)
+		}
+		stale := &models.Task{Title: "Stale", DueDate: models.Now(), Version: 1}
+		if _, err := s.UpdateTask("t1", stale); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("UpdateTask at a stale version = %v, want %v", err, ErrVersionConflict)
+		}
+
+		err = s.UpdateTaskStatus("t1", models.StatusDone, &models.Task{Status: models.StatusInProgress})
+		if !errors.Is(err, ErrStatusConflict) {
+			t.Fatalf("UpdateTaskStatus from the wrong status = %v, want %v", err, ErrStatusConflict)
+		}
+
+		if err := s.DeleteTask("t1", 1, false); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("DeleteTask at a stale version = %v, want %v", err, ErrVersionConflict)
+		}
+		if err := s.DeleteTask("t1", 2, false); err != nil {
+			t.Fatalf("DeleteTask at the current version: %v", err)
+		}
+		if _, err := s.GetTaskByID("t1"); !errors.Is(err, ErrTaskNotFound) {
+			t.Fatalf("GetTaskByID after delete = %v", err)
+		}
+	})
+}
+
+func TestStoreDeleteChildren(t *testing.T) {
+	tests := []struct {
+		name    string
+		cascade bool
+	}{
+		{"reparent", false},
+		{"cascade", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, s TaskStore) {
+				root := "root"
+				createTestTask(t, s, root, nil)
+				middle := "middle"
+				createTestTask(t, s, middle, &root)
+				createTestTask(t, s, "leaf", &middle)
+
+				if err := s.DeleteTask(middle, 0, tt.cascade); err != nil {
+					t.Fatalf("DeleteTask: %v", err)
+				}
+				leaf, err := s.GetTaskByID("leaf")
+				if tt.cascade {
+					if !errors.Is(err, ErrTaskNotFound) {
+						t.Fatalf("leaf after a cascading delete: %+v, %v", leaf, err)
+					}
+					return
+				}
+				if err != nil || leaf.ParentID == nil || *leaf.ParentID != root || leaf.Version != 2 {
+					t.Fatalf("leaf after a reparenting delete: %+v, %v", leaf, err)
+				}
+			})
+		})
+	}
+}
+
+func TestStoreDuplicateOccurrence(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		series := "s1"
+		now := models.Now()
+		occurrence := func(id string) *models.Task {
+			return &models.Task{ID: id, Title: "Standup", DueDate: now, Status: models.StatusTodo, Labels: []string{},
+				Recurrence: "FREQ=DAILY", SeriesID: &series, Occurrence: 2, CreatedAt: now, UpdatedAt: now, Version: 1}
+		}
+		if err := s.CreateTask(occurrence("first")); err != nil {
+			t.Fatalf("create the occurrence: %v", err)
+		}
+		err := s.Transaction(func(tx TaskStore) error {
+			if err := tx.CreateTask(occurrence("second")); !errors.Is(err, ErrOccurrenceExists) {
+				t.Errorf("create the occurrence again = %v, want %v", err, ErrOccurrenceExists)
+			}
+			// The transaction goes on
+			createTestTask(t, tx, "other", nil)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Transaction: %v", err)
+		}
+		if _, err := s.GetTaskByID("other"); err != nil {
+			t.Fatalf("task created after the duplicate: %v", err)
+		}
+	})
+}
+
+func TestStoreLeases(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s TaskStore) {
+		ctx := context.Background()
+		acquire := func(holder string, ttl time.Duration) bool {
+			t.Helper()
+			acquired, err := s.AcquireLease(ctx, "jobs", holder, ttl)
+			if err != nil {
+				t.Fatalf("AcquireLease(%s): %v", holder, err)
+			}
+			return acquired
+		}
+
+		if !acquire("a", time.Minute) {
+			t.Fatalf("a could not take a free lease")
+		}
+		first, err := s.GetLease("jobs")
+		if err != nil {
+			t.Fatalf("GetLease: %v", err)
+		}
+		if acquire("b", time.Minute) {
+			t.Fatalf("b took a lease a holds")
+		}
+		if !acquire("a", -time.Second) {
+			t.Fatalf("a could not renew its lease")
+		}
+		renewed, _ := s.GetLease("jobs")
+		if renewed.Holder != "a" || !renewed.AcquiredAt.Equal(first.AcquiredAt.Time) {
+			t.Fatalf("lease after renewal = %+v, want a's from %s", renewed, first.AcquiredAt)
+		}
+		if !acquire("b", time.Minute) {
+			t.Fatalf("b could not take an expired lease")
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/priority"
+	"github.com/iabdulzahid/golang_task_manager/internal/urgency"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
+)
+
+// NextTasks returns up to limit open tasks matching filter, most urgent first,
+// each with its urgency and the breakdown of its score. Ties go to the task
+// due first, then to the older one. Only open tasks the caller of a non-nil
+// filter.VisibleTo may see count as blocked by the task; a task waits for any
+// open dependency, seen or not, as it cannot start until that one finishes.
+func NextTasks(filter models.TaskFilter, limit int) ([]models.Task, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	tasks, err := store.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+	deps, err := store.ListDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	wf := workflow.Current()
+	open := map[string]models.Task{}
+	for _, task := range tasks {
+		if !wf.IsTerminal(task.Status) {
+			open[task.ID] = task
+		}
+	}
+	blocking := map[string]int{}
+	blocked := map[string]bool{}
+	for _, dep := range deps {
+		waiter, from := open[dep.TaskID]
+		_, to := open[dep.DependsOnID]
+		if !from || !to {
+			continue
+		}
+		blocked[dep.TaskID] = true
+		if filter.VisibleTo == nil || filter.VisibleTo.CanSee(waiter) {
+			blocking[dep.DependsOnID]++
+		}
+	}
+
+	now := time.Now()
+	weights := urgency.Current()
+	next := []models.Task{}
+	for _, task := range open {
+		priority.Current().Apply(&task)
+		if !filter.Matches(task) {
+			continue
+		}
+		score := weights.Score(task, urgency.Dependencies{Blocking: blocking[task.ID], Blocked: blocked[task.ID]}, now)
+		task.Urgency = &score
+		next = append(next, task)
+	}
+
+	sort.SliceStable(next, func(i, j int) bool {
+		a, b := next[i], next[j]
+		if a.Urgency.Score != b.Urgency.Score {
+			return a.Urgency.Score > b.Urgency.Score
+		}
+		if !a.DueDate.Equal(b.DueDate.Time) {
+			// Tasks without a due date come last
+			return !a.DueDate.IsZero() && (b.DueDate.IsZero() || a.DueDate.Before(b.DueDate.Time))
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt.Time) {
+			return a.CreatedAt.Before(b.CreatedAt.Time)
+		}
+		return a.ID < b.ID
+	})
+	if len(next) > limit {
+		next = next[:limit]
+	}
+	if err := addRollups(store, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+var (
+	// ErrUserNotFound is returned when a user ID or name does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when creating a user whose name is taken.
+	ErrUserExists = errors.New("user already exists")
+	// ErrAPIKeyNotFound is returned when a key ID does not exist.
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidAPIKey is returned for unknown or revoked keys.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+// apiKeyPrefix starts every issued key, so leaked keys are easy to recognise.
+const apiKeyPrefix = "tm_"
+
+// bootstrapUser owns the key given in the API_KEY setting.
+const bootstrapUser = "admin"
+
+// provisionMu serializes ProvisionUser, so concurrent first requests of a
+// token subject create one user.
+var provisionMu sync.Mutex
+
+// lastUsedResolution limits how often a key's last_used_at is written.
+const lastUsedResolution = time.Minute
+
+// UserStore is the persistence contract for users and their API keys.
+type UserStore interface {
+	CreateUser(user *models.User) error
+	GetUserByID(id string) (*models.User, error)
+	GetUserByName(name string) (*models.User, error)
+	ListUsers() ([]models.User, error)
+	CreateAPIKey(key *models.APIKey) error
+	// GetAPIKeyByHash returns revoked keys too; callers check RevokedAt.
+	GetAPIKeyByHash(hash string) (*models.APIKey, error)
+	// ListAPIKeys returns the keys of one user, or of everyone when userID is empty.
+	ListAPIKeys(userID string) ([]models.APIKey, error)
+	RevokeAPIKey(id string, at models.Timestamp) error
+	TouchAPIKey(id string, at models.Timestamp) error
+	// SetCalendarToken replaces the calendar feed token of a user.
+	SetCalendarToken(userID, hash string, at models.Timestamp) error
+	// GetCalendarTokenUser returns the ID of the user a token digest belongs
+	// to, or ErrInvalidCalendarToken.
+	GetCalendarTokenUser(hash string) (string, error)
+	DeleteCalendarToken(userID string) error
+}
+
+// hashAPIKey is the digest stored for a key. Keys carry 256 random bits, so a
+// plain SHA-256 is enough; there is nothing to brute-force.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyPrefix is the part of a key shown in listings.
+func keyPrefix(key string) string {
+	if len(key) > len(apiKeyPrefix)+6 {
+		return key[:len(apiKeyPrefix)+6]
+	}
+	return key
+}
+
+// CreateUser adds a user. Names are unique.
+func CreateUser(name string, isAdmin bool) (*models.User, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("user name is required")
+	}
+	if _, err := store.GetUserByName(name); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUserExists, name)
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
+	user := &models.User{ID: uuid.New().String(), Name: name, IsAdmin: isAdmin, CreatedAt: models.Now()}
+	if err := store.CreateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+	return user, nil
+}
+
+// ProvisionUser returns the user with the given ID, creating it on first
+// sight. Token callers are known by their issuer and subject and have no row
+// until their first request; their name falls back to the ID when it is taken.
+func ProvisionUser(id, name string) (*models.User, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	user, err := store.GetUserByID(id)
+	if !errors.Is(err, ErrUserNotFound) {
+		return user, err
+	}
+
+	provisionMu.Lock()
+	defer provisionMu.Unlock()
+	if user, err := store.GetUserByID(id); !errors.Is(err, ErrUserNotFound) {
+		return user, err
+	}
+	if name == "" {
+		name = id
+	}
+	if _, err := store.GetUserByName(name); err == nil {
+		name = id
+	}
+	user = &models.User{ID: id, Name: name, CreatedAt: models.Now()}
+	if err := store.CreateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+	log.Printf("Provisioned user %q for token subject %s\n", user.Name, id)
+	return user, nil
+}
+
+// ListUsers returns every user.
+func ListUsers() ([]models.User, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	return store.ListUsers()
+}
+
+// FindUser looks a user up by ID or by name.
+func FindUser(idOrName string) (*models.User, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	user, err := store.GetUserByID(idOrName)
+	if errors.Is(err, ErrUserNotFound) {
+		user, err = store.GetUserByName(idOrName)
+	}
+	return user, err
+}
+
+// IssueAPIKey creates a new key for a user. The returned plaintext key is not
+// stored anywhere and cannot be recovered later.
+func IssueAPIKey(userID, name string) (string, *models.APIKey, error) {
+	if store == nil {
+		return "", nil, fmt.Errorf("database connection is nil")
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %v", err)
+	}
+	plaintext := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	key, err := storeAPIKey(userID, name, plaintext)
+	return plaintext, key, err
+}
+
+func storeAPIKey(userID, name, plaintext string) (*models.APIKey, error) {
+	if _, err := store.GetUserByID(userID); err != nil {
+		return nil, err
+	}
+	key := &models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    keyPrefix(plaintext),
+		Hash:      hashAPIKey(plaintext),
+		CreatedAt: models.Now(),
+	}
+	if err := store.CreateAPIKey(key); err != nil {
+		return nil, fmt.Errorf("failed to store api key: %v", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns the keys of a user, or every key when userID is empty.
+func ListAPIKeys(userID string) ([]models.APIKey, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	return store.ListAPIKeys(userID)
+}
+
+// RevokeAPIKey disables a key. Revoking twice is not an error.
+func RevokeAPIKey(id string) error {
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	return store.RevokeAPIKey(id, models.Now())
+}
+
+// AuthenticateAPIKey resolves a plaintext key to the identity of its owner.
+// Unknown and revoked keys return ErrInvalidAPIKey.
+func AuthenticateAPIKey(plaintext string) (*models.Identity, error) {
+	if store == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	key, err := store.GetAPIKeyByHash(hashAPIKey(plaintext))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !key.RevokedAt.IsZero() {
+		return nil, ErrInvalidAPIKey
+	}
+	user, err := store.GetUserByID(key.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := models.Now()
+	if key.LastUsedAt.IsZero() || now.Sub(key.LastUsedAt.Time) >= lastUsedResolution {
+		if err := store.TouchAPIKey(key.ID, now); err != nil {
+			log.Printf("Failed to record the use of api key %s: %v\n", key.ID, err)
+		}
+	}
+	return &models.Identity{UserID: user.ID, Name: user.Name, IsAdmin: user.IsAdmin, KeyID: key.ID}, nil
+}
+
+// BootstrapAPIKey makes the key from the API_KEY setting a key of the "admin"
+// user, creating the user the first time. It lets a fresh installation (or the
+// memory backend) be used before any key was issued with the admin command.
+func BootstrapAPIKey(plaintext string) error {
+	if plaintext == "" {
+		return nil
+	}
+	if store == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if _, err := store.GetAPIKeyByHash(hashAPIKey(plaintext)); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrAPIKeyNotFound) {
+		return err
+	}
+
+	user, err := store.GetUserByName(bootstrapUser)
+	if errors.Is(err, ErrUserNotFound) {
+		user, err = CreateUser(bootstrapUser, true)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := storeAPIKey(user.ID, "API_KEY", plaintext); err != nil {
+		return err
+	}
+	log.Printf("Registered API_KEY as a key of user %q\n", user.Name)
+	return nil
+}
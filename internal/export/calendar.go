@@ -0,0 +1,29 @@
+package export
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// CalendarFeed godoc
+// @Summary Subscribable calendar of tasks
+// @Description The tasks with a due date that the token's owner may see, as an iCalendar feed of VTODO entries. Calendar apps subscribe to the URL returned by POST /calendar/token; the token takes the place of the API key.
+// @Tags calendar
+// @Produce text/calendar
+// @Param token query string true "Calendar token"
+// @Success 200 {string} string "iCalendar data"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /calendar/tasks.ics [get]
+func CalendarFeed(c *gin.Context) {
+	// The feed is private to the token's owner
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	streamTasks(c, models.TaskFilter{VisibleTo: middleware.CurrentIdentity(c)}, func(w io.Writer) taskEncoder {
+		return newICSEncoder(w, "Tasks", time.Now())
+	})
+}
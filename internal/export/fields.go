@@ -0,0 +1,169 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// CSV header styles.
+const (
+	headerPascal = "pascal" // DueDate, the default, which an import reads back
+	headerSnake  = "snake"  // due_date, the JSON field names
+	headerNone   = "none"
+)
+
+// exportField is a column of the JSON, NDJSON and CSV exports.
+type exportField struct {
+	name   string // JSON key, and the CSV header in the snake style
+	header string // CSV header in the pascal style
+	// value returns nil, a string, bool, int, int64 or []string
+	value func(task models.Task, loc *time.Location) interface{}
+}
+
+// exportFields lists every field in the order of models.Task.
+var exportFields = []exportField{
+	{"id", "ID", func(t models.Task, _ *time.Location) interface{} { return t.ID }},
+	{"title", "Title", func(t models.Task, _ *time.Location) interface{} { return t.Title }},
+	{"description", "Description", func(t models.Task, _ *time.Location) interface{} { return t.Description }},
+	{"priority", "Priority", func(t models.Task, _ *time.Location) interface{} {
+		if t.Priority == nil {
+			return nil
+		}
+		return string(*t.Priority)
+	}},
+	{"priority_pinned", "PriorityPinned", func(t models.Task, _ *time.Location) interface{} { return t.PriorityPinned }},
+	{"due_date", "DueDate", func(t models.Task, loc *time.Location) interface{} { return formatTime(t.DueDate, loc) }},
+	{"is_overdue", "IsOverdue", func(t models.Task, _ *time.Location) interface{} { return t.IsOverdue }},
+	{"labels", "Labels", func(t models.Task, _ *time.Location) interface{} { return t.Labels }},
+	{"status", "Status", func(t models.Task, _ *time.Location) interface{} { return string(t.Status) }},
+	{"started_at", "StartedAt", func(t models.Task, loc *time.Location) interface{} { return formatTime(t.StartedAt, loc) }},
+	{"completed_at", "CompletedAt", func(t models.Task, loc *time.Location) interface{} { return formatTime(t.CompletedAt, loc) }},
+	{"parent_id", "ParentID", func(t models.Task, _ *time.Location) interface{} { return optional(t.ParentID) }},
+	{"recurrence", "Recurrence", func(t models.Task, _ *time.Location) interface{} { return t.Recurrence }},
+	{"project", "Project", func(t models.Task, _ *time.Location) interface{} { return t.Project }},
+	{"series_id", "SeriesID", func(t models.Task, _ *time.Location) interface{} { return optional(t.SeriesID) }},
+	{"occurrence", "Occurrence", func(t models.Task, _ *time.Location) interface{} { return t.Occurrence }},
+	{"owner_id", "OwnerID", func(t models.Task, _ *time.Location) interface{} { return optional(t.OwnerID) }},
+	{"created_by", "CreatedBy", func(t models.Task, _ *time.Location) interface{} { return optional(t.CreatedBy) }},
+	{"updated_by", "UpdatedBy", func(t models.Task, _ *time.Location) interface{} { return optional(t.UpdatedBy) }},
+	{"created_at", "CreatedAt", func(t models.Task, loc *time.Location) interface{} { return formatTime(t.CreatedAt, loc) }},
+	{"updated_at", "UpdatedAt", func(t models.Task, loc *time.Location) interface{} { return formatTime(t.UpdatedAt, loc) }},
+	{"version", "Version", func(t models.Task, _ *time.Location) interface{} { return t.Version }},
+}
+
+// defaultCSVFields are the columns of a CSV export without fields=.
+var defaultCSVFields = []string{"id", "title", "description", "priority", "priority_pinned", "due_date", "is_overdue", "labels", "created_at", "updated_at"}
+
+// fieldNames lists the names fields= accepts.
+func fieldNames() []string {
+	names := make([]string, len(exportFields))
+	for i, field := range exportFields {
+		names[i] = field.name
+	}
+	return names
+}
+
+// lookupFields returns the fields named in a comma-separated list, in its order.
+func lookupFields(list []string) ([]exportField, error) {
+	byName := map[string]exportField{}
+	for _, field := range exportFields {
+		byName[field.name] = field
+	}
+	var fields []exportField
+	seen := map[string]bool{}
+	for _, name := range list {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		field, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid field: %s. Valid values are: %v", name, fieldNames())
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate field: %s", name)
+		}
+		seen[name] = true
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields must name at least one field. Valid values are: %v", fieldNames())
+	}
+	return fields, nil
+}
+
+// parseDelimiter reads the delimiter= parameter: a single character, or "tab".
+func parseDelimiter(value string) (rune, error) {
+	if value == "" {
+		return ',', nil
+	}
+	if strings.EqualFold(value, "tab") {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter: %q. Use a single character other than a quote or line break, or tab", value)
+	}
+	return r, nil
+}
+
+// formatTime renders a timestamp as RFC 3339 in loc, or "" when it is unset.
+func formatTime(t models.Timestamp, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Time.In(loc).Format(time.RFC3339)
+}
+
+func optional(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// marshalFields writes the fields of a task as a JSON object, in their order.
+func marshalFields(task models.Task, fields []exportField, loc *time.Location) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(field.value(task, loc))
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(strconv.Quote(field.name))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// csvText renders a field value as a CSV cell.
+func csvText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return fmt.Sprint(value)
+}
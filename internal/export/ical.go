@@ -0,0 +1,162 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
+)
+
+// icalProductID names this API in the PRODID of its calendars.
+const icalProductID = "-//golang_task_manager//Tasks//EN"
+
+// icalUIDDomain makes task UIDs globally unique, as RFC 5545 asks.
+const icalUIDDomain = "golang-task-manager"
+
+// icalPriority maps priorities onto the RFC 5545 scale, where 1 is the
+// highest, 5 medium and 9 the lowest.
+var icalPriority = map[models.Priority]int{
+	models.High:   1,
+	models.Medium: 5,
+	models.Low:    9,
+}
+
+// icsEncoder writes the tasks that have a due date as an RFC 5545 calendar
+// of VTODO components. UIDs come from the task IDs and SEQUENCE from their
+// versions, so calendar apps update entries instead of duplicating them.
+type icsEncoder struct {
+	out   *icalWriter
+	name  string
+	wf    *workflow.Workflow
+	stamp string
+}
+
+func newICSEncoder(w io.Writer, name string, now time.Time) *icsEncoder {
+	return &icsEncoder{
+		out:   &icalWriter{w: w},
+		name:  name,
+		wf:    workflow.Current(),
+		stamp: icalTime(models.NewTimestamp(now)),
+	}
+}
+
+func (e *icsEncoder) begin() error {
+	e.out.line("BEGIN", "VCALENDAR")
+	e.out.line("VERSION", "2.0")
+	e.out.line("PRODID", icalProductID)
+	e.out.line("CALSCALE", "GREGORIAN")
+	e.out.line("METHOD", "PUBLISH")
+	e.out.line("X-WR-CALNAME", escapeText(e.name))
+	return e.out.err
+}
+
+func (e *icsEncoder) encode(task models.Task) error {
+	if task.DueDate.IsZero() {
+		return nil
+	}
+	out := e.out
+	out.line("BEGIN", "VTODO")
+	out.line("UID", taskUID(task.ID))
+	out.line("DTSTAMP", e.stamp)
+	if !task.CreatedAt.IsZero() {
+		out.line("CREATED", icalTime(task.CreatedAt))
+	}
+	if !task.UpdatedAt.IsZero() {
+		out.line("LAST-MODIFIED", icalTime(task.UpdatedAt))
+	}
+	if task.Version > 0 {
+		out.line("SEQUENCE", fmt.Sprint(task.Version-1))
+	}
+	out.line("SUMMARY", escapeText(task.Title))
+	if task.Description != "" {
+		out.line("DESCRIPTION", escapeText(task.Description))
+	}
+	out.line("DUE", icalTime(task.DueDate))
+	if task.Priority != nil {
+		if priority, ok := icalPriority[*task.Priority]; ok {
+			out.line("PRIORITY", fmt.Sprint(priority))
+		}
+	}
+	if len(task.Labels) > 0 {
+		categories := make([]string, len(task.Labels))
+		for i, label := range task.Labels {
+			categories[i] = escapeText(label)
+		}
+		out.line("CATEGORIES", strings.Join(categories, ","))
+	}
+	out.line("STATUS", icalStatus(e.wf, task.Status))
+	if !task.CompletedAt.IsZero() && task.Status != models.StatusCancelled {
+		out.line("COMPLETED", icalTime(task.CompletedAt))
+	}
+	if task.ParentID != nil {
+		out.line("RELATED-TO", taskUID(*task.ParentID))
+	}
+	out.line("END", "VTODO")
+	return out.err
+}
+
+func (e *icsEncoder) end() error {
+	e.out.line("END", "VCALENDAR")
+	return e.out.err
+}
+
+// icalStatus maps a workflow status onto the statuses a VTODO can have.
+func icalStatus(wf *workflow.Workflow, status models.Status) string {
+	switch {
+	case status == models.StatusCancelled:
+		return "CANCELLED"
+	case wf.IsTerminal(status):
+		return "COMPLETED"
+	case wf.IsStarted(status):
+		return "IN-PROCESS"
+	}
+	return "NEEDS-ACTION"
+}
+
+func taskUID(id string) string {
+	return id + "@" + icalUIDDomain
+}
+
+// icalTime formats a timestamp as a UTC DATE-TIME.
+func icalTime(t models.Timestamp) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// textEscaper escapes a TEXT value (RFC 5545, section 3.3.11).
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+// icalWriter writes content lines with CRLF endings, folded so that no line
+// is longer than 75 octets. The first error sticks.
+type icalWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *icalWriter) line(name, value string) {
+	if w.err != nil {
+		return
+	}
+	line := name + ":" + value
+	limit := 75
+	for len(line) > limit {
+		// Fold between characters, never inside a UTF-8 sequence
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, w.err = io.WriteString(w.w, line[:cut]+"\r\n "); w.err != nil {
+			return
+		}
+		// Continuation lines start with a space, which counts against the limit
+		line, limit = line[cut:], 74
+	}
+	_, w.err = io.WriteString(w.w, line+"\r\n")
+}
@@ -1,92 +1,339 @@
 package export
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/csv"
-	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	dbFunc "github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
 	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/policy"
 	"github.com/iabdulzahid/golang_task_manager/pkg/globals"
 )
 
+// flushEvery is how many tasks are written between flushes to the client.
+const flushEvery = 100
+
 // ExportTasks godoc
-// @Summary Export tasks to JSON or CSV
-// @Description Export all tasks to JSON or CSV format based on the requested file format
+// @Summary Export tasks to JSON, NDJSON, CSV or iCalendar
+// @Description Export the tasks the caller may see to JSON, NDJSON (one task per line), CSV or iCalendar (ics, the tasks with a due date as VTODO entries) format based on the requested file format. Viewers cannot export. Tasks are streamed from the database in id order as they are written, gzip-compressed when the client accepts it, and the export stops when the client goes away. The filters of GET /tasks narrow the export. For JSON, NDJSON and CSV, fields picks and orders the fields and tz renders timestamps in an IANA time zone; delimiter and header set the CSV dialect.
 // @Tags tasks
 // @Produce json
-// @Param format query string true "Export format" Enums(json, csv)
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Produce text/calendar
+// @Param format query string true "Export format" Enums(json, ndjson, csv, ics)
+// @Param priority query []string false "Filter by priority (Low, Medium, High); repeat or comma-separate for several" collectionFormat(multi)
+// @Param status query []string false "Filter by status; repeat or comma-separate for several" collectionFormat(multi)
+// @Param label query []string false "Filter by label; repeat for several" collectionFormat(multi)
+// @Param label_match query string false "Whether tasks need any or all of the labels" Enums(any, all)
+// @Param overdue query bool false "Filter by overdue state"
+// @Param due_before query string false "Only tasks due before this RFC 3339 time"
+// @Param due_after query string false "Only tasks due after this RFC 3339 time"
+// @Param created_before query string false "Only tasks created before this RFC 3339 time"
+// @Param created_after query string false "Only tasks created after this RFC 3339 time"
+// @Param q query string false "Case-insensitive search in title and description"
+// @Param project query string false "Only tasks of this project"
+// @Param fields query string false "Comma-separated fields to export, in this order, e.g. id,title,due_date (json, ndjson, csv)"
+// @Param tz query string false "IANA time zone for timestamps, e.g. Europe/Berlin; default UTC (json, ndjson, csv)"
+// @Param delimiter query string false "CSV field delimiter: one character, or tab; default ,"
+// @Param header query string false "CSV header row: pascal (DueDate, the default), snake (due_date) or none" Enums(pascal, snake, none)
+// @Param Accept-Encoding header string false "gzip to receive a compressed export"
 // @Success 200 {string} string "File exported successfully"
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse "Viewers cannot export"
 // @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
 // @Router /tasks/export [get]
 func ExportTasks(c *gin.Context) {
 	format := c.DefaultQuery("format", "json")
-	logger := globals.Logger
-	// Fetch tasks from the database
-	tasks, err := dbFunc.GetTasks(logger)
+	identity := middleware.CurrentIdentity(c)
+	if err := policy.Check(identity, policy.Export, nil); err != nil {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	filter, err := models.ParseTaskFilter(c.Request.URL.Query())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch tasks"})
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	filter.VisibleTo = identity
+	newEncoder, contentType, extension, err := exportEncoder(format, c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Set content type and file name
+	c.Header("Content-Disposition", "attachment; filename=tasks."+extension)
+	c.Header("Content-Type", contentType)
+	streamTasks(c, filter, newEncoder)
+}
+
+// exportEncoder reads the format options of an export request and returns
+// the encoder for its format, with the content type and file extension.
+func exportEncoder(format string, c *gin.Context) (func(w io.Writer) taskEncoder, string, string, error) {
+	if format == "ics" {
+		for _, param := range []string{"fields", "tz", "delimiter", "header"} {
+			if _, ok := c.GetQuery(param); ok {
+				return nil, "", "", fmt.Errorf("%s does not apply to format=ics", param)
+			}
+		}
+		return func(w io.Writer) taskEncoder { return newICSEncoder(w, "Tasks", time.Now()) }, "text/calendar; charset=utf-8", "ics", nil
+	}
+	if format != "json" && format != "ndjson" && format != "csv" {
+		return nil, "", "", fmt.Errorf("Invalid format. Use 'json', 'ndjson', 'csv' or 'ics'")
+	}
+	if format != "csv" {
+		for _, param := range []string{"delimiter", "header"} {
+			if _, ok := c.GetQuery(param); ok {
+				return nil, "", "", fmt.Errorf("%s only applies to format=csv", param)
+			}
+		}
+	}
+
+	// Without fields=, JSON has every field and CSV the default columns
+	names := fieldNames()
+	if format == "csv" {
+		names = defaultCSVFields
+	}
+	if list, ok := c.GetQuery("fields"); ok {
+		names = strings.Split(list, ",")
+	}
+	fields, err := lookupFields(names)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	loc := time.UTC
+	if tz := c.Query("tz"); tz != "" {
+		// "Local" would be the server's zone, which clients cannot know
+		if loc, err = time.LoadLocation(tz); err != nil || tz == "Local" {
+			return nil, "", "", fmt.Errorf("invalid tz: %s. Use an IANA time zone such as Europe/Berlin", tz)
+		}
+	}
 
-	// Export based on the requested format (json or csv)
 	switch format {
 	case "json":
-		exportTasksToJSON(c, tasks)
-	case "csv":
-		exportTasksToCSV(c, tasks)
-	default:
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid format. Use 'json' or 'csv'"})
+		return func(w io.Writer) taskEncoder { return &jsonEncoder{w: w, fields: fields, loc: loc} }, "application/json", "json", nil
+	case "ndjson":
+		return func(w io.Writer) taskEncoder { return &ndjsonEncoder{w: w, fields: fields, loc: loc} }, "application/x-ndjson", "ndjson", nil
+	}
+	delimiter, err := parseDelimiter(c.Query("delimiter"))
+	if err != nil {
+		return nil, "", "", err
+	}
+	header := strings.ToLower(c.DefaultQuery("header", headerPascal))
+	if header != headerPascal && header != headerSnake && header != headerNone {
+		return nil, "", "", fmt.Errorf("invalid header: %s. Valid values are: [%s %s %s]", header, headerPascal, headerSnake, headerNone)
+	}
+	contentType := "text/csv"
+	if delimiter == '\t' {
+		contentType = "text/tab-separated-values"
 	}
+	return func(w io.Writer) taskEncoder {
+		writer := csv.NewWriter(w)
+		writer.Comma = delimiter
+		return &csvEncoder{writer: writer, fields: fields, loc: loc, header: header}
+	}, contentType, "csv", nil
 }
 
-func exportTasksToJSON(c *gin.Context, tasks []models.Task) {
-	// Set content type and file name for JSON export
-	c.Header("Content-Disposition", "attachment; filename=tasks.json")
-	c.Header("Content-Type", "application/json")
+// taskEncoder writes one export format a task at a time.
+type taskEncoder interface {
+	begin() error
+	encode(task models.Task) error
+	end() error
+}
 
-	// Write tasks to response as JSON
-	if err := json.NewEncoder(c.Writer).Encode(tasks); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to export tasks to JSON"})
+// streamTasks writes the tasks matching filter through the encoder made by
+// newEncoder, compressed with gzip when the client accepts it. The status and
+// headers go out with the first bytes, so a failure halfway through can only
+// be logged; the output then ends early, without the encoder's closing part.
+func streamTasks(c *gin.Context, filter models.TaskFilter, newEncoder func(w io.Writer) taskEncoder) {
+	var out io.Writer = c.Writer
+	var zw *gzip.Writer
+	c.Header("Vary", "Accept-Encoding")
+	if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+		c.Header("Content-Encoding", "gzip")
+		zw = gzip.NewWriter(c.Writer)
+		out = zw
+	}
+	buffered := bufio.NewWriter(out)
+	flush := func() error {
+		if err := buffered.Flush(); err != nil {
+			return err
+		}
+		if zw != nil {
+			if err := zw.Flush(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	encoder := newEncoder(buffered)
+	query := models.TaskQuery{TaskFilter: filter, Sort: "id", Order: models.SortAsc}
+	c.Status(http.StatusOK)
+
+	written := 0
+	err := encoder.begin()
+	if err == nil {
+		err = dbFunc.StreamTasks(c.Request.Context(), globals.Logger, query, func(task models.Task) error {
+			if err := encoder.encode(task); err != nil {
+				return err
+			}
+			if written++; written%flushEvery == 0 {
+				return flush()
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = encoder.end()
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil && !c.Writer.Written() {
+		// Nothing went out yet, so the client can still get a proper error
+		for _, header := range []string{"Content-Encoding", "Content-Disposition", "Content-Type"} {
+			c.Writer.Header().Del(header)
+		}
+		log.Printf("Failed to export tasks: %v\n", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch tasks"})
+		return
+	}
+	if err != nil {
+		log.Printf("Export stopped after %d task(s): %v\n", written, err)
 		return
 	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			log.Printf("Failed to finish the compressed export: %v\n", err)
+		}
+	}
 }
 
-func exportTasksToCSV(c *gin.Context, tasks []models.Task) {
-	// Set content type and file name for CSV export
-	c.Header("Content-Disposition", "attachment; filename=tasks.csv")
-	c.Header("Content-Type", "text/csv")
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		// q=0 means "not acceptable"
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
 
-	// Create CSV writer
-	writer := csv.NewWriter(c.Writer)
-	defer writer.Flush()
+// jsonEncoder writes a JSON array of tasks.
+type jsonEncoder struct {
+	w      io.Writer
+	fields []exportField
+	loc    *time.Location
+	count  int
+}
 
-	// Write header
-	err := writer.Write([]string{"ID", "Title", "Description", "Priority", "DueDate", "Labels", "CreatedAt", "UpdatedAt"})
+func (e *jsonEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonEncoder) encode(task models.Task) error {
+	data, err := marshalFields(task, e.fields, e.loc)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to write CSV header"})
-		return
+		return err
 	}
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.count++
+	_, err = e.w.Write(data)
+	return err
+}
 
-	// Write task data
-	for _, task := range tasks {
-		prior := globals.GetAddress(task.Priority)
-		err := writer.Write([]string{
-			task.ID,
-			task.Title,
-			task.Description,
-			string(**prior),
-			task.DueDate,
-			strings.Join(task.Labels, ","),
-			task.CreatedAt,
-			task.UpdatedAt,
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to write task data to CSV"})
-			return
+func (e *jsonEncoder) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// ndjsonEncoder writes one JSON task per line.
+type ndjsonEncoder struct {
+	w      io.Writer
+	fields []exportField
+	loc    *time.Location
+}
+
+func (e *ndjsonEncoder) begin() error { return nil }
+
+func (e *ndjsonEncoder) encode(task models.Task) error {
+	data, err := marshalFields(task, e.fields, e.loc)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+func (e *ndjsonEncoder) end() error { return nil }
+
+// csvEncoder writes the CSV export: a header row, unless the header style
+// is none, then a row per task.
+type csvEncoder struct {
+	writer *csv.Writer
+	fields []exportField
+	loc    *time.Location
+	header string
+}
+
+func (e *csvEncoder) begin() error {
+	if e.header == headerNone {
+		return nil
+	}
+	row := make([]string, len(e.fields))
+	for i, field := range e.fields {
+		row[i] = field.header
+		if e.header == headerSnake {
+			row[i] = field.name
 		}
 	}
+	return e.writer.Write(row)
+}
+
+func (e *csvEncoder) encode(task models.Task) error {
+	row := make([]string, len(e.fields))
+	for i, field := range e.fields {
+		row[i] = csvText(field.value(task, e.loc))
+	}
+	if err := e.writer.Write(row); err != nil {
+		return err
+	}
+	// The csv.Writer buffers too; hand rows on so flushes reach the client
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) end() error {
+	e.writer.Flush()
+	return e.writer.Error()
 }
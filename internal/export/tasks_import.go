@@ -0,0 +1,241 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// TaskReader reads the tasks of an import one at a time, so the input never
+// has to fit in memory.
+type TaskReader interface {
+	// Next returns the next task and the line it starts on. It returns io.EOF
+	// after the last task and a *RowError for a task that cannot be read, after
+	// which the following ones still can. Any other error means the rest of the
+	// input cannot be read.
+	Next() (*models.Task, int, error)
+}
+
+// RowError is a task of an import that cannot be read.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// NewTaskReader reads tasks in one of the formats ExportTasks writes: "json",
+// an array of tasks, or "csv", comma-separated with a pascal or snake header row.
+// CSV imports read ID, Title, Description, Priority, PriorityPinned, DueDate
+// and Labels; the other export columns are accepted and ignored. An imported
+// priority is pinned like one sent to POST /tasks, unless the task says it was
+// not (priority_pinned false, as exported), in which case the priority policy
+// picks it again.
+func NewTaskReader(format string, r io.Reader) (TaskReader, error) {
+	switch format {
+	case "json":
+		lines := &lineCounter{r: r}
+		return &jsonTaskReader{lines: lines, decoder: json.NewDecoder(lines)}, nil
+	case "csv":
+		return newCSVTaskReader(r)
+	}
+	return nil, fmt.Errorf("Invalid format. Use 'json' or 'csv'")
+}
+
+type jsonTaskReader struct {
+	lines   *lineCounter
+	decoder *json.Decoder
+	started bool
+}
+
+func (r *jsonTaskReader) Next() (*models.Task, int, error) {
+	if !r.started {
+		token, err := r.decoder.Token()
+		if err == io.EOF {
+			return nil, 1, fmt.Errorf("the input is empty")
+		}
+		if delim, ok := token.(json.Delim); err != nil || !ok || delim != '[' {
+			return nil, r.lines.lineAt(r.decoder.InputOffset()), fmt.Errorf("a JSON import is an array of tasks")
+		}
+		r.started = true
+	}
+	if !r.decoder.More() {
+		if _, err := r.decoder.Token(); err != nil {
+			return nil, r.errorLine(err), err
+		}
+		return nil, 0, io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
+		return nil, r.errorLine(err), err
+	}
+	line := r.lines.lineAt(r.decoder.InputOffset() - int64(len(raw)))
+
+	// The task is checked on its own, so a bad one does not stop the import
+	var task models.Task
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&task); err != nil {
+		return nil, line, &RowError{Line: line, Err: err}
+	}
+	var pinned struct {
+		PriorityPinned *bool `json:"priority_pinned"`
+	}
+	if json.Unmarshal(raw, &pinned) == nil && pinned.PriorityPinned != nil && !*pinned.PriorityPinned {
+		task.Priority = nil
+	}
+	return &task, line, nil
+}
+
+// errorLine is the line a JSON syntax error was found on, or the last line
+// read when the input ended early.
+func (r *jsonTaskReader) errorLine(err error) int {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return r.lines.lineAt(syntaxErr.Offset)
+	}
+	return r.lines.lineAt(r.lines.read)
+}
+
+// lineCounter passes reads through and tells the line a byte offset of the
+// input is on. Offsets must be asked for in increasing order; only the
+// newlines past the last one are kept.
+type lineCounter struct {
+	r        io.Reader
+	read     int64
+	newlines []int64
+	line     int
+}
+
+func (l *lineCounter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			l.newlines = append(l.newlines, l.read+int64(i))
+		}
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+// lineAt returns the 1-based line of offset.
+func (l *lineCounter) lineAt(offset int64) int {
+	for len(l.newlines) > 0 && l.newlines[0] < offset {
+		l.newlines = l.newlines[1:]
+		l.line++
+	}
+	return l.line + 1
+}
+
+type csvTaskReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	width   int
+}
+
+func newCSVTaskReader(r io.Reader) (*csvTaskReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the input is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Columns are known by their header in either style
+	known := map[string]string{}
+	valid := make([]string, len(exportFields))
+	for i, field := range exportFields {
+		known[strings.ToLower(field.header)] = field.header
+		known[field.name] = field.header
+		valid[i] = field.header
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		column, ok := known[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("line 1: unknown column %q. Valid columns are: %v", name, valid)
+		}
+		if _, seen := columns[column]; seen {
+			return nil, fmt.Errorf("line 1: duplicate column %q", name)
+		}
+		columns[column] = i
+	}
+	for _, required := range []string{"Title", "DueDate"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("line 1: missing column %q", required)
+		}
+	}
+	return &csvTaskReader{reader: reader, columns: columns, width: len(header)}, nil
+}
+
+func (r *csvTaskReader) Next() (*models.Task, int, error) {
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, parseErr.StartLine, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	line, _ := r.reader.FieldPos(0)
+	if len(record) != r.width {
+		return nil, line, &RowError{Line: line, Err: fmt.Errorf("expected %d fields, found %d", r.width, len(record))}
+	}
+
+	field := func(column string) string {
+		if i, ok := r.columns[column]; ok {
+			return record[i]
+		}
+		return ""
+	}
+	task := &models.Task{
+		ID:          strings.TrimSpace(field("ID")),
+		Title:       field("Title"),
+		Description: field("Description"),
+		Labels:      []string{},
+	}
+	if priority := strings.TrimSpace(field("Priority")); priority != "" {
+		p := models.Priority(priority)
+		task.Priority = &p
+	}
+	// Only a priority marked as pinned is kept; the policy works out the others again
+	pinned := false
+	if value := strings.TrimSpace(field("PriorityPinned")); value != "" {
+		if pinned, err = strconv.ParseBool(value); err != nil {
+			return nil, line, &RowError{Line: line, Err: fmt.Errorf("PriorityPinned: %q is not true or false", value)}
+		}
+	}
+	if !pinned {
+		task.Priority = nil
+	}
+	if task.DueDate, err = models.ParseTimestamp(strings.TrimSpace(field("DueDate"))); err != nil {
+		return nil, line, &RowError{Line: line, Err: fmt.Errorf("DueDate: %v", err)}
+	}
+	for _, label := range strings.Split(field("Labels"), ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			task.Labels = append(task.Labels, label)
+		}
+	}
+	// CreatedAt and UpdatedAt are set by the server
+	return task, line, nil
+}
@@ -0,0 +1,179 @@
+// Package leader picks one of the servers sharing a database to run the
+// background jobs. The servers compete for a lease in the database; the one
+// holding it renews it while it runs, and when it stops renewing, e.g.
+// because it died, another one takes over once the lease expires.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// BackgroundJobs is the lease of the server running the background jobs.
+const BackgroundJobs = "background-jobs"
+
+// Options configures an Elector.
+type Options struct {
+	// InstanceID names this server in the lease. It must differ between servers.
+	InstanceID string
+	// TTL is how long the lease lasts without a renewal, and so how long the
+	// jobs stop when the leader dies. It is renewed every TTL/3.
+	TTL time.Duration
+}
+
+// OptionsFromEnv reads INSTANCE_ID (default: the host name and a random
+// suffix) and LEADER_LEASE_TTL (a duration, default 15s).
+func OptionsFromEnv() (Options, error) {
+	opts := Options{InstanceID: os.Getenv("INSTANCE_ID"), TTL: 15 * time.Second}
+	if opts.InstanceID == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "server"
+		}
+		opts.InstanceID = fmt.Sprintf("%s-%s", host, uuid.New().String()[:8])
+	}
+	if value := os.Getenv("LEADER_LEASE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 3*time.Second {
+			return opts, fmt.Errorf("LEADER_LEASE_TTL: invalid duration %q, use at least 3s", value)
+		}
+		opts.TTL = ttl
+	}
+	return opts, nil
+}
+
+// Elector campaigns for a lease and runs a job while it holds it.
+type Elector struct {
+	name string
+	opts Options
+
+	mu      sync.Mutex
+	leading bool
+}
+
+// NewElector returns an Elector for the lease name.
+func NewElector(name string, opts Options) *Elector {
+	return &Elector{name: name, opts: opts}
+}
+
+// Run campaigns for the lease until ctx is done. While this server holds it,
+// job runs with a context that is cancelled when the lease is lost; Run waits
+// for job to return before it campaigns again. On the way out the lease is
+// released, so another server can take over at once.
+func (e *Elector) Run(ctx context.Context, job func(ctx context.Context)) {
+	renewEvery := e.opts.TTL / 3
+	var cancelJob context.CancelFunc
+	var jobDone chan struct{}
+	var heldUntil time.Time
+
+	stepDown := func(reason string) {
+		if cancelJob == nil {
+			return
+		}
+		cancelJob()
+		<-jobDone
+		cancelJob = nil
+		e.setLeading(false)
+		log.Printf("Instance %s stopped leading %s: %s\n", e.opts.InstanceID, e.name, reason)
+	}
+
+	for {
+		start := time.Now()
+		// A database that does not answer before the next try counts as a
+		// failed renewal rather than holding up the step down
+		attempt, cancel := context.WithTimeout(ctx, renewEvery)
+		acquired, err := database.AcquireLease(attempt, e.name, e.opts.InstanceID, e.opts.TTL)
+		cancel()
+		switch {
+		case err != nil:
+			log.Printf("Failed to renew lease %s: %v\n", e.name, err)
+			// Without a renewal the lease may lapse, and another server take
+			// it, before the next try
+			if start.Add(renewEvery).After(heldUntil) {
+				stepDown("the lease could not be renewed")
+			}
+		case acquired:
+			heldUntil = start.Add(e.opts.TTL)
+			if cancelJob == nil {
+				var jobCtx context.Context
+				jobCtx, cancelJob = context.WithCancel(ctx)
+				jobDone = make(chan struct{})
+				go func(done chan struct{}) {
+					defer close(done)
+					job(jobCtx)
+				}(jobDone)
+				e.setLeading(true)
+				log.Printf("Instance %s is now leading %s\n", e.opts.InstanceID, e.name)
+			}
+		default:
+			stepDown("another instance holds the lease")
+		}
+
+		timer := time.NewTimer(renewEvery)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if cancelJob != nil {
+				stepDown("shutting down")
+				if err := database.ReleaseLease(e.name, e.opts.InstanceID); err != nil {
+					log.Printf("Failed to release lease %s: %v\n", e.name, err)
+				}
+			}
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (e *Elector) setLeading(leading bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.leading = leading
+}
+
+// IsLeader reports whether this server holds the lease and runs the job.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading
+}
+
+// Status reports who holds the lease, as seen by this server.
+func (e *Elector) Status() (*models.LeaderStatus, error) {
+	lease, err := database.GetLease(e.name)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.LeaderStatus{Lease: e.name, Instance: e.opts.InstanceID, IsLeader: e.IsLeader()}
+	if lease != nil {
+		status.Leader, status.AcquiredAt, status.ExpiresAt = lease.Holder, lease.AcquiredAt, lease.ExpiresAt
+	}
+	return status, nil
+}
+
+var (
+	mu      sync.RWMutex
+	current *Elector
+)
+
+// Current returns the Elector of the background jobs, or nil before Set.
+func Current() *Elector {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set makes e the Elector reported by Current.
+func Set(e *Elector) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = e
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/auth"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// IdentityKey is the gin context key under which Auth stores the caller.
+const IdentityKey = "identity"
+
+// authResultKey is the gin context key of the outcome of authenticate.
+const authResultKey = "authResult"
+
+var (
+	jwtVerifier    *auth.Verifier
+	defaultRole    = models.RoleEditor
+	authConfigOnce sync.Once
+)
+
+// Auth rejects unauthenticated requests with 401 and stores the identity of
+// the caller in the context. Callers send an API key in the X-API-Key header
+// or a bearer token in the Authorization header; when JWT_JWKS is configured
+// the bearer token may also be a JWT, whose sub and roles claims make up the
+// identity. The caller's role and grants are resolved as well; users with no
+// global role get RBAC_DEFAULT_ROLE (editor unless set).
+func Auth() gin.HandlerFunc {
+	loadAuthConfig()
+
+	return func(c *gin.Context) {
+		result := authenticate(c)
+		if result.status == http.StatusUnauthorized {
+			unauthorized(c, result.message)
+			return
+		}
+		if result.identity == nil {
+			c.JSON(result.status, models.ErrorResponse{Error: result.message})
+			c.Abort()
+			return
+		}
+
+		c.Set(IdentityKey, result.identity)
+		c.Next()
+	}
+}
+
+// authResult is the outcome of authenticate: the caller, or the status and
+// message to reject the request with.
+type authResult struct {
+	identity *models.Identity
+	status   int
+	message  string
+}
+
+// authenticate identifies the caller of c by the API key in the X-API-Key
+// header or the bearer token in the Authorization header. The outcome is kept
+// in the context, so the rate limiter and Auth look the caller up only once.
+func authenticate(c *gin.Context) authResult {
+	if value, ok := c.Get(authResultKey); ok {
+		return value.(authResult)
+	}
+	result := lookUpCaller(c)
+	c.Set(authResultKey, result)
+	return result
+}
+
+func lookUpCaller(c *gin.Context) authResult {
+	loadAuthConfig()
+	apiKey := c.GetHeader(APIKeyHeader)
+	if apiKey == "" {
+		if scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+			apiKey = strings.TrimSpace(token)
+		}
+	}
+	if apiKey == "" {
+		return authResult{status: http.StatusUnauthorized, message: "Missing API key"}
+	}
+
+	var identity *models.Identity
+	var err error
+	if jwtVerifier != nil && auth.LooksLikeJWT(apiKey) {
+		identity, err = authenticateJWT(apiKey)
+	} else {
+		identity, err = database.AuthenticateAPIKey(apiKey)
+	}
+	if errors.Is(err, database.ErrInvalidAPIKey) {
+		return authResult{status: http.StatusUnauthorized, message: "Invalid API key"}
+	}
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return authResult{status: http.StatusUnauthorized, message: err.Error()}
+	}
+	if err != nil {
+		log.Printf("Failed to authenticate request: %v\n", err)
+		return authResult{status: http.StatusInternalServerError, message: "Failed to authenticate request"}
+	}
+
+	if err := database.ResolveAccess(identity, defaultRole); err != nil {
+		log.Printf("Failed to resolve the roles of user %s: %v\n", identity.UserID, err)
+		return authResult{status: http.StatusInternalServerError, message: "Failed to authenticate request"}
+	}
+	return authResult{identity: identity}
+}
+
+// CalendarAuth authenticates calendar feed requests by the token query
+// parameter, since calendar apps cannot send headers. The token only works on
+// the routes this middleware guards.
+func CalendarAuth() gin.HandlerFunc {
+	loadAuthConfig()
+
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			unauthorized(c, "Missing calendar token")
+			return
+		}
+		identity, err := database.AuthenticateCalendarToken(token)
+		if errors.Is(err, database.ErrInvalidCalendarToken) || errors.Is(err, database.ErrUserNotFound) {
+			unauthorized(c, "Invalid calendar token")
+			return
+		}
+		if err == nil {
+			err = database.ResolveAccess(identity, defaultRole)
+		}
+		if err != nil {
+			log.Printf("Failed to authenticate calendar request: %v\n", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to authenticate request"})
+			c.Abort()
+			return
+		}
+
+		c.Set(IdentityKey, identity)
+		c.Next()
+	}
+}
+
+// loadAuthConfig reads the JWT and RBAC settings once.
+func loadAuthConfig() {
+	authConfigOnce.Do(func() {
+		verifier, err := auth.VerifierFromEnv()
+		if err != nil {
+			log.Fatal("Invalid JWT configuration: ", err)
+		}
+		if verifier != nil {
+			log.Printf("JWT authentication enabled: issuer %s, audience %s\n", verifier.Issuer, verifier.Audience)
+		}
+		jwtVerifier = verifier
+
+		if value := os.Getenv("RBAC_DEFAULT_ROLE"); value != "" {
+			if defaultRole, err = models.ParseRole(value); err != nil {
+				log.Fatal("RBAC_DEFAULT_ROLE: ", err)
+			}
+		}
+	})
+}
+
+// authenticateJWT verifies a token and maps its claims onto an identity. The
+// issuer and subject make up the user ID (see jwtUserID), so tasks stay with
+// the caller across tokens; the roles claim feeds the caller's global role
+// (see database.ResolveAccess).
+func authenticateJWT(token string) (*models.Identity, error) {
+	claims, err := jwtVerifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	name := claims.Name
+	if name == "" {
+		name = claims.Subject
+	}
+	user, err := database.ProvisionUser(jwtUserID(claims), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Identity{UserID: user.ID, Name: user.Name, IsAdmin: user.IsAdmin, Roles: claims.Roles}, nil
+}
+
+// jwtUserID is the user ID of a token caller: "jwt:<iss>:<sub>". The prefix
+// keeps a subject from taking over the local user that has the same ID.
+func jwtUserID(claims *auth.Claims) string {
+	return "jwt:" + claims.Issuer + ":" + claims.Subject
+}
+
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="tasks"`)
+	c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: message})
+	c.Abort()
+}
+
+// CurrentIdentity returns the caller stored by Auth, or nil on routes that
+// are not authenticated.
+func CurrentIdentity(c *gin.Context) *models.Identity {
+	if value, ok := c.Get(IdentityKey); ok {
+		if identity, ok := value.(*models.Identity); ok {
+			return identity
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// IdempotencyKeyHeader is the request header naming an idempotency key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ReplayedHeader marks a response replayed for a repeated idempotency key.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the keys clients may send.
+const maxIdempotencyKeyLength = 255
+
+var (
+	idempotencyTTL        = 24 * time.Hour
+	idempotencyConfigOnce sync.Once
+)
+
+// Idempotency makes a POST safe to retry. The first request with an
+// Idempotency-Key header runs normally, and its response is stored with a
+// fingerprint of the request for IDEMPOTENCY_TTL (24h unless set). A repeat
+// with the same key and body gets the stored response again, marked with the
+// Idempotent-Replayed header; the same key with another body is rejected with
+// 422, and a repeat while the first request still runs with 409. Keys are
+// scoped per caller, so it has to run after Auth. Requests without the header
+// are not affected, and server errors are not stored, so they can be retried.
+func Idempotency() gin.HandlerFunc {
+	idempotencyConfigOnce.Do(func() {
+		if value := os.Getenv("IDEMPOTENCY_TTL"); value != "" {
+			ttl, err := time.ParseDuration(value)
+			if err != nil || ttl <= 0 {
+				log.Fatalf("IDEMPOTENCY_TTL: %q is not a positive duration", value)
+			}
+			idempotencyTTL = ttl
+		}
+	})
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Idempotency-Key must be 1 to 255 printable ASCII characters"})
+			c.Abort()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var scope string
+		if identity := CurrentIdentity(c); identity != nil {
+			scope = identity.UserID
+		}
+		record, err := database.BeginIdempotentRequest(scope, key, requestFingerprint(c.Request, body), idempotencyTTL)
+		switch {
+		case errors.Is(err, database.ErrIdempotencyKeyReused):
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: err.Error()})
+			c.Abort()
+			return
+		case errors.Is(err, database.ErrIdempotencyKeyInUse):
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+			c.Abort()
+			return
+		case err != nil:
+			log.Printf("Failed to look up idempotency key: %v\n", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to look up idempotency key"})
+			c.Abort()
+			return
+		case record != nil:
+			c.Header(ReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		err = database.FinishIdempotentRequest(scope, key, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		if err != nil {
+			log.Printf("Failed to store the response for idempotency key %q: %v\n", key, err)
+		}
+	}
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestFingerprint digests the method, path and body of a request. JSON
+// bodies are normalized first, so whitespace and member order do not count.
+func requestFingerprint(r *http.Request, body []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err == nil {
+		if normalized, err := json.Marshal(value); err == nil {
+			body = normalized
+		}
+	}
+	digest := sha256.New()
+	io.WriteString(digest, r.Method+" "+r.URL.Path+"\n")
+	digest.Write(body)
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body it passes through.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -1,7 +1,14 @@
 package middleware
 
 import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,46 +16,307 @@ import (
 	"github.com/iabdulzahid/golang_task_manager/internal/models"
 )
 
-var requestCount = make(map[string]int) // Tracks requests per IP
-var mu sync.Mutex
+// APIKeyHeader carries the client's API key. Requests with a valid one are
+// rate limited per key instead of per IP.
+const APIKeyHeader = "X-API-Key"
 
-// RateLimiter middleware that limits requests based on IP
-func RateLimiter() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		mu.Lock()
-		defer mu.Unlock()
+// limiterShards spreads the buckets over independent locks so concurrent
+// clients rarely wait on each other.
+const limiterShards = 32
+
+// Rate is a token-bucket quota: Limit requests per Period, refilled evenly.
+// A zero Limit means unlimited.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRate reads "100/1m", "10/s" or "5000/1h". "off" disables limiting.
+func ParseRate(value string) (Rate, error) {
+	value = strings.TrimSpace(value)
+	if value == "off" || value == "0" {
+		return Rate{}, nil
+	}
+	limit, period, ok := strings.Cut(value, "/")
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if !ok || err != nil || n < 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: expected <requests>/<period>, e.g. 100/1m", value)
+	}
+	period = strings.TrimSpace(period)
+	if period == "s" || period == "m" || period == "h" {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: %q is not a duration", value, period)
+	}
+	return Rate{Limit: n, Period: d}, nil
+}
 
-		ip := c.ClientIP()
-		// Set a maximum of 100 requests per minute per IP
-		maxRequests := 100
-		window := 60 * time.Second
+func (r Rate) String() string {
+	if r.Limit == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+}
+
+// RateLimitConfig holds the quotas. Groups, Users and Keys override Default
+// for a route group, a user ID and an API key ID. A key limit wins over a user
+// limit, which wins over a group limit.
+type RateLimitConfig struct {
+	Default Rate
+	Groups  map[string]Rate
+	Users   map[string]Rate
+	Keys    map[string]Rate
+}
+
+// RateLimitConfigFromEnv reads RATE_LIMIT (default 100/1m), RATE_LIMIT_GROUPS,
+// RATE_LIMIT_USERS and RATE_LIMIT_KEYS. The last three are comma-separated
+// name=rate lists, e.g. RATE_LIMIT_GROUPS=tasks=100/1m,export=10/1m.
+// RATE_LIMIT_KEYS names API keys by their ID, so no secret has to sit in the
+// environment.
+func RateLimitConfigFromEnv() (RateLimitConfig, error) {
+	cfg := RateLimitConfig{Default: Rate{Limit: 100, Period: time.Minute}}
+	if value := os.Getenv("RATE_LIMIT"); value != "" {
+		rate, err := ParseRate(value)
+		if err != nil {
+			return cfg, fmt.Errorf("RATE_LIMIT: %v", err)
+		}
+		cfg.Default = rate
+	}
+
+	var err error
+	if cfg.Groups, err = parseRateList(os.Getenv("RATE_LIMIT_GROUPS")); err != nil {
+		return cfg, fmt.Errorf("RATE_LIMIT_GROUPS: %v", err)
+	}
+	if cfg.Users, err = parseRateList(os.Getenv("RATE_LIMIT_USERS")); err != nil {
+		return cfg, fmt.Errorf("RATE_LIMIT_USERS: %v", err)
+	}
+	if cfg.Keys, err = parseRateList(os.Getenv("RATE_LIMIT_KEYS")); err != nil {
+		return cfg, fmt.Errorf("RATE_LIMIT_KEYS: %v", err)
+	}
+	return cfg, nil
+}
 
-		// Initialize the request count if not already
-		if requestCount[ip] == 0 {
-			go resetRequestCount(ip, window)
+func parseRateList(value string) (map[string]Rate, error) {
+	rates := map[string]Rate{}
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected name=rate", item)
 		}
+		rate, err := ParseRate(spec)
+		if err != nil {
+			return nil, err
+		}
+		rates[name] = rate
+	}
+	return rates, nil
+}
+
+// bucket is the token bucket of one client in one route group.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   Rate
+}
+
+// take refills the bucket up to now and spends cost tokens if there are that
+// many; otherwise it spends none. It returns the tokens left and how long
+// until the request could pass and until the bucket is full again.
+func (b *bucket) take(now time.Time, cost float64) (allowed bool, remaining int, retryAfter, reset time.Duration) {
+	perToken := b.rate.Period / time.Duration(b.rate.Limit)
+	capacity := float64(b.rate.Limit)
+
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+	if b.tokens >= cost {
+		b.tokens -= cost
+		allowed = true
+	} else {
+		retryAfter = time.Duration((math.Min(cost, capacity) - b.tokens) * float64(perToken))
+	}
+	reset = time.Duration((capacity - b.tokens) * float64(perToken))
+	return allowed, int(b.tokens), retryAfter, reset
+}
+
+type shard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// Limiter is a sharded token-bucket rate limiter. Buckets that have been idle
+// long enough to refill completely are evicted, since a fresh bucket behaves
+// the same.
+type Limiter struct {
+	config RateLimitConfig
+	shards [limiterShards]shard
+	stop   chan struct{}
+	now    func() time.Time // The clock, replaced in tests
+}
+
+// NewLimiter returns a Limiter and starts its eviction loop, which runs every
+// cleanupInterval until Stop is called.
+func NewLimiter(config RateLimitConfig, cleanupInterval time.Duration) *Limiter {
+	l := &Limiter{config: config, stop: make(chan struct{}), now: time.Now}
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]*bucket)
+	}
+	go l.evictLoop(cleanupInterval)
+	return l
+}
 
-		// Check the number of requests from this IP
-		if requestCount[ip] > maxRequests {
-			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
-				Error: "Rate limit exceeded. Please try again later.",
-			})
-			c.Abort()
+// Stop ends the eviction loop.
+func (l *Limiter) Stop() {
+	close(l.stop)
+}
+
+func (l *Limiter) evictLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
 			return
+		case now := <-ticker.C:
+			l.evict(now)
 		}
+	}
+}
 
-		// Increment request count
-		requestCount[ip]++
+func (l *Limiter) evict(now time.Time) {
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.mu.Lock()
+		for key, b := range s.buckets {
+			if now.Sub(b.last) >= b.rate.Period {
+				delete(s.buckets, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// rateFor picks the quota of a request: the API key's, then the user's, then
+// the route group's, then the default. identity is nil for anonymous requests.
+func (l *Limiter) rateFor(group string, identity *models.Identity) Rate {
+	if identity != nil {
+		if rate, ok := l.config.Keys[identity.KeyID]; ok && identity.KeyID != "" {
+			return rate
+		}
+		if rate, ok := l.config.Users[identity.UserID]; ok {
+			return rate
+		}
+	}
+	if rate, ok := l.config.Groups[group]; ok {
+		return rate
+	}
+	return l.config.Default
+}
+
+// allow spends cost tokens from the bucket of key, creating it on first use.
+func (l *Limiter) allow(key string, rate Rate, now time.Time, cost int) (bool, int, time.Duration, time.Duration) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	s := &l.shards[h.Sum32()%limiterShards]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[key]
+	if !ok || b.rate != rate {
+		b = &bucket{tokens: float64(rate.Limit), last: now, rate: rate}
+		s.buckets[key] = b
+	}
+	return b.take(now, float64(cost))
+}
+
+// rateChargeKey holds the function that charges a request further tokens.
+const rateChargeKey = "rateLimitCharge"
+
+// ChargeRateLimit makes the current request count as n more requests against
+// its rate limit, for handlers that do the work of several, e.g. a bulk
+// request. It spends all n tokens or none; when the client does not have
+// them it answers 429 and returns false. Without a limit it always returns true.
+func ChargeRateLimit(c *gin.Context, n int) bool {
+	charge, ok := c.Get(rateChargeKey)
+	if !ok || n <= 0 {
+		return true
+	}
+	return charge.(func(int) bool)(n)
+}
+
+// Middleware limits the requests of a route group. Clients are told apart by
+// the API key they authenticate with, by the user of a token, and by IP when
+// they send no credentials or invalid ones, so made-up keys do not get buckets
+// of their own. Every limited response carries RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset; rejected ones also carry
+// Retry-After. All values are in seconds.
+func (l *Limiter) Middleware(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := authenticate(c).identity
+		rate := l.rateFor(group, identity)
+		if rate.Limit == 0 {
+			c.Next()
+			return
+		}
+
+		client := "ip:" + c.ClientIP()
+		switch {
+		case identity != nil && identity.KeyID != "":
+			client = "key:" + identity.KeyID
+		case identity != nil:
+			client = "user:" + identity.UserID
+		}
+
+		charge := func(cost int) bool {
+			allowed, remaining, retryAfter, reset := l.allow(group+"|"+client, rate, l.now(), cost)
+			c.Header("RateLimit-Limit", strconv.Itoa(rate.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+			c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+			c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rate.Limit, ceilSeconds(rate.Period)))
+
+			if !allowed {
+				c.Header("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+				c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+					Error: "Rate limit exceeded. Please try again later.",
+				})
+				c.Abort()
+			}
+			return allowed
+		}
+		if !charge(1) {
+			return
+		}
+		c.Set(rateChargeKey, charge)
 
 		// Continue processing request
 		c.Next()
 	}
 }
 
-// Reset the request count after the window (60 seconds)
-func resetRequestCount(ip string, window time.Duration) {
-	time.Sleep(window)
-	mu.Lock()
-	defer mu.Unlock()
-	requestCount[ip] = 0
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+var (
+	defaultLimiter     *Limiter
+	defaultLimiterOnce sync.Once
+)
+
+// RateLimiter returns the middleware for a route group, backed by a shared
+// Limiter configured from the environment on first use.
+func RateLimiter(group string) gin.HandlerFunc {
+	defaultLimiterOnce.Do(func() {
+		config, err := RateLimitConfigFromEnv()
+		if err != nil {
+			log.Fatal("Invalid rate limit configuration: ", err)
+		}
+		log.Printf("Rate limit: default %s, groups %v, users %v, keys %v\n", config.Default, config.Groups, config.Users, config.Keys)
+		defaultLimiter = NewLimiter(config, time.Minute)
+	})
+	return defaultLimiter.Middleware(group)
 }
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+var limiterEpoch = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+// testClock is a fake clock for the Limiter, moved on by advance.
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(t *testing.T, config RateLimitConfig) (*Limiter, *testClock) {
+	t.Helper()
+	clock := &testClock{now: limiterEpoch}
+	l := NewLimiter(config, time.Hour)
+	l.now = clock.Now
+	t.Cleanup(l.Stop)
+	return l, clock
+}
+
+// newLimitedRouter serves GET /<group> through the limiter. The caller is
+// taken from the X-Test-Caller header: "key:<id>:<user>" for an API key,
+// "user:<id>" for a token and nothing for an anonymous request, so no
+// database is needed. Handlers charge the query parameter n with
+// ChargeRateLimit.
+func newLimitedRouter(l *Limiter, groups ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		kind, rest, _ := strings.Cut(c.GetHeader("X-Test-Caller"), ":")
+		switch kind {
+		case "key":
+			keyID, userID, _ := strings.Cut(rest, ":")
+			c.Set(authResultKey, authResult{identity: &models.Identity{UserID: userID, KeyID: keyID}})
+		case "user":
+			c.Set(authResultKey, authResult{identity: &models.Identity{UserID: rest}})
+		}
+	})
+	for _, group := range groups {
+		router.GET("/"+group, l.Middleware(group), func(c *gin.Context) {
+			n, _ := strconv.Atoi(c.Query("n"))
+			if !ChargeRateLimit(c, n) {
+				return
+			}
+			c.Status(http.StatusOK)
+		})
+	}
+	return router
+}
+
+func limitedRequest(router *gin.Engine, path, caller string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if caller != "" {
+		req.Header.Set("X-Test-Caller", caller)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Rate
+		wantErr bool
+	}{
+		{value: "100/1m", want: Rate{Limit: 100, Period: time.Minute}},
+		{value: "10/s", want: Rate{Limit: 10, Period: time.Second}},
+		{value: " 5000 / 1h ", want: Rate{Limit: 5000, Period: time.Hour}},
+		{value: "off", want: Rate{}},
+		{value: "0", want: Rate{}},
+		{value: "100", wantErr: true},
+		{value: "-1/m", wantErr: true},
+		{value: "ten/m", wantErr: true},
+		{value: "10/fortnight", wantErr: true},
+		{value: "10/0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRate(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitConfigFromEnv(t *testing.T) {
+	t.Setenv("RATE_LIMIT", "50/1m")
+	t.Setenv("RATE_LIMIT_GROUPS", "export=10/1m")
+	t.Setenv("RATE_LIMIT_USERS", "alice=1000/1m")
+	t.Setenv("RATE_LIMIT_KEYS", "key-1=5000/1m, key-2=off")
+
+	cfg, err := RateLimitConfigFromEnv()
+	if err != nil {
+		t.Fatalf("RateLimitConfigFromEnv: %v", err)
+	}
+	want := RateLimitConfig{
+		Default: Rate{Limit: 50, Period: time.Minute},
+		Groups:  map[string]Rate{"export": {Limit: 10, Period: time.Minute}},
+		Users:   map[string]Rate{"alice": {Limit: 1000, Period: time.Minute}},
+		Keys:    map[string]Rate{"key-1": {Limit: 5000, Period: time.Minute}, "key-2": {}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+
+	t.Setenv("RATE_LIMIT_KEYS", "=5/1m")
+	if _, err := RateLimitConfigFromEnv(); err == nil {
+		t.Error("RATE_LIMIT_KEYS without a key ID was accepted")
+	}
+}
+
+func TestRateFor(t *testing.T) {
+	l, _ := newTestLimiter(t, RateLimitConfig{
+		Default: Rate{Limit: 100, Period: time.Minute},
+		Groups:  map[string]Rate{"export": {Limit: 10, Period: time.Minute}},
+		Users:   map[string]Rate{"alice": {Limit: 1000, Period: time.Minute}},
+		Keys:    map[string]Rate{"key-1": {Limit: 5000, Period: time.Minute}},
+	})
+	tests := []struct {
+		name     string
+		group    string
+		identity *models.Identity
+		want     int
+	}{
+		{"anonymous", "tasks", nil, 100},
+		{"group", "export", nil, 10},
+		{"user over group", "export", &models.Identity{UserID: "alice"}, 1000},
+		{"key over user", "export", &models.Identity{UserID: "alice", KeyID: "key-1"}, 5000},
+		{"user of an unlisted key", "tasks", &models.Identity{UserID: "alice", KeyID: "key-2"}, 1000},
+		{"unlisted user", "export", &models.Identity{UserID: "bob", KeyID: "key-3"}, 10},
+	}
+	for _, tt := range tests {
+		if got := l.rateFor(tt.group, tt.identity).Limit; got != tt.want {
+			t.Errorf("%s: limit = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	rate := Rate{Limit: 2, Period: time.Second}
+	b := &bucket{tokens: 2, last: limiterEpoch, rate: rate}
+	now := limiterEpoch
+
+	steps := []struct {
+		advance        time.Duration
+		cost           float64
+		wantAllowed    bool
+		wantRemaining  int
+		wantRetryAfter time.Duration
+	}{
+		{0, 1, true, 1, 0},
+		{0, 1, true, 0, 0},
+		{0, 1, false, 0, 500 * time.Millisecond},
+		{250 * time.Millisecond, 1, false, 0, 250 * time.Millisecond},
+		{250 * time.Millisecond, 1, true, 0, 0},
+		// An idle bucket refills to its capacity and no further
+		{time.Hour, 3, false, 2, 0},
+		{0, 2, true, 0, 0},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		allowed, remaining, retryAfter, _ := b.take(now, step.cost)
+		if allowed != step.wantAllowed || remaining != step.wantRemaining || retryAfter != step.wantRetryAfter {
+			t.Errorf("step %d: take = %v, %d, %s; want %v, %d, %s", i, allowed, remaining, retryAfter,
+				step.wantAllowed, step.wantRemaining, step.wantRetryAfter)
+		}
+	}
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	l, clock := newTestLimiter(t, RateLimitConfig{Default: Rate{Limit: 3, Period: time.Minute}})
+	router := newLimitedRouter(l, "tasks")
+
+	for i := 0; i < 3; i++ {
+		if w := limitedRequest(router, "/tasks", "key:key-1:alice"); w.Code != http.StatusOK {
+			t.Fatalf("request %d of the burst: status %d", i, w.Code)
+		}
+	}
+	w := limitedRequest(router, "/tasks", "key:key-1:alice")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the burst: status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("Retry-After = %q, want 20", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "3;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want 3;w=60", got)
+	}
+
+	clock.advance(20 * time.Second)
+	w = limitedRequest(router, "/tasks", "key:key-1:alice")
+	if w.Code != http.StatusOK {
+		t.Fatalf("request after a refill: status %d", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "60" {
+		t.Errorf("RateLimit-Reset = %q, want 60", got)
+	}
+}
+
+func TestRateLimitKeying(t *testing.T) {
+	l, _ := newTestLimiter(t, RateLimitConfig{
+		Default: Rate{Limit: 1, Period: time.Minute},
+		Keys:    map[string]Rate{"key-3": {Limit: 2, Period: time.Minute}},
+	})
+	router := newLimitedRouter(l, "tasks", "export")
+
+	// Every caller below has a bucket of its own in each route group
+	callers := []string{
+		"key:key-1:alice",
+		"key:key-2:alice", // Another key of the same user
+		"user:alice",      // A token of the same user
+		"user:bob",
+		"", // Anonymous, by IP
+	}
+	for _, group := range []string{"/tasks", "/export"} {
+		for _, caller := range callers {
+			if w := limitedRequest(router, group, caller); w.Code != http.StatusOK {
+				t.Errorf("first request of %q to %s: status %d", caller, group, w.Code)
+			}
+			if w := limitedRequest(router, group, caller); w.Code != http.StatusTooManyRequests {
+				t.Errorf("second request of %q to %s: status %d, want 429", caller, group, w.Code)
+			}
+		}
+	}
+
+	// A key override applies to that key only
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if w := limitedRequest(router, "/tasks", "key:key-3:alice"); w.Code != want {
+			t.Errorf("request %d of key-3: status %d, want %d", i, w.Code, want)
+		}
+	}
+
+	// The buckets of the same client always land in the same shard
+	buckets := 0
+	for i := range l.shards {
+		buckets += len(l.shards[i].buckets)
+	}
+	if want := 2*len(callers) + 1; buckets != want {
+		t.Errorf("%d buckets, want %d", buckets, want)
+	}
+}
+
+func TestChargeRateLimit(t *testing.T) {
+	l, clock := newTestLimiter(t, RateLimitConfig{Default: Rate{Limit: 5, Period: 5 * time.Second}})
+	router := newLimitedRouter(l, "tasks")
+	caller := "key:key-1:alice"
+
+	// The request itself costs one, the batch four more
+	w := limitedRequest(router, "/tasks?n=4", caller)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch within the limit: status %d", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+
+	// A batch larger than what is left is refused whole and spends nothing
+	clock.advance(2 * time.Second)
+	w = limitedRequest(router, "/tasks?n=3", caller)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("batch over the limit: status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if w := limitedRequest(router, "/tasks", caller); w.Code != http.StatusOK {
+		t.Errorf("request after a refused batch: status %d", w.Code)
+	}
+
+	// Without a rate limiter there is nothing to charge
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if !ChargeRateLimit(c, 100) {
+		t.Error("ChargeRateLimit without a limiter refused the request")
+	}
+}
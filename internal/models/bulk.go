@@ -0,0 +1,53 @@
+package models
+
+// MaxBulkOperations is the most operations one POST /tasks/bulk may carry
+const MaxBulkOperations = 100
+
+// Bulk request modes
+const (
+	BulkAtomic     = "atomic"      // All operations succeed or none is applied
+	BulkBestEffort = "best_effort" // Each operation is applied on its own
+)
+
+// Bulk operation kinds
+const (
+	BulkCreate  = "create"
+	BulkUpdate  = "update"
+	BulkDelete  = "delete"
+	BulkRelabel = "relabel"
+)
+
+// BulkRequest is the body of POST /tasks/bulk
+type BulkRequest struct {
+	Mode       string          `json:"mode" enums:"atomic,best_effort" example:"atomic"` // Defaults to atomic
+	Operations []BulkOperation `json:"operations"`
+}
+
+// BulkOperation is one change of a bulk request
+type BulkOperation struct {
+	Op           string   `json:"op" enums:"create,update,delete,relabel" example:"create"`
+	ID           string   `json:"id,omitempty"`                                // Task to update, delete or relabel
+	IfMatch      string   `json:"if_match,omitempty" example:"\"3\""`          // ETag the task must still have, as with the If-Match header
+	Children     string   `json:"children,omitempty" enums:"reparent,cascade"` // delete: what happens to subtasks
+	Task         *Task    `json:"task,omitempty"`                              // create and update: the task, as for POST and PUT /tasks
+	AddLabels    []string `json:"add_labels,omitempty" example:"urgent"`       // relabel
+	RemoveLabels []string `json:"remove_labels,omitempty" example:"later"`     // relabel
+}
+
+// BulkResult is the outcome of one operation, in request order
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status" example:"200"` // HTTP status the operation would have had on its own; 424 for operations not applied because another one failed
+	Error  string `json:"error,omitempty"`
+	Task   *Task  `json:"task,omitempty"` // The created or updated task
+}
+
+// BulkResponse reports the outcome of a bulk request
+type BulkResponse struct {
+	Mode      string       `json:"mode"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// CalendarToken is a newly issued calendar feed token, shown only once
+type CalendarToken struct {
+	Token string `json:"token" example:"tmcal_..."`
+	URL   string `json:"url" example:"https://tasks.example.com/calendar/tasks.ics?token=tmcal_..."` // Subscribe to this URL in a calendar app
+}
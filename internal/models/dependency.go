@@ -0,0 +1,28 @@
+package models
+
+// Dependency is an edge of the dependency graph: TaskID is blocked by
+// DependsOnID until DependsOnID reaches a terminal status
+type Dependency struct {
+	TaskID      string    `json:"task_id"`
+	DependsOnID string    `json:"depends_on_id"`
+	CreatedAt   Timestamp `json:"created_at" swaggertype:"string" format:"date-time"`
+}
+
+// DependencyRequest is the body of POST /tasks/{id}/dependencies
+type DependencyRequest struct {
+	DependsOnID string `json:"depends_on_id" binding:"required"`
+}
+
+// TaskDependencies lists the edges touching one task
+type TaskDependencies struct {
+	BlockedBy []Dependency `json:"blocked_by"` // Tasks this task waits for
+	Blocks    []Dependency `json:"blocks"`     // Tasks waiting for this task
+}
+
+// CriticalPath is the chain of open tasks that decides when all dependent work can finish
+type CriticalPath struct {
+	Tasks           []Task    `json:"tasks"`
+	Start           Timestamp `json:"start" swaggertype:"string" format:"date-time"`
+	Finish          Timestamp `json:"finish" swaggertype:"string" format:"date-time"` // Due date of the last task on the path
+	DurationSeconds int64     `json:"duration_seconds"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import "fmt"
+
+// Role is a level of access. Each role includes the rights of the ones below it.
+type Role string
+
+const (
+	RoleViewer Role = "viewer" // Read tasks
+	RoleEditor Role = "editor" // Read, create and change tasks
+	RoleAdmin  Role = "admin"  // Everything, including deleting others' tasks and granting roles
+)
+
+// Rank orders roles; unknown roles and the empty role rank 0.
+func (r Role) Rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// AtLeast reports whether r includes the rights of other.
+func (r Role) AtLeast(other Role) bool {
+	return r.Rank() > 0 && r.Rank() >= other.Rank()
+}
+
+// MaxRole returns the higher of two roles.
+func MaxRole(a, b Role) Role {
+	if b.Rank() > a.Rank() {
+		return b
+	}
+	return a
+}
+
+// ParseRole validates a role name.
+func ParseRole(value string) (Role, error) {
+	role := Role(value)
+	if role.Rank() == 0 {
+		return "", fmt.Errorf("invalid role: %s. Valid values are: %v", value, []Role{RoleViewer, RoleEditor, RoleAdmin})
+	}
+	return role, nil
+}
+
+// Grant gives a user a role everywhere (no project and no task), on every
+// task of a project, or on a single task.
+type Grant struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Role      Role      `json:"role" enums:"viewer,editor,admin"`
+	Project   *string   `json:"project"`
+	TaskID    *string   `json:"task_id"`
+	CreatedAt Timestamp `json:"created_at" swaggertype:"string" format:"date-time"`
+	CreatedBy *string   `json:"created_by"`
+}
+
+// IsGlobal reports whether the grant applies everywhere.
+func (g Grant) IsGlobal() bool {
+	return g.Project == nil && g.TaskID == nil
+}
+
+// Covers reports whether the grant applies to a task.
+func (g Grant) Covers(task Task) bool {
+	switch {
+	case g.TaskID != nil:
+		return *g.TaskID == task.ID
+	case g.Project != nil:
+		return *g.Project == task.Project
+	}
+	return true
+}
+
+// GrantRequest is the body of POST /admin/grants. Leave project and task_id
+// empty for a global grant; set at most one of them.
+type GrantRequest struct {
+	User    string `json:"user" binding:"required" example:"alice"` // User ID or name
+	Role    Role   `json:"role" binding:"required" enums:"viewer,editor,admin"`
+	Project string `json:"project,omitempty"`
+	TaskID  string `json:"task_id,omitempty"`
+}
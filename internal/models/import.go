@@ -0,0 +1,24 @@
+package models
+
+// MaxImportErrors caps the row errors an import reports
+const MaxImportErrors = 1000
+
+// ImportResult reports the outcome of POST /tasks/import
+type ImportResult struct {
+	Mode            string        `json:"mode" example:"create"`
+	DryRun          bool          `json:"dry_run"`
+	Rows            int           `json:"rows"`    // Tasks read from the input
+	Created         int           `json:"created"` // In a dry run: tasks that would be created
+	Updated         int           `json:"updated"` // In a dry run: tasks that would be updated
+	Failed          int           `json:"failed"`
+	Errors          []ImportError `json:"errors"`
+	ErrorsTruncated bool          `json:"errors_truncated,omitempty"` // More than MaxImportErrors rows failed
+	Incomplete      bool          `json:"incomplete,omitempty"`       // The input broke off; the last error says where
+}
+
+// ImportError is a row of an import that was rejected
+type ImportError struct {
+	Line  int    `json:"line" example:"2"` // Line of the input the row starts on
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
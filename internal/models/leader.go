@@ -0,0 +1,11 @@
+package models
+
+// LeaderStatus tells which server runs the background jobs
+type LeaderStatus struct {
+	Lease      string    `json:"lease" example:"background-jobs"`
+	Leader     string    `json:"leader" example:"api-2-4f1c2a9b"`                     // Instance holding the lease; empty when none does
+	AcquiredAt Timestamp `json:"acquired_at" swaggertype:"string" format:"date-time"` // Since when the leader holds the lease
+	ExpiresAt  Timestamp `json:"expires_at" swaggertype:"string" format:"date-time"`  // When the lease lapses unless the leader renews it
+	Instance   string    `json:"instance" example:"api-1-9b0e77d2"`                   // Instance that answered
+	IsLeader   bool      `json:"is_leader"`                                           // Whether the instance that answered runs the background jobs
+}
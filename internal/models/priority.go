@@ -0,0 +1,21 @@
+package models
+
+// PriorityExplanation tells how the priority policy arrived at a task's priority
+type PriorityExplanation struct {
+	TaskID              string          `json:"task_id"`
+	Priority            *Priority       `json:"priority" enum:"Low,Medium,High"` // The task's current priority
+	DecidedBy           string          `json:"decided_by" example:"due-soon"`   // Name of the rule that decided it, or "user", "default" or "status"
+	Pinned              bool            `json:"pinned"`                          // Whether a client chose the priority
+	RespectUserPriority bool            `json:"respect_user_priority"`           // Whether the policy leaves chosen priorities alone
+	Base                Priority        `json:"base" enum:"Low,Medium,High"`     // Priority the rules start from
+	Rules               []PriorityMatch `json:"rules"`                           // Every rule of the policy, in order
+}
+
+// PriorityMatch is the outcome of one rule of the priority policy for a task
+type PriorityMatch struct {
+	Rule     string    `json:"rule" example:"due-soon"`
+	Action   string    `json:"action" example:"set High"`       // "set <priority>" or "escalate <n>"
+	Matched  bool      `json:"matched"`                         // Whether the task meets the rule's conditions
+	Applied  bool      `json:"applied"`                         // Whether the rule changed the priority; a set rule only applies when no earlier one did
+	Priority *Priority `json:"priority" enum:"Low,Medium,High"` // Priority after the rule, when applied
+}
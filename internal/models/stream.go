@@ -0,0 +1,16 @@
+package models
+
+// Task event types of the change stream, besides the database change kinds
+// (created, updated, deleted, overdue)
+const (
+	TaskEventLeft  = "left"  // An update made the task stop matching the stream's filters
+	TaskEventReset = "reset" // Changes were missed; fetch the tasks again
+)
+
+// TaskEvent is a change to a task, as sent by GET /tasks/stream and GET /tasks/stream/ws
+type TaskEvent struct {
+	ID   string    `json:"id" example:"m2x8k1qz-42"`                     // Send as Last-Event-ID to resume after this event
+	Type string    `json:"type" example:"updated"`                       // created, updated, deleted, overdue, left or reset
+	Task *Task     `json:"task,omitempty"`                               // The task after the change; absent on reset
+	Time Timestamp `json:"time" swaggertype:"string" format:"date-time"` // When the change was made
+}
@@ -2,15 +2,40 @@ package models
 
 // Task struct for task model
 type Task struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Priority    *Priority `json:"priority" enum:"Low,Medium,High"` // Swagger annotation for enum
-	DueDate     string    `json:"due_date"`
-	IsOverdue   bool      `json:"is_overdue"` // Computed field
-	Labels      []string  `json:"labels"`
-	CreatedAt   string    `json:"created_at"`
-	UpdatedAt   string    `json:"updated_at"`
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	Description    string    `json:"description"`
+	Priority       *Priority `json:"priority" enum:"Low,Medium,High"` // Swagger annotation for enum
+	PriorityPinned bool      `json:"priority_pinned"`                 // Read-only: set when a client chose the priority; the priority policy decides the others
+	DueDate        Timestamp `json:"due_date" swaggertype:"string" format:"date-time"`
+	IsOverdue      bool      `json:"is_overdue"` // Computed field
+	Labels         []string  `json:"labels"`
+	Status         Status    `json:"status" example:"todo"` // Changed through POST /tasks/{id}/transitions
+	StartedAt      Timestamp `json:"started_at" swaggertype:"string" format:"date-time"`
+	CompletedAt    Timestamp `json:"completed_at" swaggertype:"string" format:"date-time"`
+	ParentID       *string   `json:"parent_id"`                                 // Optional parent task, making this a subtask
+	Recurrence     string    `json:"recurrence" example:"FREQ=WEEKLY;BYDAY=MO"` // RFC 5545 RRULE; empty for one-off tasks
+	Project        string    `json:"project" example:"website"`                 // Optional; roles can be granted per project
+	SeriesID       *string   `json:"series_id"`                                 // Shared by every occurrence of a recurring task
+	Occurrence     int       `json:"occurrence"`                                // 1-based position in the series
+	OwnerID        *string   `json:"owner_id"`                                  // User the task belongs to
+	CreatedBy      *string   `json:"created_by"`                                // Null for tasks the server created, e.g. recurring occurrences
+	UpdatedBy      *string   `json:"updated_by"`
+	CreatedAt      Timestamp `json:"created_at" swaggertype:"string" format:"date-time"`
+	UpdatedAt      Timestamp `json:"updated_at" swaggertype:"string" format:"date-time"`
+	Version        int64     `json:"version" example:"1"` // Bumped on every write; the task's ETag
+
+	// Read-only fields filled in by the API
+	Rollup   *TaskRollup `json:"rollup,omitempty"`   // Present on tasks that have subtasks
+	Children []Task      `json:"children,omitempty"` // Only with GET /tasks/{id}?tree=true
+	Urgency  *Urgency    `json:"urgency,omitempty"`  // Only with GET /tasks/next
+}
+
+// TaskRollup summarizes the direct subtasks of a task
+type TaskRollup struct {
+	ChildCount       int            `json:"child_count"`
+	ChildrenByStatus map[Status]int `json:"children_by_status"`
+	PercentComplete  float64        `json:"percent_complete"` // Share of children in a terminal status (done, cancelled)
 }
 
 // Define the custom type for Priority
@@ -23,6 +48,23 @@ const (
 	High   Priority = "High"
 )
 
+// Status is a step of the task workflow. The statuses below make up the
+// default workflow; the transition graph itself lives in internal/workflow.
+type Status string
+
+const (
+	StatusTodo       Status = "todo"
+	StatusInProgress Status = "in_progress"
+	StatusBlocked    Status = "blocked"
+	StatusDone       Status = "done"
+	StatusCancelled  Status = "cancelled"
+)
+
+// TransitionRequest is the body of POST /tasks/{id}/transitions
+type TransitionRequest struct {
+	Status Status `json:"status" binding:"required" example:"in_progress"`
+}
+
 // SuccessResponse is a struct for success responses
 type SuccessResponse struct {
 	Data interface{} `json:"data"`
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Label match modes for TaskFilter.LabelMatch.
+const (
+	LabelMatchAny = "any"
+	LabelMatchAll = "all"
+)
+
+// Sort orders for TaskQuery.Order.
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// Page size limits for GET /tasks.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// SortableFields lists the task fields GET /tasks can be sorted by.
+var SortableFields = []string{"priority", "due_date", "created_at", "updated_at", "title", "description", "is_overdue", "status", "id"}
+
+// TaskFilter narrows a task listing. Zero values mean "no constraint".
+type TaskFilter struct {
+	Priorities    []Priority
+	Statuses      []Status
+	Labels        []string
+	LabelMatch    string // LabelMatchAny (default) or LabelMatchAll
+	Overdue       *bool
+	DueBefore     Timestamp
+	DueAfter      Timestamp
+	CreatedBefore Timestamp
+	CreatedAfter  Timestamp
+	Search        string // Case-insensitive match on title or description
+	ParentID      string // Only direct subtasks of this task
+	Project       string // Only tasks of this project
+
+	// VisibleTo limits the results to tasks the caller may see. The API sets
+	// it from the authenticated identity; it is never read from the query string.
+	VisibleTo *Identity
+}
+
+// TaskQuery is a filtered, sorted and paginated task listing.
+type TaskQuery struct {
+	TaskFilter
+	Sort   string
+	Order  string
+	Limit  int
+	Cursor string
+}
+
+// ParseTaskFilter reads the filter query parameters shared by task listing and export:
+// priority, status, label, label_match, overdue, due_before, due_after, created_before, created_after, q, parent_id and project.
+// priority and status accept repeated keys and comma-separated values; label accepts repeated keys.
+func ParseTaskFilter(values url.Values) (TaskFilter, error) {
+	var filter TaskFilter
+	var err error
+
+	for _, p := range splitList(values["priority"]) {
+		priority, ok := parsePriority(p)
+		if !ok {
+			return filter, fmt.Errorf("invalid priority: %s. Valid values are: [Low Medium High]", p)
+		}
+		filter.Priorities = append(filter.Priorities, priority)
+	}
+
+	for _, status := range splitList(values["status"]) {
+		filter.Statuses = append(filter.Statuses, Status(strings.ToLower(status)))
+	}
+
+	// Labels may contain commas, so only repeated keys select several labels
+	for _, label := range values["label"] {
+		if label = strings.TrimSpace(label); label != "" {
+			filter.Labels = append(filter.Labels, label)
+		}
+	}
+	filter.LabelMatch = strings.ToLower(values.Get("label_match"))
+	switch filter.LabelMatch {
+	case "":
+		filter.LabelMatch = LabelMatchAny
+	case LabelMatchAny, LabelMatchAll:
+	default:
+		return filter, fmt.Errorf("invalid label_match: %s. Valid values are: [any all]", filter.LabelMatch)
+	}
+
+	if raw := values.Get("overdue"); raw != "" {
+		overdue, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid overdue: %s. Use true or false", raw)
+		}
+		filter.Overdue = &overdue
+	}
+
+	timeParams := []struct {
+		name string
+		dest *Timestamp
+	}{
+		{"due_before", &filter.DueBefore},
+		{"due_after", &filter.DueAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"created_after", &filter.CreatedAfter},
+	}
+	for _, param := range timeParams {
+		if *param.dest, err = ParseTimestamp(values.Get(param.name)); err != nil {
+			return filter, fmt.Errorf("invalid %s: %v", param.name, err)
+		}
+	}
+
+	filter.Search = strings.TrimSpace(values.Get("q"))
+	filter.ParentID = strings.TrimSpace(values.Get("parent_id"))
+	filter.Project = strings.TrimSpace(values.Get("project"))
+	return filter, nil
+}
+
+// ParseTaskQuery reads the GET /tasks query parameters: the filters of
+// ParseTaskFilter plus sort, order, limit and cursor.
+func ParseTaskQuery(values url.Values) (TaskQuery, error) {
+	filter, err := ParseTaskFilter(values)
+	if err != nil {
+		return TaskQuery{}, err
+	}
+	query := TaskQuery{TaskFilter: filter, Cursor: values.Get("cursor")}
+
+	query.Sort = strings.ToLower(values.Get("sort"))
+	if query.Sort == "" {
+		query.Sort = "priority"
+	}
+	if !isSortableField(query.Sort) {
+		return query, fmt.Errorf("invalid sort: %s. Valid values are: %v", query.Sort, SortableFields)
+	}
+
+	query.Order = strings.ToLower(values.Get("order"))
+	switch query.Order {
+	case "":
+		query.Order = SortAsc
+	case SortAsc, SortDesc:
+	default:
+		return query, fmt.Errorf("invalid order: %s. Valid values are: [asc desc]", query.Order)
+	}
+
+	query.Limit = DefaultPageSize
+	if raw := values.Get("limit"); raw != "" {
+		query.Limit, err = strconv.Atoi(raw)
+		if err != nil || query.Limit < 1 || query.Limit > MaxPageSize {
+			return query, fmt.Errorf("invalid limit: %s. Use a number between 1 and %d", raw, MaxPageSize)
+		}
+	}
+	return query, nil
+}
+
+// Matches reports whether task passes every constraint of the filter.
+func (f TaskFilter) Matches(task Task) bool {
+	if len(f.Priorities) > 0 {
+		found := false
+		for _, p := range f.Priorities {
+			if task.Priority != nil && *task.Priority == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, s := range f.Statuses {
+			if task.Status == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Labels) > 0 {
+		matched := 0
+		for _, want := range f.Labels {
+			for _, have := range task.Labels {
+				if have == want {
+					matched++
+					break
+				}
+			}
+		}
+		if matched == 0 || (f.LabelMatch == LabelMatchAll && matched < len(f.Labels)) {
+			return false
+		}
+	}
+
+	if f.Overdue != nil && task.IsOverdue != *f.Overdue {
+		return false
+	}
+	if !f.DueBefore.IsZero() && (task.DueDate.IsZero() || !task.DueDate.Before(f.DueBefore.Time)) {
+		return false
+	}
+	if !f.DueAfter.IsZero() && (task.DueDate.IsZero() || !task.DueDate.After(f.DueAfter.Time)) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && (task.CreatedAt.IsZero() || !task.CreatedAt.Before(f.CreatedBefore.Time)) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && (task.CreatedAt.IsZero() || !task.CreatedAt.After(f.CreatedAfter.Time)) {
+		return false
+	}
+
+	if f.Project != "" && task.Project != f.Project {
+		return false
+	}
+	if f.ParentID != "" && (task.ParentID == nil || *task.ParentID != f.ParentID) {
+		return false
+	}
+	if f.VisibleTo != nil && !f.VisibleTo.CanSee(task) {
+		return false
+	}
+
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		if !strings.Contains(strings.ToLower(task.Title), search) && !strings.Contains(strings.ToLower(task.Description), search) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSortableField(field string) bool {
+	for _, f := range SortableFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePriority accepts priorities case-insensitively ("high" -> High).
+func parsePriority(value string) (Priority, bool) {
+	for _, p := range []Priority{Low, Medium, High} {
+		if strings.EqualFold(value, string(p)) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// splitList flattens repeated and comma-separated query values, dropping blanks.
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Timestamp is a point in time that travels over JSON as an RFC 3339 string,
+// and as "" when unset, so clients see the same values as when timestamps were
+// stored as text. In the database it maps to a real timestamp column (NULL when unset).
+type Timestamp struct {
+	time.Time
+}
+
+// timestampLayouts are the textual forms accepted when scanning, covering
+// RFC 3339 and the formats SQLite drivers write for timestamp columns.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// NewTimestamp truncates t to whole seconds, the precision clients have always received.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t.Truncate(time.Second)}
+}
+
+// Now returns the current time as a Timestamp.
+func Now() Timestamp {
+	return NewTimestamp(time.Now())
+}
+
+// ParseTimestamp parses an RFC 3339 string; an empty string is the zero Timestamp.
+func ParseTimestamp(value string) (Timestamp, error) {
+	if value == "" {
+		return Timestamp{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp %q, expected RFC 3339 (e.g. 2024-12-01T00:00:00Z)", value)
+	}
+	return NewTimestamp(t), nil
+}
+
+// String formats the timestamp as RFC 3339 in UTC, or "" when unset.
+func (t Timestamp) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Time.UTC().Format(time.RFC3339)
+}
+
+// Ptr returns nil for an unset timestamp, which is handy for optional SQL parameters.
+func (t Timestamp) Ptr() *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t.Time
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = Timestamp{}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("timestamp must be an RFC 3339 string")
+	}
+	parsed, err := ParseTimestamp(value)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Value implements driver.Valuer; timestamps are always written in UTC.
+func (t Timestamp) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.Time.UTC(), nil
+}
+
+// Scan implements sql.Scanner.
+func (t *Timestamp) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = Timestamp{}
+		return nil
+	case time.Time:
+		*t = NewTimestamp(v)
+		return nil
+	case []byte:
+		return t.scanString(string(v))
+	case string:
+		return t.scanString(v)
+	}
+	return fmt.Errorf("cannot scan %T into Timestamp", src)
+}
+
+func (t *Timestamp) scanString(value string) error {
+	if value == "" {
+		*t = Timestamp{}
+		return nil
+	}
+	for _, layout := range timestampLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			*t = NewTimestamp(parsed)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as a timestamp", value)
+}
@@ -0,0 +1,15 @@
+package models
+
+// Urgency tells how pressing a task is: the sum of the scores of its terms
+type Urgency struct {
+	Score float64       `json:"score" example:"14.6"`
+	Terms []UrgencyTerm `json:"terms"`
+}
+
+// UrgencyTerm is one contribution to the urgency score, Value times Weight
+type UrgencyTerm struct {
+	Factor string  `json:"factor" example:"due"`  // priority, due, age, blocking, blocked or label:<name>
+	Value  float64 `json:"value" example:"0.733"` // Between 0 and 1, or the number of waiting tasks for blocking
+	Weight float64 `json:"weight" example:"12"`
+	Score  float64 `json:"score" example:"8.8"`
+}
@@ -0,0 +1,81 @@
+package models
+
+// User is someone who calls the API with an API key
+type User struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	IsAdmin   bool      `json:"is_admin"` // Admins see and manage every task
+	CreatedAt Timestamp `json:"created_at" swaggertype:"string" format:"date-time"`
+}
+
+// APIKey is a credential of a user. The key itself is only shown once, when it
+// is issued; afterwards it is known by its ID and prefix.
+type APIKey struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Hash       string    `json:"-"`
+	CreatedAt  Timestamp `json:"created_at" swaggertype:"string" format:"date-time"`
+	LastUsedAt Timestamp `json:"last_used_at" swaggertype:"string" format:"date-time"`
+	RevokedAt  Timestamp `json:"revoked_at" swaggertype:"string" format:"date-time"`
+}
+
+// Identity is the authenticated caller of a request, from an API key or a JWT
+type Identity struct {
+	UserID  string   `json:"user_id"`
+	Name    string   `json:"name"`
+	IsAdmin bool     `json:"is_admin"`
+	KeyID   string   `json:"key_id,omitempty"` // Set for API keys
+	Roles   []string `json:"roles,omitempty"`  // From the token's roles claim
+
+	// Role is the caller's global role and Grants their project and task
+	// grants, both filled in after authentication
+	Role   Role    `json:"role"`
+	Grants []Grant `json:"-"`
+}
+
+// RoleOn returns the caller's role on a task, or "" when they have none. It
+// is the highest of: their global role, on tasks they own; any project grant
+// covering the task; any grant on the task itself. Admins are admin everywhere.
+func (i *Identity) RoleOn(task Task) Role {
+	if i.IsAdmin {
+		return RoleAdmin
+	}
+	var role Role
+	if i.Owns(task) {
+		role = i.Role
+	}
+	for _, grant := range i.Grants {
+		if !grant.IsGlobal() && grant.Covers(task) {
+			role = MaxRole(role, grant.Role)
+		}
+	}
+	return role
+}
+
+// RoleInProject returns the caller's role for new tasks in a project: their
+// global role or a grant on the project, whichever is higher.
+func (i *Identity) RoleInProject(project string) Role {
+	if i.IsAdmin {
+		return RoleAdmin
+	}
+	role := i.Role
+	for _, grant := range i.Grants {
+		if project != "" && grant.Project != nil && *grant.Project == project {
+			role = MaxRole(role, grant.Role)
+		}
+	}
+	return role
+}
+
+// Owns reports whether the caller owns the task.
+func (i *Identity) Owns(task Task) bool {
+	return task.OwnerID != nil && *task.OwnerID == i.UserID
+}
+
+// CanSee reports whether the caller may read the task: admins see every
+// task, everyone else the tasks they own or hold a grant on.
+func (i *Identity) CanSee(task Task) bool {
+	return i.RoleOn(task).AtLeast(RoleViewer)
+}
@@ -1,76 +1,211 @@
 package monitor
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	zLogger "github.com/iabdulzahid/go-logger/logger"
 	"github.com/iabdulzahid/golang_task_manager/internal/database"
-	"github.com/iabdulzahid/golang_task_manager/pkg/globals"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/priority"
+	"github.com/iabdulzahid/golang_task_manager/internal/scheduler"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
 )
 
-// monitorOverdueTasks checks tasks and updates their overdue status in the database
-func TaskMonitor(logger zLogger.Logger) {
-	// logger := globals.Logger
-	db := globals.DB
+// Options configures the TaskMonitor.
+type Options struct {
+	// SweepInterval is how often every task is checked, in case a change was
+	// missed, e.g. one made by another server.
+	SweepInterval time.Duration
+	// Backoff spaces out the retries of a task or sweep that failed.
+	Backoff scheduler.Backoff
+}
+
+// DefaultOptions sweep every 10 minutes and retry after 1s up to 5m.
+var DefaultOptions = Options{
+	SweepInterval: 10 * time.Minute,
+	Backoff:       scheduler.Backoff{Min: time.Second, Max: 5 * time.Minute},
+}
+
+// OptionsFromEnv reads MONITOR_SWEEP_INTERVAL (a duration such as 10m) over
+// the DefaultOptions.
+func OptionsFromEnv() (Options, error) {
+	opts := DefaultOptions
+	if value := os.Getenv("MONITOR_SWEEP_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return opts, fmt.Errorf("MONITOR_SWEEP_INTERVAL: invalid duration %q, e.g. 10m", value)
+		}
+		opts.SweepInterval = interval
+	}
+	return opts, nil
+}
+
+// TaskMonitor marks tasks overdue the moment their due date passes, stores the
+// priority the policy gives them as it changes and continues recurring series.
+// Instead of scanning the tasks on a timer it keeps the moments they are due
+// for a check in a Scheduler, which the database's change notifications keep
+// up to date, and sweeps all tasks now and then.
+type TaskMonitor struct {
+	logger    zLogger.Logger
+	opts      Options
+	scheduler scheduler.Scheduler
+}
+
+// NewTaskMonitor returns a TaskMonitor scheduling on a scheduler.Heap.
+func NewTaskMonitor(logger zLogger.Logger, opts Options) *TaskMonitor {
+	m := &TaskMonitor{logger: logger, opts: opts}
+	m.scheduler = scheduler.NewHeap(m.checkTask, opts.Backoff)
+	return m
+}
+
+// Run watches the tasks until ctx is done.
+func (m *TaskMonitor) Run(ctx context.Context) {
+	stop := database.ObserveTasks(m.taskChanged)
+	defer stop()
 
-	ticker := time.NewTicker(5 * time.Second)
-	logger.Info("TaskMonitor........")
-	// Create a ticker that ticks every 12 hours
-	// ticker := time.NewTicker(12 * time.Hour)
-	// Run a goroutine to periodically check app status
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.scheduler.Run(ctx)
+	}()
+
+	failures := 0
 	for {
-		logger.Info("TaskMonitor........inside for loop")
+		wait := m.opts.SweepInterval
+		if err := m.sweep(ctx); err != nil && ctx.Err() == nil {
+			failures++
+			wait = m.opts.Backoff.Delay(failures)
+			m.logger.Error(fmt.Sprintf("TaskMonitor: sweep failed, retrying in %s", wait), err)
+		} else {
+			failures = 0
+		}
+
+		timer := time.NewTimer(wait)
 		select {
-		case <-ticker.C:
-			logger.Info("TaskMonitor........inside ticker")
-			// Fetch tasks from the database
-			tasks, err := database.GetTasks(logger)
-			if err != nil {
-				logger.Info("TaskMonitor", "err", fmt.Sprintf("Error fetching tasks: %s", err))
-				logger.Error("TaskMonitor: Error fetching tasks", err)
-				return
-			}
-			logger.Info("TaskMonitor", "tasks", tasks)
-			// Loop through tasks and update the overdue status
-			for _, task := range tasks {
-				logger.Info("TaskMonitor........", "task", task)
-				if task.DueDate != "" {
-					dueDate, err := time.Parse(time.RFC3339, task.DueDate)
-					if err != nil {
-						// logger.Info("TaskMonitor", "msg", fmt.Sprintf("Error parsing due date for task %s: %v", task.ID, err))
-						logger.Error(fmt.Sprintf("TaskMonitor: Error parsing due date for task %s", task.ID), err)
-						continue
-					}
-					logger.Info("TaskMonitor", "dueDate", dueDate)
-					globals.SetPriorityBasedOnDueDate(logger, &task)
-					logger.Info("TaskMonitor", "task.Priority", task.Priority)
-					// If the task is overdue, set the IsOverdue flag to true
-					if dueDate.Before(time.Now()) {
-						// Update the task in the database to reflect the overdue status
-						// You would need to run a query to update the task in the database
-						// Assuming you have a `db.Exec` function for running SQL queries
-						_, err := db.Exec(
-							"UPDATE tasks SET is_overdue = $1, priority = $2 WHERE id = $3",
-							true, task.Priority, task.ID,
-						)
-						if err != nil {
-							// log.Printf("TaskMonitor: Error updating overdue status for task %s: %v", task.ID, err)
-							logger.Error(fmt.Sprintf("TaskMonitor: Error updating overdue status for task %s", task.ID), err)
-							continue
-						}
-						logger.Info("TaskMonitor", "dueDate.Before(time.Now())", dueDate.Before(time.Now()))
-					}
-				}
-
-			}
-			// default:
-			// 	logger.Info("TaskMonitor........inside default")
-			// 	continue
+		case <-ctx.Done():
+			timer.Stop()
+			<-done
+			m.logger.Info("TaskMonitor stopped")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// needsCheck reports whether a task has a due date the monitor must act on.
+func needsCheck(task models.Task) bool {
+	if task.DueDate.IsZero() || workflow.Current().IsTerminal(task.Status) {
+		return false
+	}
+	// Overdue tasks only matter while they may still have to continue a series
+	return !task.IsOverdue || task.SeriesID != nil
+}
+
+// nextCheck returns when the monitor must look at a task again: when it falls
+// due, or when the priority policy may give it another priority. It is zero
+// when neither will happen.
+func nextCheck(task models.Task, now time.Time) time.Time {
+	next := priority.Current().NextChange(task, now)
+	if needsCheck(task) && (next.IsZero() || task.DueDate.Before(next)) {
+		next = task.DueDate.Time
+	}
+	return next
+}
+
+// taskChanged keeps the scheduler in step with the writes to tasks.
+func (m *TaskMonitor) taskChanged(change database.TaskChange) {
+	if change.Kind == database.TaskDeleted {
+		m.scheduler.Cancel(change.Task.ID)
+		return
+	}
+	if change.Kind == database.TaskOverdue {
+		// checkTask flagged it and goes on to continue its series
+		return
+	}
+	if at := nextCheck(change.Task, time.Now()); !at.IsZero() {
+		m.scheduler.Schedule(change.Task.ID, at)
+	}
+}
+
+// sweep stores the priorities the policy moved on from and schedules every
+// task, so none is missed when a change notification was, e.g. after a
+// restart. It continues the series whose latest occurrence finished without
+// starting the next one.
+func (m *TaskMonitor) sweep(ctx context.Context) error {
+	changed, err := database.RefreshPriorities(ctx)
+	if err != nil {
+		return err
+	}
+	if changed > 0 {
+		m.logger.Info("TaskMonitor: refreshed priorities", "tasks", changed)
+	}
+
+	var recurring []models.Task
+	now := time.Now()
+	query := models.TaskQuery{Sort: "due_date", Order: models.SortAsc}
+	err = database.StreamTasks(ctx, m.logger, query, func(task models.Task) error {
+		at := nextCheck(task, now)
+		if task.IsOverdue {
+			// Overdue occurrences are left to AdvanceRecurringTasks below
+			at = priority.Current().NextChange(task, now)
+		}
+		if !at.IsZero() {
+			m.scheduler.Schedule(task.ID, at)
+		}
+		if task.SeriesID != nil {
+			recurring = append(recurring, task)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	database.AdvanceRecurringTasks(m.logger, recurring)
+	return nil
+}
 
-	logger.Info("TaskMonitor.......exiting ticker")
-	defer ticker.Stop()
+// checkTask is the scheduler's job: it runs when a task may have become due
+// or its priority may have changed.
+func (m *TaskMonitor) checkTask(ctx context.Context, taskID string) error {
+	task, err := database.GetTaskByID(taskID)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		return nil
+	}
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("TaskMonitor: Error fetching task %s", taskID), err)
+		return err
+	}
+	if _, err := database.RefreshTaskPriority(task.ID); err != nil {
+		m.logger.Error(fmt.Sprintf("TaskMonitor: Error refreshing the priority of task %s", task.ID), err)
+		return err
+	}
+	// The due date may have moved since the task was scheduled, or only its
+	// priority was up
+	if !needsCheck(*task) || task.DueDate.After(time.Now()) {
+		if at := nextCheck(*task, time.Now()); !at.IsZero() {
+			m.scheduler.Schedule(task.ID, at)
+		}
+		return nil
+	}
 
+	if !task.IsOverdue {
+		if err := database.MarkTaskOverdue(task.ID); err != nil {
+			m.logger.Error(fmt.Sprintf("TaskMonitor: Error updating overdue status for task %s", task.ID), err)
+			return err
+		}
+		m.logger.Info("TaskMonitor: task is overdue", "task", task.ID)
+	}
+
+	// Bring up the next occurrence of a recurring task that is due
+	if task.SeriesID != nil {
+		if _, err := database.SpawnNextOccurrence(*task); err != nil {
+			m.logger.Error(fmt.Sprintf("TaskMonitor: Error creating the next occurrence of task %s", task.ID), err)
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,108 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	const doc = `{"title":"Write docs","parent_id":"p1","labels":["home","work"],"a/b":1,"m~n":2}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string // Empty when the patch must fail
+		err   error
+	}{
+		{"replace", `[{"op":"replace","path":"/title","value":"Edit docs"}]`,
+			`{"title":"Edit docs","parent_id":"p1","labels":["home","work"],"a/b":1,"m~n":2}`, nil},
+		{"replace with null", `[{"op":"replace","path":"/parent_id","value":null}]`,
+			`{"title":"Write docs","parent_id":null,"labels":["home","work"],"a/b":1,"m~n":2}`, nil},
+		{"add null", `[{"op":"add","path":"/due_date","value":null}]`,
+			`{"title":"Write docs","parent_id":"p1","labels":["home","work"],"a/b":1,"m~n":2,"due_date":null}`, nil},
+		{"escaped slash", `[{"op":"replace","path":"/a~1b","value":3}]`,
+			`{"title":"Write docs","parent_id":"p1","labels":["home","work"],"a/b":3,"m~n":2}`, nil},
+		{"escaped tilde", `[{"op":"remove","path":"/m~0n"}]`,
+			`{"title":"Write docs","parent_id":"p1","labels":["home","work"],"a/b":1}`, nil},
+		{"append to an array", `[{"op":"add","path":"/labels/-","value":"urgent"}]`,
+			`{"title":"Write docs","parent_id":"p1","labels":["home","work","urgent"],"a/b":1,"m~n":2}`, nil},
+		{"insert into an array", `[{"op":"add","path":"/labels/1","value":"urgent"}]`,
+			`{"title":"Write docs","parent_id":"p1","labels":["home","urgent","work"],"a/b":1,"m~n":2}`, nil},
+		{"remove from an array", `[{"op":"remove","path":"/labels/0"}]`,
+			`{"title":"Write docs","parent_id":"p1","labels":["work"],"a/b":1,"m~n":2}`, nil},
+		{"move", `[{"op":"move","from":"/parent_id","path":"/owner"}]`,
+			`{"title":"Write docs","owner":"p1","labels":["home","work"],"a/b":1,"m~n":2}`, nil},
+		{"copy", `[{"op":"copy","from":"/labels/1","path":"/labels/0"}]`,
+			`{"title":"Write docs","parent_id":"p1","labels":["work","home","work"],"a/b":1,"m~n":2}`, nil},
+		{"passing test", `[{"op":"test","path":"/a~1b","value":1.0},{"op":"remove","path":"/labels"}]`,
+			`{"title":"Write docs","parent_id":"p1","a/b":1,"m~n":2}`, nil},
+		{"test for null", `[{"op":"replace","path":"/parent_id","value":null},{"op":"test","path":"/parent_id","value":null}]`,
+			`{"title":"Write docs","parent_id":null,"labels":["home","work"],"a/b":1,"m~n":2}`, nil},
+		{"failing test", `[{"op":"remove","path":"/labels"},{"op":"test","path":"/title","value":"Other"}]`, "", ErrTestFailed},
+		{"remove a missing member", `[{"op":"remove","path":"/nope"}]`, "", ErrInvalidPatch},
+		{"replace a missing member", `[{"op":"replace","path":"/nope","value":1}]`, "", ErrInvalidPatch},
+		{"array index out of range", `[{"op":"add","path":"/labels/3","value":"x"}]`, "", ErrInvalidPatch},
+		{"move into itself", `[{"op":"move","from":"/labels","path":"/labels/0"}]`, "", ErrInvalidPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := Decode([]byte(doc))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			ops, err := ParseJSONPatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("ParseJSONPatch: %v", err)
+			}
+			patched, err := ApplyJSONPatch(target, ops)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("ApplyJSONPatch = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyJSONPatch: %v", err)
+			}
+			want, _ := Decode([]byte(tt.want))
+			if !equal(patched, want) {
+				got, _ := json.Marshal(patched)
+				t.Fatalf("patched = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		valid bool
+	}{
+		{"null value", `[{"op":"replace","path":"/parent_id","value":null}]`, true},
+		{"missing value", `[{"op":"replace","path":"/parent_id"}]`, false},
+		{"remove needs no value", `[{"op":"remove","path":"/parent_id"}]`, true},
+		{"unknown op", `[{"op":"merge","path":"/title","value":"x"}]`, false},
+		{"relative path", `[{"op":"remove","path":"title"}]`, false},
+		{"relative from", `[{"op":"copy","from":"title","path":"/name"}]`, false},
+		{"not an array", `{"op":"remove","path":"/title"}`, false},
+	}
+	for _, tt := range tests {
+		_, err := ParseJSONPatch([]byte(tt.patch))
+		if tt.valid && err != nil {
+			t.Errorf("%s: ParseJSONPatch = %v, want no error", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: ParseJSONPatch = %v, want %v", tt.name, err, ErrInvalidPatch)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	target, _ := Decode([]byte(`{"title":"Write docs","parent_id":"p1","labels":["home"]}`))
+	patch, _ := Decode([]byte(`{"parent_id":null,"labels":["work"],"description":"Soon"}`))
+	want, _ := Decode([]byte(`{"title":"Write docs","labels":["work"],"description":"Soon"}`))
+	if got := MergePatch(target, patch); !equal(got, want) {
+		t.Fatalf("MergePatch = %v, want %v", got, want)
+	}
+}
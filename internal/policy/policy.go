@@ -0,0 +1,127 @@
+// Package policy decides what an authenticated caller may do with tasks. The
+// api handlers consult it before every operation.
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+var (
+	// ErrForbidden is wrapped by denials the caller should be told about (403).
+	ErrForbidden = errors.New("forbidden")
+	// ErrHidden is returned when the caller may not even see the task; the
+	// API answers as if it did not exist (404).
+	ErrHidden = errors.New("task not visible")
+)
+
+// Action is something a caller does with a task.
+type Action string
+
+const (
+	Read   Action = "read"   // Fetch a task and its subtasks, series and dependencies
+	Create Action = "create" // Create a task, in the task's project
+	Update Action = "update" // Edit, transition or change the dependencies of a task
+	Delete Action = "delete" // Delete a task
+	Export Action = "export" // Export tasks
+	Grant  Action = "grant"  // Grant or revoke roles on a task
+)
+
+// Check decides whether identity may perform action on task. Create checks
+// the task about to be created; Export ignores task. A nil identity means an
+// unauthenticated route and is always allowed.
+//
+//   - Read needs any role on the task (see models.Identity.RoleOn).
+//   - Create needs editor in the task's project, or globally.
+//   - Update needs editor on the task.
+//   - Delete needs editor on a task the caller owns, or admin on the task.
+//   - Export needs the editor role globally.
+//   - Grant needs admin on the task.
+func Check(identity *models.Identity, action Action, task *models.Task) error {
+	if identity == nil || identity.IsAdmin {
+		return nil
+	}
+	if action == Export {
+		if !identity.Role.AtLeast(models.RoleEditor) {
+			return deny("exporting tasks needs the editor role; you are %s", describe(identity.Role))
+		}
+		return nil
+	}
+	if action == Create {
+		if role := identity.RoleInProject(task.Project); !role.AtLeast(models.RoleEditor) {
+			if task.Project != "" {
+				return deny("creating tasks in project %q needs the editor role; you are %s", task.Project, describe(role))
+			}
+			return deny("creating tasks needs the editor role; you are %s", describe(role))
+		}
+		return nil
+	}
+
+	role := identity.RoleOn(*task)
+	if !role.AtLeast(models.RoleViewer) {
+		return ErrHidden
+	}
+	switch action {
+	case Read:
+		return nil
+	case Update:
+		if !role.AtLeast(models.RoleEditor) {
+			return deny("changing task %s needs the editor role; you are %s on it", task.ID, describe(role))
+		}
+		return nil
+	case Delete:
+		if role.AtLeast(models.RoleAdmin) || (identity.Owns(*task) && role.AtLeast(models.RoleEditor)) {
+			return nil
+		}
+		if identity.Owns(*task) {
+			return deny("deleting task %s needs the editor role; you are %s", task.ID, describe(role))
+		}
+		return deny("only the owner of task %s or an admin can delete it", task.ID)
+	case Grant:
+		if !role.AtLeast(models.RoleAdmin) {
+			return deny("granting roles on task %s needs the admin role on it; you are %s", task.ID, describe(role))
+		}
+		return nil
+	}
+	return deny("unknown action %q", action)
+}
+
+// CheckProjectGrant decides whether identity may grant or revoke roles on a
+// project; "" stands for global grants, which only admins manage.
+func CheckProjectGrant(identity *models.Identity, project string) error {
+	if identity == nil || identity.IsAdmin {
+		return nil
+	}
+	if project == "" {
+		return deny("only admins can manage global roles")
+	}
+	if role := identity.RoleInProject(project); !role.AtLeast(models.RoleAdmin) {
+		return deny("granting roles on project %q needs the admin role in it; you are %s", project, describe(role))
+	}
+	return nil
+}
+
+// CheckAdmin decides whether identity may see how the servers are running,
+// which only admins may.
+func CheckAdmin(identity *models.Identity) error {
+	if identity == nil || identity.IsAdmin {
+		return nil
+	}
+	return deny("only admins can see the server status")
+}
+
+func deny(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrForbidden, fmt.Sprintf(format, args...))
+}
+
+func describe(role models.Role) string {
+	switch role {
+	case "":
+		return "without a role"
+	case models.RoleEditor, models.RoleAdmin:
+		return "an " + string(role)
+	}
+	return "a " + string(role)
+}
@@ -0,0 +1,348 @@
+// Package priority works out the priority of tasks from a configurable policy:
+// an ordered list of rules matching on labels, status, due date and age, each
+// of which sets the priority or escalates it.
+package priority
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
+)
+
+// Config is the JSON form of a policy, as read from PRIORITY_POLICY_FILE:
+//
+//	{
+//	  "respect_user_priority": true,
+//	  "default": "Low",
+//	  "rules": [
+//	    {"name": "due-soon", "when": {"due_within": "3d"}, "set": "High"},
+//	    {"name": "due-this-week", "when": {"due_within": "6d"}, "set": "Medium"},
+//	    {"name": "urgent", "when": {"labels": ["urgent"], "status": ["blocked"]}, "escalate": 1}
+//	  ]
+//	}
+//
+// A task starts from the default priority, or from the one a client chose for
+// it. The first matching "set" rule replaces it, then every matching
+// "escalate" rule raises it by that many steps, up to High. When
+// respect_user_priority is on (the default), tasks whose priority a client
+// chose are left alone; tasks in a terminal status always keep theirs.
+type Config struct {
+	RespectUserPriority *bool           `json:"respect_user_priority"`
+	Default             models.Priority `json:"default"`
+	Rules               []Rule          `json:"rules"`
+}
+
+// Rule changes the priority of the tasks meeting all of its conditions.
+// Exactly one of Set and Escalate is given.
+type Rule struct {
+	Name     string           `json:"name"`
+	When     Conditions       `json:"when"`
+	Set      *models.Priority `json:"set,omitempty"`
+	Escalate int              `json:"escalate,omitempty"`
+}
+
+// Conditions select tasks; empty conditions match every task.
+type Conditions struct {
+	// Labels matches tasks with any of the labels
+	Labels []string `json:"labels,omitempty"`
+	// Status matches tasks in any of the statuses
+	Status []models.Status `json:"status,omitempty"`
+	// DueWithin matches tasks due in less than the duration, overdue ones included
+	DueWithin *Duration `json:"due_within,omitempty"`
+	// OlderThan matches tasks created more than the duration ago
+	OlderThan *Duration `json:"older_than,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "36h" or "3d".
+type Duration struct {
+	time.Duration
+}
+
+// ParseDuration accepts time.ParseDuration strings and a whole number of days with a "d" suffix, e.g. "7d".
+func ParseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("durations are strings such as \"36h\" or \"3d\"")
+	}
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// DefaultConfig is the policy used when PRIORITY_POLICY_FILE is not set: tasks
+// due within 3 days are High, within 6 days Medium, and the others Low.
+func DefaultConfig() Config {
+	return Config{
+		Default: models.Low,
+		Rules: []Rule{
+			{Name: "due-soon", When: Conditions{DueWithin: &Duration{72 * time.Hour}}, Set: priorityOf(models.High)},
+			{Name: "due-this-week", When: Conditions{DueWithin: &Duration{144 * time.Hour}}, Set: priorityOf(models.Medium)},
+		},
+	}
+}
+
+// levels orders the priorities from lowest to highest.
+var levels = []models.Priority{models.Low, models.Medium, models.High}
+
+func level(p models.Priority) int {
+	for i, l := range levels {
+		if l == p {
+			return i
+		}
+	}
+	return -1
+}
+
+func priorityOf(p models.Priority) *models.Priority {
+	return &p
+}
+
+// Policy is a validated priority policy.
+type Policy struct {
+	config  Config
+	respect bool
+}
+
+// New validates cfg and builds a Policy from it. Statuses are checked against
+// the current workflow, so it must be loaded first.
+func New(cfg Config) (*Policy, error) {
+	p := &Policy{config: cfg, respect: cfg.RespectUserPriority == nil || *cfg.RespectUserPriority}
+	if p.config.Default == "" {
+		p.config.Default = models.Low
+	}
+	if level(p.config.Default) < 0 {
+		return nil, fmt.Errorf("invalid default priority %q. Valid values are: %v", p.config.Default, levels)
+	}
+
+	names := map[string]bool{}
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("priority rule %d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("priority rule %q appears twice", rule.Name)
+		}
+		names[rule.Name] = true
+
+		switch {
+		case rule.Set != nil && rule.Escalate != 0:
+			return nil, fmt.Errorf("priority rule %q has both set and escalate", rule.Name)
+		case rule.Set != nil && level(*rule.Set) < 0:
+			return nil, fmt.Errorf("priority rule %q: invalid priority %q. Valid values are: %v", rule.Name, *rule.Set, levels)
+		case rule.Set == nil && rule.Escalate <= 0:
+			return nil, fmt.Errorf("priority rule %q needs set or a positive escalate", rule.Name)
+		}
+		for _, status := range rule.When.Status {
+			if !workflow.Current().IsKnown(status) {
+				return nil, fmt.Errorf("priority rule %q: unknown status %q", rule.Name, status)
+			}
+		}
+		if d := rule.When.DueWithin; d != nil && d.Duration <= 0 {
+			return nil, fmt.Errorf("priority rule %q: due_within must be positive", rule.Name)
+		}
+		if d := rule.When.OlderThan; d != nil && d.Duration <= 0 {
+			return nil, fmt.Errorf("priority rule %q: older_than must be positive", rule.Name)
+		}
+	}
+	return p, nil
+}
+
+// Load reads a policy Config from a JSON file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read priority policy file: %v", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse priority policy file %s: %v", path, err)
+	}
+	return New(cfg)
+}
+
+var (
+	mu      sync.RWMutex
+	current = mustDefault()
+)
+
+func mustDefault() *Policy {
+	p, err := New(DefaultConfig())
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Current returns the active policy.
+func Current() *Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set replaces the active policy.
+func Set(p *Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = p
+}
+
+// InitFromEnv loads PRIORITY_POLICY_FILE when it is set and keeps the default policy otherwise.
+func InitFromEnv() error {
+	path := os.Getenv("PRIORITY_POLICY_FILE")
+	if path == "" {
+		return nil
+	}
+	p, err := Load(path)
+	if err != nil {
+		return err
+	}
+	Set(p)
+	return nil
+}
+
+// Apply sets the priority of task according to the policy.
+func (p *Policy) Apply(task *models.Task) {
+	explanation := p.Explain(*task, time.Now())
+	task.Priority = explanation.Priority
+}
+
+// Explain works out the priority of task at now and tells how.
+func (p *Policy) Explain(task models.Task, now time.Time) models.PriorityExplanation {
+	explanation := models.PriorityExplanation{
+		TaskID:              task.ID,
+		Priority:            task.Priority,
+		Pinned:              task.PriorityPinned,
+		RespectUserPriority: p.respect,
+		Base:                p.config.Default,
+		Rules:               []models.PriorityMatch{},
+	}
+	if task.PriorityPinned && task.Priority != nil {
+		explanation.Base = *task.Priority
+	}
+
+	switch {
+	case workflow.Current().IsTerminal(task.Status):
+		explanation.DecidedBy = "status"
+	case task.PriorityPinned && p.respect:
+		explanation.DecidedBy = "user"
+	}
+	if explanation.DecidedBy != "" {
+		// The rules are still listed, to show what they would have matched
+		for _, rule := range p.config.Rules {
+			explanation.Rules = append(explanation.Rules, models.PriorityMatch{Rule: rule.Name, Action: rule.action(), Matched: rule.matches(task, now)})
+		}
+		return explanation
+	}
+
+	result, decidedBy, set := explanation.Base, "default", false
+	if task.PriorityPinned {
+		decidedBy = "user"
+	}
+	for _, rule := range p.config.Rules {
+		match := models.PriorityMatch{Rule: rule.Name, Action: rule.action(), Matched: rule.matches(task, now)}
+		if match.Matched {
+			switch {
+			case rule.Set != nil && !set:
+				result, decidedBy, set = *rule.Set, rule.Name, true
+				match.Applied = true
+			case rule.Set == nil:
+				if raised := levels[min(level(result)+rule.Escalate, len(levels)-1)]; raised != result {
+					result, decidedBy = raised, rule.Name
+					match.Applied = true
+				}
+			}
+			if match.Applied {
+				match.Priority = priorityOf(result)
+			}
+		}
+		explanation.Rules = append(explanation.Rules, match)
+	}
+	explanation.Priority, explanation.DecidedBy = priorityOf(result), decidedBy
+	return explanation
+}
+
+// NextChange returns the first moment from now on at which a time condition
+// of a rule turns for task, so its priority may change without any write to
+// it. The conditions are strict, so they turn just after that moment.
+// It is zero when the priority stays as it is until the task changes.
+func (p *Policy) NextChange(task models.Task, now time.Time) time.Time {
+	var next time.Time
+	consider := func(at time.Time) {
+		if !at.Before(now) && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	if workflow.Current().IsTerminal(task.Status) || (task.PriorityPinned && p.respect) {
+		return next
+	}
+	for _, rule := range p.config.Rules {
+		if d := rule.When.DueWithin; d != nil && !task.DueDate.IsZero() {
+			consider(task.DueDate.Add(-d.Duration))
+		}
+		if d := rule.When.OlderThan; d != nil && !task.CreatedAt.IsZero() {
+			consider(task.CreatedAt.Add(d.Duration))
+		}
+	}
+	return next
+}
+
+func (r Rule) action() string {
+	if r.Set != nil {
+		return "set " + string(*r.Set)
+	}
+	return fmt.Sprintf("escalate %d", r.Escalate)
+}
+
+func (r Rule) matches(task models.Task, now time.Time) bool {
+	when := r.When
+	if len(when.Labels) > 0 && !hasAny(task.Labels, when.Labels) {
+		return false
+	}
+	if len(when.Status) > 0 && !hasAny([]models.Status{task.Status}, when.Status) {
+		return false
+	}
+	if when.DueWithin != nil && (task.DueDate.IsZero() || task.DueDate.Sub(now) >= when.DueWithin.Duration) {
+		return false
+	}
+	if when.OlderThan != nil && (task.CreatedAt.IsZero() || now.Sub(task.CreatedAt.Time) <= when.OlderThan.Duration) {
+		return false
+	}
+	return true
+}
+
+func hasAny[T comparable](values, wanted []T) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
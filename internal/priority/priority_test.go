@@ -0,0 +1,197 @@
+package priority
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+func TestNextChange(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	policy, err := New(Config{Rules: []Rule{
+		{Name: "due-soon", When: Conditions{DueWithin: &Duration{72 * time.Hour}}, Set: priorityOf(models.High)},
+		{Name: "due-this-week", When: Conditions{DueWithin: &Duration{144 * time.Hour}}, Set: priorityOf(models.Medium)},
+		{Name: "stale", When: Conditions{OlderThan: &Duration{30 * 24 * time.Hour}}, Escalate: 1},
+	}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	at := func(d time.Duration) models.Timestamp { return models.Timestamp{Time: now.Add(d)} }
+
+	tests := []struct {
+		name string
+		task models.Task
+		want time.Time
+	}{
+		{"next due rule", models.Task{Status: models.StatusTodo, DueDate: at(10 * 24 * time.Hour), CreatedAt: at(0)},
+			now.Add(4 * 24 * time.Hour)},
+		{"second due rule", models.Task{Status: models.StatusTodo, DueDate: at(100 * time.Hour), CreatedAt: at(0)},
+			now.Add(28 * time.Hour)},
+		{"age before due date", models.Task{Status: models.StatusTodo, DueDate: at(60 * 24 * time.Hour), CreatedAt: at(-29 * 24 * time.Hour)},
+			now.Add(24 * time.Hour)},
+		{"overdue and old", models.Task{Status: models.StatusTodo, DueDate: at(-time.Hour), CreatedAt: at(-60 * 24 * time.Hour)},
+			time.Time{}},
+		{"pinned", models.Task{Status: models.StatusTodo, DueDate: at(10 * 24 * time.Hour), PriorityPinned: true}, time.Time{}},
+		{"done", models.Task{Status: models.StatusDone, DueDate: at(10 * 24 * time.Hour)}, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := policy.NextChange(tt.task, now); !got.Equal(tt.want) {
+			t.Errorf("%s: NextChange = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// appliedRules lists the rules that changed the priority, in order.
+func appliedRules(explanation models.PriorityExplanation) []string {
+	applied := []string{}
+	for _, match := range explanation.Rules {
+		if match.Applied {
+			applied = append(applied, match.Rule)
+		}
+	}
+	return applied
+}
+
+func TestExplain(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	rules := []Rule{
+		{Name: "due-soon", When: Conditions{DueWithin: &Duration{72 * time.Hour}}, Set: priorityOf(models.High)},
+		{Name: "due-this-week", When: Conditions{DueWithin: &Duration{144 * time.Hour}}, Set: priorityOf(models.Medium)},
+		{Name: "urgent", When: Conditions{Labels: []string{"urgent"}}, Escalate: 1},
+		{Name: "blocked", When: Conditions{Status: []models.Status{models.StatusBlocked}}, Escalate: 2},
+		{Name: "stale", When: Conditions{OlderThan: &Duration{30 * 24 * time.Hour}}, Escalate: 1},
+	}
+	respectful, err := New(Config{Rules: rules})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	respectOff := false
+	overriding, err := New(Config{RespectUserPriority: &respectOff, Rules: rules})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	mediumDefault, err := New(Config{Default: models.Medium, Rules: rules})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	at := func(d time.Duration) models.Timestamp { return models.Timestamp{Time: now.Add(d)} }
+	task := func(status models.Status, due time.Duration, labels ...string) models.Task {
+		task := models.Task{ID: "t1", Status: status, Labels: labels, CreatedAt: at(0)}
+		if due != 0 {
+			task.DueDate = at(due)
+		}
+		return task
+	}
+	pinned := func(task models.Task, p models.Priority) models.Task {
+		task.Priority, task.PriorityPinned = &p, true
+		return task
+	}
+	stale := task(models.StatusTodo, 0, "urgent")
+	stale.CreatedAt = at(-40 * 24 * time.Hour)
+	done := task(models.StatusDone, 48*time.Hour)
+	done.Priority = priorityOf(models.Medium)
+
+	tests := []struct {
+		name      string
+		policy    *Policy
+		task      models.Task
+		want      models.Priority
+		decidedBy string
+		applied   []string
+	}{
+		{"default priority", respectful, task(models.StatusTodo, 10*24*time.Hour), models.Low, "default", []string{}},
+		{"custom default priority", mediumDefault, task(models.StatusTodo, 0), models.Medium, "default", []string{}},
+		{"first set rule wins", respectful, task(models.StatusTodo, 48*time.Hour), models.High, "due-soon", []string{"due-soon"}},
+		{"later set rule", respectful, task(models.StatusTodo, 100*time.Hour), models.Medium, "due-this-week", []string{"due-this-week"}},
+		{"escalate after set", respectful, task(models.StatusTodo, 100*time.Hour, "urgent"), models.High, "urgent", []string{"due-this-week", "urgent"}},
+		{"escalate capped at High", respectful, task(models.StatusTodo, 48*time.Hour, "urgent"), models.High, "due-soon", []string{"due-soon"}},
+		{"escalations add up", respectful, stale, models.High, "stale", []string{"urgent", "stale"}},
+		{"escalate by two", respectful, task(models.StatusBlocked, 0), models.High, "blocked", []string{"blocked"}},
+		{"pinned and respected", respectful, pinned(task(models.StatusTodo, 48*time.Hour), models.Low), models.Low, "user", []string{}},
+		{"pinned without a match", overriding, pinned(task(models.StatusTodo, 0), models.Medium), models.Medium, "user", []string{}},
+		{"pinned and escalated", overriding, pinned(task(models.StatusTodo, 0, "urgent"), models.Medium), models.High, "urgent", []string{"urgent"}},
+		{"pinned and set", overriding, pinned(task(models.StatusTodo, 100*time.Hour), models.High), models.Medium, "due-this-week", []string{"due-this-week"}},
+		{"terminal status", overriding, done, models.Medium, "status", []string{}},
+	}
+	for _, tt := range tests {
+		explanation := tt.policy.Explain(tt.task, now)
+		if explanation.Priority == nil || *explanation.Priority != tt.want || explanation.DecidedBy != tt.decidedBy {
+			t.Errorf("%s: priority %v decided by %q, want %s decided by %q", tt.name, explanation.Priority, explanation.DecidedBy, tt.want, tt.decidedBy)
+		}
+		if applied := appliedRules(explanation); strings.Join(applied, ",") != strings.Join(tt.applied, ",") {
+			t.Errorf("%s: applied rules %v, want %v", tt.name, applied, tt.applied)
+		}
+		if len(explanation.Rules) != len(rules) {
+			t.Errorf("%s: %d rules explained, want all %d", tt.name, len(explanation.Rules), len(rules))
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	policy := mustDefault()
+	due := models.Timestamp{Time: time.Now().Add(time.Hour)}
+
+	task := models.Task{Status: models.StatusTodo, DueDate: due, Priority: priorityOf(models.Low)}
+	policy.Apply(&task)
+	if task.Priority == nil || *task.Priority != models.High {
+		t.Errorf("priority of a task due within the hour = %v, want %s", task.Priority, models.High)
+	}
+
+	task = models.Task{Status: models.StatusTodo, DueDate: due, Priority: priorityOf(models.Low), PriorityPinned: true}
+	policy.Apply(&task)
+	if task.Priority == nil || *task.Priority != models.Low {
+		t.Errorf("pinned priority = %v, want %s", task.Priority, models.Low)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	due := &Duration{time.Hour}
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"invalid default", Config{Default: "Urgent"}},
+		{"unnamed rule", Config{Rules: []Rule{{Set: priorityOf(models.High)}}}},
+		{"duplicate rule", Config{Rules: []Rule{{Name: "a", Escalate: 1}, {Name: "a", Escalate: 1}}}},
+		{"set and escalate", Config{Rules: []Rule{{Name: "a", Set: priorityOf(models.High), Escalate: 1}}}},
+		{"neither set nor escalate", Config{Rules: []Rule{{Name: "a"}}}},
+		{"negative escalate", Config{Rules: []Rule{{Name: "a", Escalate: -1}}}},
+		{"invalid set", Config{Rules: []Rule{{Name: "a", Set: priorityOf("Urgent")}}}},
+		{"unknown status", Config{Rules: []Rule{{Name: "a", When: Conditions{Status: []models.Status{"someday"}}, Escalate: 1}}}},
+		{"zero due_within", Config{Rules: []Rule{{Name: "a", When: Conditions{DueWithin: &Duration{}}, Escalate: 1}}}},
+		{"negative older_than", Config{Rules: []Rule{{Name: "a", When: Conditions{DueWithin: due, OlderThan: &Duration{-time.Hour}}, Escalate: 1}}}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.cfg); err == nil {
+			t.Errorf("%s: New accepted %+v", tt.name, tt.cfg)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	data := `{"respect_user_priority": false, "default": "Medium",
+		"rules": [{"name": "due-soon", "when": {"due_within": "3d", "labels": ["home"]}, "set": "High"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if policy.respect || policy.config.Default != models.Medium || policy.config.Rules[0].When.DueWithin.Duration != 72*time.Hour {
+		t.Errorf("loaded policy = %+v", policy)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"rules": [{"name": "a", "when": {"due_within": "soon"}, "set": "High"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted an invalid duration")
+	}
+}
@@ -0,0 +1,395 @@
+// Package recurrence implements the subset of RFC 5545 recurrence rules used
+// for repeating tasks: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, BYDAY,
+// BYMONTHDAY, COUNT and UNTIL.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRule is returned for rules that cannot be parsed or use parts that are not supported.
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Frequency is the FREQ part of a rule.
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds the search for the next occurrence, so rules that can
+// never match (e.g. BYMONTHDAY=31 with BYDAY=MO on a 2-month interval that
+// only hits short months) end instead of looping forever.
+const maxPeriods = 5000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// WeekdayNum is one BYDAY entry. Ordinal selects the nth such weekday of the
+// month or year (negative counts from the end); 0 means every one.
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+func (w WeekdayNum) String() string {
+	day := strings.ToUpper(w.Weekday.String()[:2])
+	if w.Ordinal == 0 {
+		return day
+	}
+	return strconv.Itoa(w.Ordinal) + day
+}
+
+// Rule is a parsed RRULE.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	Count      int
+	Until      time.Time
+}
+
+// Parse reads an RRULE value such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE".
+// A leading "RRULE:" is accepted. Errors wrap ErrInvalidRule.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	if len(value) >= 6 && strings.EqualFold(value[:6], "RRULE:") {
+		value = value[6:]
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		name, val = strings.ToUpper(strings.TrimSpace(name)), strings.ToUpper(strings.TrimSpace(val))
+		if !ok || name == "" || val == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s appears more than once", ErrInvalidRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(val)
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported FREQ %s. Valid values are: [DAILY WEEKLY MONTHLY YEARLY]", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = positive(name, val)
+		case "COUNT":
+			rule.Count, err = positive(name, val)
+		case "UNTIL":
+			rule.Until, err = parseUntil(val)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseByMonthDay(val)
+		default:
+			err = fmt.Errorf("unsupported rule part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot be combined", ErrInvalidRule)
+	}
+	if rule.Freq == Daily || rule.Freq == Weekly {
+		for _, day := range rule.ByDay {
+			if day.Ordinal != 0 {
+				return nil, fmt.Errorf("%w: BYDAY=%s needs FREQ=MONTHLY or FREQ=YEARLY", ErrInvalidRule, day)
+			}
+		}
+	}
+	return rule, nil
+}
+
+func positive(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %s", name, value)
+	}
+	return n, nil
+}
+
+// parseUntil accepts a UTC date-time (20301231T235959Z), a floating date-time
+// (taken as UTC) or a date, which includes the whole day.
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("UNTIL %s is not a date (YYYYMMDD) or UTC date-time (YYYYMMDDTHHMMSSZ)", value)
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY value %q", item)
+		}
+		weekday, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY value %q", item)
+		}
+		day := WeekdayNum{Weekday: weekday}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid BYDAY value %q", item)
+			}
+			day.Ordinal = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var days []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -31 || n > 31 {
+			return nil, fmt.Errorf("invalid BYMONTHDAY value %q", item)
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
+
+// String returns the rule in canonical RRULE form.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence after prev, where prev is occurrence
+// number n (1-based) of the series. The time of day is taken from prev. It
+// reports false once the series is over: COUNT is reached, the next date lies
+// past UNTIL, or no date matches the rule at all.
+func (r *Rule) Next(prev time.Time, n int) (time.Time, bool) {
+	if r.Count > 0 && n >= r.Count {
+		return time.Time{}, false
+	}
+
+	start := r.periodStart(prev)
+	for i := 0; i < maxPeriods; i++ {
+		for _, day := range r.expand(r.advance(start, i*r.Interval), prev) {
+			candidate := time.Date(day.Year(), day.Month(), day.Day(), prev.Hour(), prev.Minute(), prev.Second(), prev.Nanosecond(), prev.Location())
+			if !candidate.After(prev) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return time.Time{}, false
+			}
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// periodStart is the first day of the day, week (starting Monday), month or
+// year that t falls in.
+func (r *Rule) periodStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch r.Freq {
+	case Weekly:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case Monthly:
+		return day.AddDate(0, 0, 1-day.Day())
+	case Yearly:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+	return day
+}
+
+func (r *Rule) advance(start time.Time, steps int) time.Time {
+	switch r.Freq {
+	case Weekly:
+		return start.AddDate(0, 0, 7*steps)
+	case Monthly:
+		return start.AddDate(0, steps, 0)
+	case Yearly:
+		return start.AddDate(steps, 0, 0)
+	}
+	return start.AddDate(0, 0, steps)
+}
+
+// expand lists the days of one period that match the rule, in order. Parts
+// the rule leaves out default to the matching part of the series start.
+func (r *Rule) expand(period, start time.Time) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		days = []time.Time{period}
+
+	case Weekly:
+		byDay := r.ByDay
+		if len(byDay) == 0 {
+			byDay = []WeekdayNum{{Weekday: start.Weekday()}}
+		}
+		for _, day := range byDay {
+			days = append(days, period.AddDate(0, 0, (int(day.Weekday)+6)%7))
+		}
+
+	case Monthly:
+		switch {
+		case len(r.ByMonthDay) > 0:
+			days = monthDays(period, r.ByMonthDay)
+		case len(r.ByDay) > 0:
+			days = weekdaysIn(period, period.AddDate(0, 1, 0), r.ByDay)
+		default:
+			days = monthDays(period, []int{start.Day()})
+		}
+
+	case Yearly:
+		next := period.AddDate(1, 0, 0)
+		switch {
+		case len(r.ByMonthDay) > 0:
+			for month := period; month.Before(next); month = month.AddDate(0, 1, 0) {
+				days = append(days, monthDays(month, r.ByMonthDay)...)
+			}
+		case len(r.ByDay) > 0:
+			days = weekdaysIn(period, next, r.ByDay)
+		default:
+			days = monthDays(period.AddDate(0, int(start.Month())-1, 0), []int{start.Day()})
+		}
+	}
+
+	// BYDAY and BYMONTHDAY narrow down whatever the other parts produced
+	var matched []time.Time
+	for _, day := range days {
+		if r.matchesMonthDay(day) && r.matchesWeekday(day, period) {
+			matched = append(matched, day)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Before(matched[j]) })
+	return matched
+}
+
+// monthDays resolves month days (negative counts from the end) within the
+// month starting at first, skipping days the month does not have.
+func monthDays(first time.Time, monthDays []int) []time.Time {
+	length := first.AddDate(0, 1, -1).Day()
+	var days []time.Time
+	for _, md := range monthDays {
+		if md < 0 {
+			md = length + md + 1
+		}
+		if md >= 1 && md <= length {
+			days = append(days, first.AddDate(0, 0, md-1))
+		}
+	}
+	return days
+}
+
+// weekdaysIn lists the days in [from, to) named by byDay, honouring ordinals.
+func weekdaysIn(from, to time.Time, byDay []WeekdayNum) []time.Time {
+	var days []time.Time
+	for _, want := range byDay {
+		var all []time.Time
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == want.Weekday {
+				all = append(all, day)
+			}
+		}
+		switch {
+		case want.Ordinal == 0:
+			days = append(days, all...)
+		case want.Ordinal > 0 && want.Ordinal <= len(all):
+			days = append(days, all[want.Ordinal-1])
+		case want.Ordinal < 0 && -want.Ordinal <= len(all):
+			days = append(days, all[len(all)+want.Ordinal])
+		}
+	}
+	return days
+}
+
+func (r *Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := day.AddDate(0, 1, -day.Day()).Day()
+	for _, md := range r.ByMonthDay {
+		if md == day.Day() || length+md+1 == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday checks BYDAY; ordinals are counted within the month for
+// MONTHLY rules and within the year for YEARLY ones.
+func (r *Rule) matchesWeekday(day, period time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, want := range r.ByDay {
+		if want.Weekday != day.Weekday() {
+			continue
+		}
+		if want.Ordinal == 0 {
+			return true
+		}
+		from, to := period, period.AddDate(1, 0, 0)
+		if r.Freq == Monthly {
+			to = period.AddDate(0, 1, 0)
+		}
+		for _, d := range weekdaysIn(from, to, []WeekdayNum{want}) {
+			if d.Equal(day) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string // The rule's canonical form
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:freq=weekly; interval=2; byday=mo,we", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"},
+		{"FREQ=YEARLY;INTERVAL=1;COUNT=5", "FREQ=YEARLY;COUNT=5"},
+		{"FREQ=MONTHLY;BYDAY=-1FR,2TU", "FREQ=MONTHLY;BYDAY=-1FR,2TU"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		// A date UNTIL includes the whole day
+		{"FREQ=DAILY;UNTIL=20261231", "FREQ=DAILY;UNTIL=20261231T235959Z"},
+		{"FREQ=DAILY;UNTIL=20261231T120000Z", "FREQ=DAILY;UNTIL=20261231T120000Z"},
+		{"FREQ=DAILY;UNTIL=20261231T120000", "FREQ=DAILY;UNTIL=20261231T120000Z"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.value)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.value, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=x",
+		"FREQ=DAILY;COUNT=2;UNTIL=20260101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=54MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+	} {
+		if rule, err := Parse(value); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) = %v, %v; want %v", value, rule, err, ErrInvalidRule)
+		}
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		prev time.Time
+		n    int
+		want time.Time // Zero when the series is over
+	}{
+		{"daily", "FREQ=DAILY", date(2026, 1, 31), 1, date(2026, 2, 1)},
+		{"daily interval", "FREQ=DAILY;INTERVAL=3", date(2026, 1, 30), 1, date(2026, 2, 2)},
+		{"weekly on the same weekday", "FREQ=WEEKLY", date(2026, 1, 2), 1, date(2026, 1, 9)},
+		{"weekly BYDAY over the weekend", "FREQ=WEEKLY;BYDAY=MO,WE,FR", date(2026, 1, 2), 1, date(2026, 1, 5)},
+		{"weekly BYDAY within the week", "FREQ=WEEKLY;BYDAY=MO,WE,FR", date(2026, 1, 5), 1, date(2026, 1, 7)},
+		{"weekly BYDAY every other week", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", date(2026, 1, 2), 1, date(2026, 1, 12)},
+		{"monthly", "FREQ=MONTHLY", date(2026, 1, 15), 1, date(2026, 2, 15)},
+		// Months without the day are skipped rather than overflowing into the next
+		{"monthly on the 31st", "FREQ=MONTHLY", date(2026, 1, 31), 1, date(2026, 3, 31)},
+		{"monthly on the 30th over February", "FREQ=MONTHLY;BYMONTHDAY=30", date(2026, 1, 30), 1, date(2026, 3, 30)},
+		{"last day of January", "FREQ=MONTHLY;BYMONTHDAY=-1", date(2026, 1, 31), 1, date(2026, 2, 28)},
+		{"last day of February", "FREQ=MONTHLY;BYMONTHDAY=-1", date(2026, 2, 28), 1, date(2026, 3, 31)},
+		{"last day of a leap February", "FREQ=MONTHLY;BYMONTHDAY=-1", date(2028, 1, 31), 1, date(2028, 2, 29)},
+		{"last Friday", "FREQ=MONTHLY;BYDAY=-1FR", date(2026, 1, 30), 1, date(2026, 2, 27)},
+		{"second Tuesday", "FREQ=MONTHLY;BYDAY=2TU", date(2026, 1, 13), 1, date(2026, 2, 10)},
+		{"Friday the 13th", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", date(2026, 2, 13), 1, date(2026, 3, 13)},
+		{"yearly", "FREQ=YEARLY", date(2026, 7, 4), 1, date(2027, 7, 4)},
+		{"yearly on a leap day", "FREQ=YEARLY", date(2024, 2, 29), 1, date(2028, 2, 29)},
+		{"first Monday of the year", "FREQ=YEARLY;BYDAY=1MO", date(2026, 1, 5), 1, date(2027, 1, 4)},
+		{"within COUNT", "FREQ=DAILY;COUNT=3", date(2026, 1, 1), 2, date(2026, 1, 2)},
+		{"COUNT reached", "FREQ=DAILY;COUNT=3", date(2026, 1, 1), 3, time.Time{}},
+		{"within an UNTIL date", "FREQ=DAILY;UNTIL=20260105", date(2026, 1, 4), 1, date(2026, 1, 5)},
+		{"past an UNTIL date", "FREQ=DAILY;UNTIL=20260105", date(2026, 1, 5), 1, time.Time{}},
+		{"past an UNTIL date-time", "FREQ=DAILY;UNTIL=20260105T080000Z", date(2026, 1, 4), 1, time.Time{}},
+		{"no matching date", "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30", date(2026, 2, 10), 1, time.Time{}},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.rule)
+		if err != nil {
+			t.Fatalf("%s: Parse(%q): %v", tt.name, tt.rule, err)
+		}
+		got, ok := rule.Next(tt.prev, tt.n)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%s, %d) = %s, %v; want %s", tt.name, tt.prev.Format(time.DateOnly), tt.n, got, ok, tt.want)
+		}
+	}
+}
+
+func TestNextKeepsTimeOfDay(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=TU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	prev := time.Date(2026, 3, 3, 23, 45, 0, 0, zone)
+	want := time.Date(2026, 3, 10, 23, 45, 0, 0, zone)
+	if got, ok := rule.Next(prev, 1); !ok || !got.Equal(want) || got.Location() != zone {
+		t.Errorf("Next = %s, %v; want %s", got, ok, want)
+	}
+}
@@ -0,0 +1,200 @@
+// Package scheduler runs jobs at the times set for them.
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Job is run for a key when its time comes. A key whose job returns an error
+// is tried again after a backoff.
+type Job func(ctx context.Context, key string) error
+
+// Scheduler runs a Job for each key at the time it was scheduled for.
+type Scheduler interface {
+	// Schedule asks for the job of key to run at at. A key that is already due
+	// earlier keeps its earlier time, so the job must check whether there is
+	// anything to do yet.
+	Schedule(key string, at time.Time)
+	// Cancel forgets a key.
+	Cancel(key string)
+	// Run runs the jobs as they come due, one at a time, until ctx is done.
+	Run(ctx context.Context) error
+}
+
+// Backoff is how long a failing key waits before it is tried again: Min after
+// the first failure, doubling with each further one up to Max.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Delay returns the wait after the given number of failures in a row.
+func (b Backoff) Delay(failures int) time.Duration {
+	delay := b.Min
+	for i := 1; i < failures && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// Heap is a Scheduler keeping its keys in a min-heap by time. It sleeps until
+// the earliest key is due, or until an earlier one is scheduled.
+type Heap struct {
+	job     Job
+	backoff Backoff
+
+	mu       sync.Mutex
+	entries  entries
+	byKey    map[string]*entry
+	failures map[string]int
+	wake     chan struct{}
+}
+
+// NewHeap returns a Heap that runs job, retrying failed keys after backoff.
+func NewHeap(job Job, backoff Backoff) *Heap {
+	return &Heap{
+		job:      job,
+		backoff:  backoff,
+		byKey:    map[string]*entry{},
+		failures: map[string]int{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (h *Heap) Schedule(key string, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.byKey[key]; ok {
+		if !at.Before(e.at) {
+			return
+		}
+		e.at = at
+		heap.Fix(&h.entries, e.index)
+	} else {
+		e := &entry{key: key, at: at}
+		h.byKey[key] = e
+		heap.Push(&h.entries, e)
+	}
+
+	// Run sleeps until the earliest time; a new earliest one must wake it up
+	if h.entries[0].key == key {
+		select {
+		case h.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *Heap) Cancel(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.byKey[key]; ok {
+		heap.Remove(&h.entries, e.index)
+		delete(h.byKey, key)
+	}
+	delete(h.failures, key)
+}
+
+// Len returns the number of keys waiting to run.
+func (h *Heap) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.entries)
+}
+
+func (h *Heap) Run(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key, wait, due := h.next(time.Now())
+		if due {
+			h.done(key, h.job(ctx, key))
+			continue
+		}
+
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			fire = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-h.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// next takes the earliest key off the heap if it is due. Otherwise it returns
+// how long until it is, or -1 when there are no keys.
+func (h *Heap) next(now time.Time) (key string, wait time.Duration, due bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) == 0 {
+		return "", -1, false
+	}
+	e := h.entries[0]
+	if e.at.After(now) {
+		return "", e.at.Sub(now), false
+	}
+	heap.Pop(&h.entries)
+	delete(h.byKey, e.key)
+	return e.key, 0, true
+}
+
+// done schedules a failed key again after its backoff.
+func (h *Heap) done(key string, err error) {
+	h.mu.Lock()
+	if err == nil {
+		delete(h.failures, key)
+		h.mu.Unlock()
+		return
+	}
+	h.failures[key]++
+	delay := h.backoff.Delay(h.failures[key])
+	h.mu.Unlock()
+	h.Schedule(key, time.Now().Add(delay))
+}
+
+type entry struct {
+	key   string
+	at    time.Time
+	index int
+}
+
+// entries implements heap.Interface, earliest first.
+type entries []*entry
+
+func (e entries) Len() int           { return len(e) }
+func (e entries) Less(i, j int) bool { return e[i].at.Before(e[j].at) }
+
+func (e entries) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+	e[i].index = i
+	e[j].index = j
+}
+
+func (e *entries) Push(x interface{}) {
+	item := x.(*entry)
+	item.index = len(*e)
+	*e = append(*e, item)
+}
+
+func (e *entries) Pop() interface{} {
+	old := *e
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*e = old[:len(old)-1]
+	return item
+}
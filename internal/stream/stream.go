@@ -0,0 +1,251 @@
+// Package stream keeps a bounded log of the changes to tasks and passes them
+// on to the clients following them, e.g. over Server-Sent Events. A client
+// that reconnects names the last event it saw and gets the ones it missed
+// from the log, as long as the log still holds them.
+package stream
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/database"
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+	"github.com/iabdulzahid/golang_task_manager/internal/priority"
+)
+
+// Options configures a Log.
+type Options struct {
+	// Size is how many events the log keeps for clients to catch up on.
+	Size int
+	// Heartbeat is how often an idle stream sends a keep-alive, so proxies do
+	// not close it and clients notice a dead connection.
+	Heartbeat time.Duration
+	// Buffer is how many events a subscriber may fall behind before it is
+	// dropped; it then reconnects and catches up from the log.
+	Buffer int
+}
+
+// DefaultOptions keep 1000 events and send a heartbeat every 15s.
+var DefaultOptions = Options{Size: 1000, Heartbeat: 15 * time.Second, Buffer: 256}
+
+// OptionsFromEnv reads STREAM_LOG_SIZE (a number of events) and
+// STREAM_HEARTBEAT (a duration such as 15s) over the DefaultOptions.
+func OptionsFromEnv() (Options, error) {
+	opts := DefaultOptions
+	if value := os.Getenv("STREAM_LOG_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return opts, fmt.Errorf("STREAM_LOG_SIZE: invalid size %q, use a positive number", value)
+		}
+		opts.Size = size
+	}
+	if value := os.Getenv("STREAM_HEARTBEAT"); value != "" {
+		heartbeat, err := time.ParseDuration(value)
+		if err != nil || heartbeat <= 0 {
+			return opts, fmt.Errorf("STREAM_HEARTBEAT: invalid duration %q, e.g. 15s", value)
+		}
+		opts.Heartbeat = heartbeat
+	}
+	return opts, nil
+}
+
+// Event is a change to a task, numbered in the order the log received it.
+type Event struct {
+	ID       string // Epoch of the log and sequence number, e.g. "m2x8k1qz-42"
+	Kind     database.ChangeKind
+	Task     models.Task
+	Previous *models.Task
+	Time     models.Timestamp
+
+	seq uint64
+}
+
+// For returns the event as sent to a client following the tasks matching
+// filter, and false when the event is none of its business. An update that
+// takes a task out of the filter is sent as a "left" event.
+func (e Event) For(filter models.TaskFilter) (models.TaskEvent, bool) {
+	event := models.TaskEvent{ID: e.ID, Type: string(e.Kind), Task: &e.Task, Time: e.Time}
+	switch {
+	case filter.Matches(e.Task):
+		return event, true
+	case e.Previous != nil && filter.Matches(*e.Previous):
+		event.Type = models.TaskEventLeft
+		return event, true
+	}
+	return event, false
+}
+
+// Log is a ring of the latest events, fed by the database's change
+// notifications. Event IDs start with the epoch of the log, so the IDs of an
+// earlier run of the server are not mistaken for current ones.
+type Log struct {
+	opts  Options
+	epoch string
+
+	mu     sync.Mutex
+	ring   []Event
+	start  int // Index of the oldest event in ring
+	seq    uint64
+	subs   map[*Subscription]bool
+	closed bool
+}
+
+// NewLog returns an empty Log; Start connects it to the database.
+func NewLog(opts Options) *Log {
+	return &Log{
+		opts:  opts,
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:  make([]Event, 0, opts.Size),
+		subs:  map[*Subscription]bool{},
+	}
+}
+
+// Options returns the options the log was created with.
+func (l *Log) Options() Options {
+	return l.opts
+}
+
+// Start records every task change from now on and returns a function that stops it.
+func (l *Log) Start() (stop func()) {
+	return database.ObserveTasks(l.add)
+}
+
+func (l *Log) add(change database.TaskChange) {
+	// Events carry the priority the API would show
+	policy := priority.Current()
+	policy.Apply(&change.Task)
+	if change.Previous != nil {
+		previous := *change.Previous
+		policy.Apply(&previous)
+		change.Previous = &previous
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	event := Event{
+		ID:       l.id(l.seq),
+		Kind:     change.Kind,
+		Task:     change.Task,
+		Previous: change.Previous,
+		Time:     models.Now(),
+		seq:      l.seq,
+	}
+	if len(l.ring) < l.opts.Size {
+		l.ring = append(l.ring, event)
+	} else {
+		l.ring[l.start] = event
+		l.start = (l.start + 1) % len(l.ring)
+	}
+
+	for sub := range l.subs {
+		select {
+		case sub.events <- event:
+		default:
+			// Too far behind: the client catches up from the log when it reconnects
+			l.drop(sub)
+		}
+	}
+}
+
+func (l *Log) id(seq uint64) string {
+	return fmt.Sprintf("%s-%d", l.epoch, seq)
+}
+
+// Subscribe starts following the log. With the ID of the last event a client
+// saw, it also returns the events since then. complete is false when they
+// are no longer all in the log, or the ID is not one of this log's; the
+// client must then fetch the tasks again. head is the ID of the latest event,
+// from which a client that started over can resume.
+func (l *Log) Subscribe(lastEventID string) (sub *Subscription, missed []Event, head string, complete bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sub = &Subscription{log: l, events: make(chan Event, l.opts.Buffer)}
+	if l.closed {
+		close(sub.events)
+	} else {
+		l.subs[sub] = true
+	}
+	head, complete = l.id(l.seq), true
+	if lastEventID == "" {
+		return sub, nil, head, complete
+	}
+
+	epoch, number, _ := strings.Cut(lastEventID, "-")
+	seq, err := strconv.ParseUint(number, 10, 64)
+	if epoch != l.epoch || err != nil || seq > l.seq {
+		return sub, nil, head, false
+	}
+	// The event after the last one seen must still be in the log
+	oldest := l.seq - uint64(len(l.ring)) + 1
+	if seq+1 < oldest {
+		return sub, nil, head, false
+	}
+	for i := range l.ring {
+		if event := l.ring[(l.start+i)%len(l.ring)]; event.seq > seq {
+			missed = append(missed, event)
+		}
+	}
+	return sub, missed, head, complete
+}
+
+// drop ends a subscription; l.mu must be held.
+func (l *Log) drop(sub *Subscription) {
+	if l.subs[sub] {
+		delete(l.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Close ends every subscription and refuses new ones, e.g. when the server shuts down.
+func (l *Log) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	for sub := range l.subs {
+		l.drop(sub)
+	}
+}
+
+// Subscription follows the events of a Log.
+type Subscription struct {
+	log    *Log
+	events chan Event
+}
+
+// Events delivers the events in order. It is closed when the subscription
+// ends: on Close, when the log closes, or when the subscriber fell too far
+// behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+	s.log.drop(s)
+}
+
+var (
+	mu      sync.RWMutex
+	current *Log
+)
+
+// Current returns the Log of the task changes, or nil before Set.
+func Current() *Log {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set makes l the Log reported by Current.
+func Set(l *Log) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = l
+}
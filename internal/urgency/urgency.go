@@ -0,0 +1,207 @@
+// Package urgency scores how pressing a task is, in the style of
+// Taskwarrior's urgency: a sum of terms, each a value between 0 and 1 (or a
+// count) times a configurable weight.
+package urgency
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// Weights are the coefficients of the urgency terms.
+type Weights struct {
+	// Priority is added for a task of that priority
+	Priority map[models.Priority]float64
+	// Due is reached by tasks a week or more overdue; tasks due in two weeks
+	// or more get a fifth of it, and tasks without a due date none
+	Due float64
+	// Age is reached by tasks a year old, growing linearly until then
+	Age float64
+	// Blocking is added for every open task waiting for the task
+	Blocking float64
+	// Blocked is added once while the task waits for an open dependency
+	Blocked float64
+	// Labels is added for each label of the task that has a weight
+	Labels map[string]float64
+}
+
+// DefaultWeights are Taskwarrior's coefficients, with the "next" label
+// standing in for its "next" tag.
+func DefaultWeights() Weights {
+	return Weights{
+		Priority: map[models.Priority]float64{models.High: 6.0, models.Medium: 3.9, models.Low: 1.8},
+		Due:      12.0,
+		Age:      2.0,
+		Blocking: 8.0,
+		Blocked:  -5.0,
+		Labels:   map[string]float64{"next": 15.0},
+	}
+}
+
+// WeightsFromEnv reads URGENCY_WEIGHTS and URGENCY_LABEL_WEIGHTS over the
+// DefaultWeights. Both are comma-separated name=weight lists: the first names
+// High, Medium, Low, due, age, blocking or blocked, e.g.
+// URGENCY_WEIGHTS=due=10,blocked=-3; the second replaces the label weights,
+// e.g. URGENCY_LABEL_WEIGHTS=next=15,someday=-5.
+func WeightsFromEnv() (Weights, error) {
+	weights := DefaultWeights()
+	values, err := parseWeightList(os.Getenv("URGENCY_WEIGHTS"))
+	if err != nil {
+		return weights, fmt.Errorf("URGENCY_WEIGHTS: %v", err)
+	}
+	for name, value := range values {
+		switch strings.ToLower(name) {
+		case "high":
+			weights.Priority[models.High] = value
+		case "medium":
+			weights.Priority[models.Medium] = value
+		case "low":
+			weights.Priority[models.Low] = value
+		case "due":
+			weights.Due = value
+		case "age":
+			weights.Age = value
+		case "blocking":
+			weights.Blocking = value
+		case "blocked":
+			weights.Blocked = value
+		default:
+			return weights, fmt.Errorf("URGENCY_WEIGHTS: unknown term %q. Valid terms are: [High Medium Low due age blocking blocked]", name)
+		}
+	}
+
+	if value := os.Getenv("URGENCY_LABEL_WEIGHTS"); value != "" {
+		if weights.Labels, err = parseWeightList(value); err != nil {
+			return weights, fmt.Errorf("URGENCY_LABEL_WEIGHTS: %v", err)
+		}
+	}
+	return weights, nil
+}
+
+func parseWeightList(value string) (map[string]float64, error) {
+	weights := map[string]float64{}
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected name=weight", item)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(spec), 64)
+		if err != nil || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("invalid weight %q for %s", spec, name)
+		}
+		weights[name] = weight
+	}
+	return weights, nil
+}
+
+var (
+	mu      sync.RWMutex
+	current = DefaultWeights()
+)
+
+// Current returns the active weights.
+func Current() Weights {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set replaces the active weights.
+func Set(w Weights) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = w
+}
+
+// InitFromEnv makes the weights of WeightsFromEnv the active ones.
+func InitFromEnv() error {
+	w, err := WeightsFromEnv()
+	if err != nil {
+		return err
+	}
+	Set(w)
+	return nil
+}
+
+// Dependencies are the urgency inputs that come from the dependency graph.
+type Dependencies struct {
+	Blocking int  // Open tasks waiting for the task
+	Blocked  bool // Whether the task waits for an open task
+}
+
+// Score works out the urgency of task at now.
+func (w Weights) Score(task models.Task, deps Dependencies, now time.Time) models.Urgency {
+	var terms []models.UrgencyTerm
+	add := func(factor string, value, weight float64) {
+		terms = append(terms, models.UrgencyTerm{Factor: factor, Value: round(value), Weight: weight, Score: round(value * weight)})
+	}
+
+	if task.Priority != nil {
+		add("priority", 1, w.Priority[*task.Priority])
+	} else {
+		add("priority", 0, 0)
+	}
+	add("due", dueValue(task.DueDate, now), w.Due)
+	add("age", ageValue(task.CreatedAt, now), w.Age)
+	add("blocking", float64(deps.Blocking), w.Blocking)
+	if deps.Blocked {
+		add("blocked", 1, w.Blocked)
+	} else {
+		add("blocked", 0, w.Blocked)
+	}
+
+	labels := append([]string{}, task.Labels...)
+	sort.Strings(labels)
+	for _, label := range labels {
+		if weight, ok := w.Labels[label]; ok {
+			add("label:"+label, 1, weight)
+		}
+	}
+
+	urgency := models.Urgency{Terms: terms}
+	for _, term := range terms {
+		urgency.Score += term.Score
+	}
+	urgency.Score = round(urgency.Score)
+	return urgency
+}
+
+// dueValue rises linearly from 0.2 for tasks due in 14 days or more to 1 for
+// tasks 7 days overdue or more.
+func dueValue(due models.Timestamp, now time.Time) float64 {
+	if due.IsZero() {
+		return 0
+	}
+	daysOverdue := now.Sub(due.Time).Hours() / 24
+	switch {
+	case daysOverdue >= 7:
+		return 1
+	case daysOverdue >= -14:
+		return (daysOverdue+14)*0.8/21 + 0.2
+	}
+	return 0.2
+}
+
+// ageValue rises linearly from 0 for new tasks to 1 for tasks a year old.
+func ageValue(created models.Timestamp, now time.Time) float64 {
+	if created.IsZero() {
+		return 0
+	}
+	return math.Max(0, math.Min(now.Sub(created.Time).Hours()/24/365, 1))
+}
+
+func round(x float64) float64 {
+	return math.Round(x*1000) / 1000
+}
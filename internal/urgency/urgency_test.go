@@ -0,0 +1,139 @@
+package urgency
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+var testNow = time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+
+func days(n float64) models.Timestamp {
+	return models.Timestamp{Time: testNow.Add(time.Duration(n * 24 * float64(time.Hour)))}
+}
+
+func TestDueValue(t *testing.T) {
+	tests := []struct {
+		name string
+		due  models.Timestamp
+		want float64
+	}{
+		{"no due date", models.Timestamp{}, 0},
+		{"due in a month", days(30), 0.2},
+		{"due in two weeks", days(14), 0.2},
+		{"due in a week", days(7), 0.467},
+		{"due now", days(0), 0.733},
+		{"a day overdue", days(-1), 0.771},
+		{"a week overdue", days(-7), 1},
+		{"a month overdue", days(-30), 1},
+	}
+	for _, tt := range tests {
+		if got := round(dueValue(tt.due, testNow)); got != tt.want {
+			t.Errorf("%s: dueValue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAgeValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		created models.Timestamp
+		want    float64
+	}{
+		{"no creation date", models.Timestamp{}, 0},
+		{"new", days(0), 0},
+		{"created in the future", days(1), 0},
+		{"half a year old", days(-365.0 / 2), 0.5},
+		{"a year old", days(-365), 1},
+		{"two years old", days(-730), 1},
+	}
+	for _, tt := range tests {
+		if got := round(ageValue(tt.created, testNow)); got != tt.want {
+			t.Errorf("%s: ageValue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	high, low := models.High, models.Low
+	tests := []struct {
+		name  string
+		task  models.Task
+		deps  Dependencies
+		score float64
+		terms map[string]float64 // Score of each term by factor
+	}{
+		{
+			name:  "bare task",
+			task:  models.Task{},
+			score: 0,
+			terms: map[string]float64{"priority": 0, "due": 0, "age": 0, "blocking": 0, "blocked": 0},
+		},
+		{
+			name:  "low priority due in a month",
+			task:  models.Task{Priority: &low, DueDate: days(30), CreatedAt: days(0)},
+			score: 4.2,
+			terms: map[string]float64{"priority": 1.8, "due": 2.4, "age": 0, "blocking": 0, "blocked": 0},
+		},
+		{
+			name: "every term",
+			task: models.Task{Priority: &high, DueDate: days(0), CreatedAt: days(-365), Labels: []string{"next", "home"}},
+			deps: Dependencies{Blocking: 2, Blocked: true},
+			// 6 + 0.733*12 + 2 + 2*8 - 5 + 15
+			score: 42.8,
+			terms: map[string]float64{"priority": 6, "due": 8.8, "age": 2, "blocking": 16, "blocked": -5, "label:next": 15},
+		},
+	}
+	for _, tt := range tests {
+		urgency := DefaultWeights().Score(tt.task, tt.deps, testNow)
+		if urgency.Score != tt.score {
+			t.Errorf("%s: score = %v, want %v", tt.name, urgency.Score, tt.score)
+		}
+		terms := map[string]float64{}
+		for _, term := range urgency.Terms {
+			terms[term.Factor] = term.Score
+		}
+		if !reflect.DeepEqual(terms, tt.terms) {
+			t.Errorf("%s: terms = %v, want %v", tt.name, terms, tt.terms)
+		}
+	}
+}
+
+func TestScoreLabelOrder(t *testing.T) {
+	weights := DefaultWeights()
+	weights.Labels = map[string]float64{"next": 15, "someday": -5, "chore": 1}
+	task := models.Task{Labels: []string{"someday", "home", "next", "chore"}}
+
+	var factors []string
+	for _, term := range weights.Score(task, Dependencies{}, testNow).Terms {
+		factors = append(factors, term.Factor)
+	}
+	want := []string{"priority", "due", "age", "blocking", "blocked", "label:chore", "label:next", "label:someday"}
+	if !reflect.DeepEqual(factors, want) {
+		t.Errorf("terms = %v, want %v", factors, want)
+	}
+}
+
+func TestWeightsFromEnv(t *testing.T) {
+	t.Setenv("URGENCY_WEIGHTS", "High=10, due=0,blocked=-3")
+	t.Setenv("URGENCY_LABEL_WEIGHTS", "someday=-5")
+	weights, err := WeightsFromEnv()
+	if err != nil {
+		t.Fatalf("WeightsFromEnv: %v", err)
+	}
+	want := DefaultWeights()
+	want.Priority[models.High], want.Due, want.Blocked = 10, 0, -3
+	want.Labels = map[string]float64{"someday": -5}
+	if !reflect.DeepEqual(weights, want) {
+		t.Errorf("weights = %+v, want %+v", weights, want)
+	}
+
+	for _, value := range []string{"urgency=1", "due=soon", "due=NaN", "=1"} {
+		t.Setenv("URGENCY_WEIGHTS", value)
+		if _, err := WeightsFromEnv(); err == nil {
+			t.Errorf("URGENCY_WEIGHTS=%s was accepted", value)
+		}
+	}
+}
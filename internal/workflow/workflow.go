@@ -0,0 +1,239 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/iabdulzahid/golang_task_manager/internal/models"
+)
+
+// ErrIllegalTransition is returned when the workflow has no edge between two statuses.
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// Config is the JSON form of a workflow, as read from TASK_WORKFLOW_FILE:
+//
+//	{
+//	  "initial": "todo",
+//	  "started": ["in_progress"],
+//	  "terminal": ["done", "cancelled"],
+//	  "cancelled": ["cancelled"],
+//	  "transitions": {"todo": ["in_progress", "cancelled"], "in_progress": ["done"]}
+//	}
+//
+// Entering a "started" status stamps started_at; entering a terminal status
+// stamps completed_at, and leaving one clears it again. The "cancelled"
+// statuses are the terminal ones that give a task up instead of finishing it.
+type Config struct {
+	Initial     models.Status                     `json:"initial"`
+	Started     []models.Status                   `json:"started"`
+	Terminal    []models.Status                   `json:"terminal"`
+	Cancelled   []models.Status                   `json:"cancelled"`
+	Transitions map[models.Status][]models.Status `json:"transitions"`
+}
+
+// Workflow is a validated transition graph.
+type Workflow struct {
+	config      Config
+	statuses    map[models.Status]bool
+	started     map[models.Status]bool
+	terminal    map[models.Status]bool
+	cancelled   map[models.Status]bool
+	transitions map[models.Status]map[models.Status]bool
+}
+
+// DefaultConfig is the workflow used when TASK_WORKFLOW_FILE is not set.
+func DefaultConfig() Config {
+	return Config{
+		Initial:   models.StatusTodo,
+		Started:   []models.Status{models.StatusInProgress},
+		Terminal:  []models.Status{models.StatusDone, models.StatusCancelled},
+		Cancelled: []models.Status{models.StatusCancelled},
+		Transitions: map[models.Status][]models.Status{
+			models.StatusTodo:       {models.StatusInProgress, models.StatusBlocked, models.StatusDone, models.StatusCancelled},
+			models.StatusInProgress: {models.StatusTodo, models.StatusBlocked, models.StatusDone, models.StatusCancelled},
+			models.StatusBlocked:    {models.StatusTodo, models.StatusInProgress, models.StatusCancelled},
+			models.StatusDone:       {models.StatusTodo},
+			models.StatusCancelled:  {models.StatusTodo},
+		},
+	}
+}
+
+// New validates cfg and builds a Workflow from it.
+func New(cfg Config) (*Workflow, error) {
+	w := &Workflow{
+		config:      cfg,
+		statuses:    map[models.Status]bool{},
+		started:     map[models.Status]bool{},
+		terminal:    map[models.Status]bool{},
+		cancelled:   map[models.Status]bool{},
+		transitions: map[models.Status]map[models.Status]bool{},
+	}
+
+	for from, targets := range cfg.Transitions {
+		if from == "" {
+			return nil, fmt.Errorf("workflow has a transition from an empty status")
+		}
+		w.statuses[from] = true
+		w.transitions[from] = map[models.Status]bool{}
+		for _, to := range targets {
+			if to == "" {
+				return nil, fmt.Errorf("workflow has a transition from %s to an empty status", from)
+			}
+			w.statuses[to] = true
+			w.transitions[from][to] = true
+		}
+	}
+
+	if !w.statuses[cfg.Initial] {
+		return nil, fmt.Errorf("initial status %q does not appear in the transitions", cfg.Initial)
+	}
+	for _, s := range cfg.Started {
+		if !w.statuses[s] {
+			return nil, fmt.Errorf("started status %q does not appear in the transitions", s)
+		}
+		w.started[s] = true
+	}
+	for _, s := range cfg.Terminal {
+		if !w.statuses[s] {
+			return nil, fmt.Errorf("terminal status %q does not appear in the transitions", s)
+		}
+		w.terminal[s] = true
+	}
+	for _, s := range cfg.Cancelled {
+		if !w.terminal[s] {
+			return nil, fmt.Errorf("cancelled status %q is not a terminal status", s)
+		}
+		w.cancelled[s] = true
+	}
+	return w, nil
+}
+
+// Load reads a workflow Config from a JSON file.
+func Load(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow file: %v", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow file %s: %v", path, err)
+	}
+	return New(cfg)
+}
+
+var (
+	mu      sync.RWMutex
+	current = mustDefault()
+)
+
+func mustDefault() *Workflow {
+	w, err := New(DefaultConfig())
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// Current returns the active workflow.
+func Current() *Workflow {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set replaces the active workflow.
+func Set(w *Workflow) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = w
+}
+
+// InitFromEnv loads TASK_WORKFLOW_FILE when it is set and keeps the default workflow otherwise.
+func InitFromEnv() error {
+	path := os.Getenv("TASK_WORKFLOW_FILE")
+	if path == "" {
+		return nil
+	}
+	w, err := Load(path)
+	if err != nil {
+		return err
+	}
+	Set(w)
+	return nil
+}
+
+// Initial is the status new tasks start in.
+func (w *Workflow) Initial() models.Status {
+	return w.config.Initial
+}
+
+// IsKnown reports whether status is part of the workflow.
+func (w *Workflow) IsKnown(status models.Status) bool {
+	return w.statuses[status]
+}
+
+// IsTerminal reports whether status ends the task's life (e.g. done, cancelled).
+// Background jobs leave tasks in terminal statuses alone.
+func (w *Workflow) IsTerminal(status models.Status) bool {
+	return w.terminal[status]
+}
+
+// IsCancelled reports whether status is a terminal status that gives the task
+// up rather than finishing it.
+func (w *Workflow) IsCancelled(status models.Status) bool {
+	return w.cancelled[status]
+}
+
+// IsStarted reports whether entering status means work has begun.
+func (w *Workflow) IsStarted(status models.Status) bool {
+	return w.started[status]
+}
+
+// Allowed lists the statuses reachable from status in one step, sorted.
+func (w *Workflow) Allowed(status models.Status) []models.Status {
+	allowed := []models.Status{}
+	for to := range w.transitions[status] {
+		allowed = append(allowed, to)
+	}
+	sort.Slice(allowed, func(i, j int) bool { return allowed[i] < allowed[j] })
+	return allowed
+}
+
+// Statuses lists every status of the workflow, sorted.
+func (w *Workflow) Statuses() []models.Status {
+	statuses := make([]models.Status, 0, len(w.statuses))
+	for s := range w.statuses {
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	return statuses
+}
+
+// CheckTransition returns an ErrIllegalTransition error when from -> to is not an edge.
+func (w *Workflow) CheckTransition(from, to models.Status) error {
+	if !w.statuses[to] {
+		return fmt.Errorf("%w: unknown status %q. Valid values are: %v", ErrIllegalTransition, to, w.Statuses())
+	}
+	if !w.transitions[from][to] {
+		return fmt.Errorf("%w: cannot move a task from %s to %s. Allowed: %v", ErrIllegalTransition, from, to, w.Allowed(from))
+	}
+	return nil
+}
+
+// Apply sets task.Status to status and stamps started_at/completed_at accordingly.
+// It does not check the transition; call CheckTransition first.
+func (w *Workflow) Apply(task *models.Task, status models.Status, now models.Timestamp) {
+	task.Status = status
+	if w.IsStarted(status) && task.StartedAt.IsZero() {
+		task.StartedAt = now
+	}
+	if w.IsTerminal(status) {
+		task.CompletedAt = now
+	} else {
+		task.CompletedAt = models.Timestamp{}
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,8 +19,13 @@ import (
 	"github.com/iabdulzahid/golang_task_manager/internal/api"
 	taskDB "github.com/iabdulzahid/golang_task_manager/internal/database"
 	"github.com/iabdulzahid/golang_task_manager/internal/export"
+	"github.com/iabdulzahid/golang_task_manager/internal/leader"
 	"github.com/iabdulzahid/golang_task_manager/internal/middleware"
 	"github.com/iabdulzahid/golang_task_manager/internal/monitor"
+	"github.com/iabdulzahid/golang_task_manager/internal/priority"
+	"github.com/iabdulzahid/golang_task_manager/internal/stream"
+	"github.com/iabdulzahid/golang_task_manager/internal/urgency"
+	"github.com/iabdulzahid/golang_task_manager/internal/workflow"
 	"github.com/iabdulzahid/golang_task_manager/pkg/globals"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -32,12 +42,56 @@ import (
 // @license.url https://opensource.org/licenses/MIT
 // @host localhost:8080
 // @BasePath /api
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
 func main() {
+	// "migrate" manages the database schema and exits without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	// "admin" manages users and API keys
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdmin(os.Args[2:]))
+	}
+
 	// Initialize Database
-	db, err := taskDB.InitDB()
+	store, err := taskDB.InitDB()
 	if err != nil {
 		log.Fatal("Error initializing database:", err)
 	}
+	defer store.Close()
+
+	// API_KEY, if set, is a key of the "admin" user
+	if err := taskDB.BootstrapAPIKey(os.Getenv("API_KEY")); err != nil {
+		log.Fatal("Error registering API_KEY:", err)
+	}
+
+	// Load a custom status workflow if TASK_WORKFLOW_FILE is set
+	if err := workflow.InitFromEnv(); err != nil {
+		log.Fatal("Error loading task workflow:", err)
+	}
+	// Load a custom priority policy if PRIORITY_POLICY_FILE is set; its rules may name workflow statuses
+	if err := priority.InitFromEnv(); err != nil {
+		log.Fatal("Error loading priority policy:", err)
+	}
+	// Read the urgency weights of GET /tasks/next (URGENCY_WEIGHTS, URGENCY_LABEL_WEIGHTS)
+	if err := urgency.InitFromEnv(); err != nil {
+		log.Fatal("Error reading urgency weights:", err)
+	}
+
+	monitorOptions, err := monitor.OptionsFromEnv()
+	if err != nil {
+		log.Fatal("Error configuring the task monitor:", err)
+	}
+	leaderOptions, err := leader.OptionsFromEnv()
+	if err != nil {
+		log.Fatal("Error configuring leader election:", err)
+	}
+	streamOptions, err := stream.OptionsFromEnv()
+	if err != nil {
+		log.Fatal("Error configuring the change stream:", err)
+	}
 
 	goLogger, err := zLogger.NewLogger(
 		zLogger.Config{
@@ -55,8 +109,6 @@ func main() {
 		log.Fatal("Failed to create logger: ", err)
 	}
 
-	globals.DB = db
-
 	logger := goLogger.WithContext(
 		zap.String("requestId", "12345"),
 		zap.String("userId", "admin"),
@@ -66,21 +118,70 @@ func main() {
 	// Create a new Gin router
 	r := gin.Default()
 
-	// Apply rate limiting middleware
-	r.Use(middleware.RateLimiter())
+	// SIGINT and SIGTERM stop the server and the monitor
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Of several servers sharing the database, only the leader runs the monitor
+	taskMonitor := monitor.NewTaskMonitor(*logger, monitorOptions)
+	elector := leader.NewElector(leader.BackgroundJobs, leaderOptions)
+	leader.Set(elector)
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		elector.Run(ctx, taskMonitor.Run)
+	}()
 
-	go monitor.TaskMonitor(*logger)
+	// Keep the latest task changes for the change streams to resume from
+	changes := stream.NewLog(streamOptions)
+	stopChanges := changes.Start()
+	defer stopChanges()
+	stream.Set(changes)
 
+	// Each route group has its own rate limit bucket (see RATE_LIMIT_GROUPS)
 	// Swagger UI
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.GET("/swagger/*any", middleware.RateLimiter("swagger"), ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Define routes
-	r.POST("/tasks", api.CreateTask)
-	r.GET("/tasks", api.GetAllTasks)
-	r.GET("/tasks/:id", api.GetTaskByID)
-	r.PUT("/tasks/:id", api.UpdateTask)
-	r.DELETE("/tasks/:id", api.DeleteTask)
-	r.GET("/tasks/export", export.ExportTasks)
+	// Define routes; every task route needs an API key
+	tasks := r.Group("/tasks", middleware.RateLimiter("tasks"), middleware.Auth())
+	tasks.POST("", middleware.Idempotency(), api.CreateTask)
+	tasks.POST("/bulk", api.BulkTasks)
+	tasks.GET("", api.GetAllTasks)
+	tasks.GET("/:id", api.GetTaskByID)
+	tasks.PUT("/:id", api.UpdateTask)
+	tasks.PATCH("/:id", api.PatchTask)
+	tasks.DELETE("/:id", api.DeleteTask)
+	tasks.GET("/:id/children", api.GetTaskChildren)
+	tasks.GET("/:id/series", api.GetTaskSeries)
+	tasks.GET("/:id/priority", api.ExplainTaskPriority)
+	tasks.POST("/:id/transitions", api.TransitionTask)
+	tasks.GET("/:id/dependencies", api.GetTaskDependencies)
+	tasks.POST("/:id/dependencies", api.AddTaskDependency)
+	tasks.DELETE("/:id/dependencies/:depends_on_id", api.RemoveTaskDependency)
+	tasks.GET("/:id/blockers", api.GetTaskBlockers)
+	tasks.GET("/critical-path", api.GetCriticalPath)
+	tasks.GET("/next", api.GetNextTasks)
+	tasks.GET("/stream", api.StreamTaskChanges)
+	tasks.GET("/stream/ws", api.StreamTaskChangesWebSocket)
+
+	exports := r.Group("/tasks/export", middleware.RateLimiter("export"), middleware.Auth())
+	exports.GET("", export.ExportTasks)
+
+	imports := r.Group("/tasks/import", middleware.RateLimiter("import"), middleware.Auth())
+	imports.POST("", api.ImportTasks)
+
+	// Calendar apps cannot send headers; the feed takes a token of its own
+	calendar := r.Group("/calendar", middleware.RateLimiter("calendar"))
+	calendar.GET("/tasks.ics", middleware.CalendarAuth(), export.CalendarFeed)
+	calendar.POST("/token", middleware.Auth(), api.CreateCalendarToken)
+	calendar.DELETE("/token", middleware.Auth(), api.RevokeCalendarToken)
+
+	// Role grants; the handlers check who may grant in which scope
+	admin := r.Group("/admin", middleware.RateLimiter("admin"), middleware.Auth())
+	admin.GET("/grants", api.ListGrants)
+	admin.POST("/grants", api.CreateGrant)
+	admin.DELETE("/grants/:id", api.RevokeGrant)
+	admin.GET("/leader", api.GetLeaderStatus)
 
 	// Start the server
 	port := os.Getenv("PORT")
@@ -88,7 +189,23 @@ func main() {
 		port = "8080"
 	}
 
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	// Streams never finish on their own; end them so Shutdown need not wait
+	server.RegisterOnShutdown(changes.Close)
+	go func() {
+		log.Printf("Listening on %s\n", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+	// Requests in flight, e.g. long exports, get a while to finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server did not shut down cleanly: %v\n", err)
 	}
+	<-monitorDone
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	taskDB "github.com/iabdulzahid/golang_task_manager/internal/database"
+)
+
+const migrateUsage = `usage: golang_task_manager migrate <command>
+
+commands:
+  status         list migrations and whether they are applied
+  up             apply every pending migration
+  down [steps]   roll back the last migration, or the last <steps> migrations
+  to <version>   migrate up or down to exactly <version> (0 rolls back everything)`
+
+// runMigrate implements the "migrate" subcommand and returns the process exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	taskDB.LoadEnv()
+	store, err := taskDB.ConnectStore(os.Getenv("DATABASE_DRIVER"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error connecting to database:", err)
+		return 1
+	}
+	defer store.Close()
+
+	migrator, err := taskDB.MigratorFor(store)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(migrator)
+	case "up":
+		ran, err := migrator.Up()
+		return reportMigration(migrator, ran, err)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "steps must be a number:", args[1])
+				return 2
+			}
+		}
+		ran, err := migrator.Down(steps)
+		return reportMigration(migrator, ran, err)
+	case "to":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "missing target version\n"+migrateUsage)
+			return 2
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "version must be a number:", args[1])
+			return 2
+		}
+		ran, err := migrator.To(version)
+		return reportMigration(migrator, ran, err)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n%s\n", args[0], migrateUsage)
+		return 2
+	}
+}
+
+func printMigrationStatus(migrator *taskDB.Migrator) int {
+	statuses, err := migrator.Status()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading migration status:", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, status := range statuses {
+		state := "pending"
+		switch {
+		case status.Unknown:
+			state = "applied (unknown to this binary)"
+		case status.ChecksumMismatch:
+			state = "applied (checksum mismatch)"
+		case status.Applied:
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, status.AppliedAt)
+	}
+	w.Flush()
+
+	if err := migrator.Verify(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func reportMigration(migrator *taskDB.Migrator, ran int, err error) int {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Migration failed:", err)
+		return 1
+	}
+	current, err := migrator.CurrentVersion()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%d migration(s) run; schema is at version %d (latest %d)\n", ran, current, migrator.LatestVersion())
+	return 0
+}